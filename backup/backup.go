@@ -0,0 +1,223 @@
+// Copyright 2023 ClearBlade Inc.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package backup snapshots a registry to an archive and recreates it from
+// one, in the same or another location:
+//
+//	f, err := os.Create("registry.backup")
+//	...
+//	err = backup.Backup(ctx, service, name, f, backup.Options{})
+//	...
+//	err = backup.Restore(ctx, service, f, target, backup.Options{})
+//
+// An archive holds the registry settings, every device with its metadata,
+// credentials, config history, recent states and gateway bindings, and the
+// IAM policy of the registry. Device states are reported by devices, so they
+// are kept in the archive for reference but cannot be restored. If the
+// service does not support IAM policies, the archive records that the
+// policy was omitted, and Backup and Restore report it as a warning.
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	iot "github.com/clearblade/go-iot"
+)
+
+// Format identifies backup archives, and FormatVersion is the version of the
+// archive format written by Backup. Restore reads archives of this version
+// and earlier.
+const (
+	Format        = "go-iot-backup"
+	FormatVersion = 1
+)
+
+// ErrUnsupportedArchive is returned by Restore for data that is not a backup
+// archive, or was written by a newer version of this package.
+var ErrUnsupportedArchive = errors.New("backup: unsupported archive")
+
+// Header is the first record of an archive. It is followed by one Record
+// per device.
+type Header struct {
+	Format  string `json:"format"`
+	Version int    `json:"version"`
+	// Registry is the name of the registry that was backed up.
+	Registry string `json:"registry"`
+	// CreateTime is when the backup started, in RFC3339 format.
+	CreateTime string `json:"createTime"`
+	// Devices is the number of device records that follow.
+	Devices int `json:"devices"`
+
+	DeviceRegistry *iot.DeviceRegistry `json:"deviceRegistry"`
+	// Policy is the IAM policy of the registry.
+	Policy *iot.Policy `json:"policy,omitempty"`
+	// PolicyOmitted, if set, is why the IAM policy could not be backed up,
+	// such as the service not supporting IAM policies.
+	PolicyOmitted string `json:"policyOmitted,omitempty"`
+}
+
+// Record is the archived state of a device.
+type Record struct {
+	Device *iot.Device `json:"device"`
+	// ConfigVersions is the config history of the device, oldest first.
+	ConfigVersions []*iot.DeviceConfig `json:"configVersions,omitempty"`
+	// States are the most recent states of the device, newest first.
+	States []*iot.DeviceState `json:"states,omitempty"`
+	// BoundDevices are the IDs of the devices bound to a gateway.
+	BoundDevices []string `json:"boundDevices,omitempty"`
+}
+
+// Stage is the part of a backup or restore a Progress report is about.
+type Stage string
+
+const (
+	StageRegistry Stage = "registry"
+	StageDevices  Stage = "devices"
+	StageBindings Stage = "bindings"
+	StagePolicy   Stage = "policy"
+)
+
+// Progress reports how far a backup or restore has come.
+type Progress struct {
+	Stage Stage
+	// Done and Total count the items of the stage, such as devices or
+	// bindings.
+	Done, Total int
+	// Warning, if set, is a part of the stage that was skipped, such as an
+	// IAM policy that could not be backed up or restored.
+	Warning string
+}
+
+// Options configures Backup and Restore.
+type Options struct {
+	// States is the number of recent states backed up per device. The
+	// default is all the states the service keeps.
+	States int64
+
+	// Progress, if set, is called as each item of a stage is done, and with
+	// warnings for the parts of a stage that were skipped.
+	Progress func(Progress)
+}
+
+func (o Options) report(stage Stage, done, total int) {
+	if o.Progress != nil {
+		o.Progress(Progress{Stage: stage, Done: done, Total: total})
+	}
+}
+
+func (o Options) warn(stage Stage, format string, args ...interface{}) {
+	if o.Progress != nil {
+		o.Progress(Progress{Stage: stage, Warning: fmt.Sprintf(format, args...)})
+	}
+}
+
+// Backup writes an archive of a registry to w. The registry may change while
+// it is backed up: devices deleted after they were listed are left out. All
+// devices are read before the archive is written, so that its header counts
+// the devices it holds.
+func Backup(ctx context.Context, service *iot.Service, name iot.RegistryName, w io.Writer, opts Options) error {
+	registries := service.Projects.Locations.Registries
+	devices := registries.Devices
+
+	registry, err := registries.GetByName(name).Context(ctx).Do()
+	if err != nil {
+		return err
+	}
+	var policyOmitted string
+	policy, err := registries.GetIamPolicy(name.String(), &iot.GetIamPolicyRequest{}).Context(ctx).Do()
+	if errors.Is(err, iot.ErrNotImplemented) {
+		policy, err = nil, nil
+		policyOmitted = "the service does not support IAM policies"
+	}
+	if err != nil {
+		return err
+	}
+	opts.report(StageRegistry, 1, 1)
+	if policyOmitted != "" {
+		opts.warn(StagePolicy, "IAM policy not backed up: %s", policyOmitted)
+	}
+
+	var ids []string
+	for d, err := range devices.ListIn(name).FieldMask("id").All(ctx) {
+		if err != nil {
+			return err
+		}
+		ids = append(ids, d.Id)
+	}
+
+	createTime := time.Now().UTC().Format(time.RFC3339)
+	var records []*Record
+	for i, id := range ids {
+		r, err := backupDevice(ctx, service, name.Device(id), opts)
+		if errors.Is(err, iot.ErrNotFound) {
+			// The device was deleted after it was listed.
+			opts.report(StageDevices, i+1, len(ids))
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("backup: device %s: %w", id, err)
+		}
+		records = append(records, r)
+		opts.report(StageDevices, i+1, len(ids))
+	}
+
+	enc := json.NewEncoder(w)
+	err = enc.Encode(&Header{
+		Format:         Format,
+		Version:        FormatVersion,
+		Registry:       name.String(),
+		CreateTime:     createTime,
+		Devices:        len(records),
+		DeviceRegistry: registry,
+		Policy:         policy,
+		PolicyOmitted:  policyOmitted,
+	})
+	if err != nil {
+		return err
+	}
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func backupDevice(ctx context.Context, service *iot.Service, name iot.DeviceName, opts Options) (*Record, error) {
+	devices := service.Projects.Locations.Registries.Devices
+	device, err := devices.GetByName(name).Context(ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+	r := &Record{Device: device}
+
+	configs, err := devices.ConfigVersions.ListByName(name).Context(ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+	for i := len(configs.DeviceConfigs) - 1; i >= 0; i-- {
+		r.ConfigVersions = append(r.ConfigVersions, configs.DeviceConfigs[i])
+	}
+	states, err := devices.States.ListByName(name).NumStates(opts.States).Context(ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+	r.States = states.DeviceStates
+
+	if device.GatewayConfig != nil && device.GatewayConfig.GatewayType == "GATEWAY" {
+		bound := devices.ListIn(name.Parent()).GatewayListOptionsAssociationsGatewayId(name.Device).FieldMask("id")
+		for d, err := range bound.All(ctx) {
+			if err != nil {
+				return nil, err
+			}
+			r.BoundDevices = append(r.BoundDevices, d.Id)
+		}
+	}
+	return r, nil
+}
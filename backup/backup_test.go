@@ -0,0 +1,170 @@
+package backup_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	iot "github.com/clearblade/go-iot"
+	"github.com/clearblade/go-iot/backup"
+	"github.com/clearblade/go-iot/iottest"
+)
+
+func TestBackupAndRestore(t *testing.T) {
+	fake, service := iottest.NewService(t, "test-project")
+	ctx := context.Background()
+	registries := service.Projects.Locations.Registries
+	devices := registries.Devices
+	source := iot.LocationName{Project: "test-project", Location: "us-central1"}.Registry("registry")
+	target := iot.LocationName{Project: "test-project", Location: "europe-west1"}.Registry("restored")
+
+	if _, err := registries.CreateIn(source.Parent(), &iot.DeviceRegistry{Id: source.Registry, LogLevel: "INFO"}).Do(); err != nil {
+		t.Fatalf("Failed to create registry: %s", err.Error())
+	}
+	for _, d := range []*iot.Device{
+		{Id: "gateway", GatewayConfig: &iot.GatewayConfig{GatewayType: "GATEWAY", GatewayAuthMethod: "ASSOCIATION_ONLY"}},
+		{Id: "device", Metadata: map[string]string{"site": "berlin"}, Blocked: true},
+	} {
+		if _, err := devices.CreateIn(source, d).Do(); err != nil {
+			t.Fatalf("Failed to create device: %s", err.Error())
+		}
+	}
+	if _, err := registries.BindDeviceToGatewayIn(source, &iot.BindDeviceToGatewayRequest{DeviceId: "device", GatewayId: "gateway"}).Do(); err != nil {
+		t.Fatalf("Failed to bind device: %s", err.Error())
+	}
+	for _, config := range []string{"one", "two"} {
+		_, err := devices.ModifyCloudToDeviceConfigByName(source.Device("device"), &iot.ModifyCloudToDeviceConfigRequest{
+			BinaryData: base64.StdEncoding.EncodeToString([]byte(config)),
+		}).Do()
+		if err != nil {
+			t.Fatalf("Failed to modify config: %s", err.Error())
+		}
+	}
+	fake.ReportState(source.Device("device").String(), base64.StdEncoding.EncodeToString([]byte("running")))
+
+	var archive bytes.Buffer
+	var progress []backup.Progress
+	opts := backup.Options{Progress: func(p backup.Progress) { progress = append(progress, p) }}
+	if err := backup.Backup(ctx, service, source, &archive, opts); err != nil {
+		t.Fatalf("Failed to back up registry: %s", err.Error())
+	}
+	if !strings.Contains(archive.String(), `"states":[{"binaryData"`) {
+		t.Errorf("Expected the archive to contain device states but got: %s", archive.String())
+	}
+	if last := progress[len(progress)-1]; last.Stage != backup.StageDevices || last.Done != 2 || last.Total != 2 {
+		t.Errorf("Expected progress to end with 2 of 2 devices but got: %+v", last)
+	}
+	// The fake does not support IAM policies, which the archive records.
+	var header backup.Header
+	if err := json.NewDecoder(bytes.NewReader(archive.Bytes())).Decode(&header); err != nil {
+		t.Fatalf("Failed to decode header: %s", err.Error())
+	}
+	if header.Policy != nil || header.PolicyOmitted == "" {
+		t.Errorf("Expected the policy to be recorded as omitted but got: %+v", header)
+	}
+	if !hasWarning(progress, backup.StagePolicy) {
+		t.Errorf("Expected a warning about the policy but got: %+v", progress)
+	}
+
+	progress = nil
+	if err := backup.Restore(ctx, service, bytes.NewReader(archive.Bytes()), target, opts); err != nil {
+		t.Fatalf("Failed to restore registry: %s", err.Error())
+	}
+	if last := progress[len(progress)-2]; last.Stage != backup.StageBindings || last.Done != 1 || last.Total != 1 {
+		t.Errorf("Expected 1 of 1 bindings before the policy warning but got: %+v", last)
+	}
+	if !hasWarning(progress, backup.StagePolicy) {
+		t.Errorf("Expected a warning about the policy but got: %+v", progress)
+	}
+	registry, err := registries.GetByName(target).Do()
+	if err != nil {
+		t.Fatalf("Failed to get registry: %s", err.Error())
+	}
+	if registry.LogLevel != "INFO" {
+		t.Errorf("Expected log level INFO but got: %s", registry.LogLevel)
+	}
+	device, err := devices.GetByName(target.Device("device")).Do()
+	if err != nil {
+		t.Fatalf("Failed to get device: %s", err.Error())
+	}
+	if device.Metadata["site"] != "berlin" || !device.Blocked {
+		t.Errorf("Expected metadata and blocked to be restored but got: %+v", device)
+	}
+	if data, _ := base64.StdEncoding.DecodeString(device.Config.BinaryData); string(data) != "two" || device.Config.Version != 3 {
+		t.Errorf("Expected config history to be replayed but got version %d: %q", device.Config.Version, data)
+	}
+	bound, err := devices.ListIn(target).GatewayListOptionsAssociationsGatewayId("gateway").Do()
+	if err != nil {
+		t.Fatalf("Failed to list bound devices: %s", err.Error())
+	}
+	if len(bound.Devices) != 1 || bound.Devices[0].Id != "device" {
+		t.Errorf("Expected device to be bound to gateway but got: %d devices", len(bound.Devices))
+	}
+
+	// Restoring over an existing registry fails before anything is changed.
+	err = backup.Restore(ctx, service, bytes.NewReader(archive.Bytes()), target, backup.Options{})
+	if !errors.Is(err, iot.ErrAlreadyExists) {
+		t.Errorf("Expected ErrAlreadyExists but got: %v", err)
+	}
+	// A truncated archive is not restored as if it were complete.
+	lines := strings.SplitAfter(archive.String(), "\n")
+	truncated := strings.Join(lines[:len(lines)-2], "")
+	err = backup.Restore(ctx, service, strings.NewReader(truncated), target.Parent().Registry("truncated"), backup.Options{})
+	if !errors.Is(err, backup.ErrUnsupportedArchive) {
+		t.Errorf("Expected ErrUnsupportedArchive for a truncated archive but got: %v", err)
+	}
+	err = backup.Restore(ctx, service, strings.NewReader(`{"format":"other"}`), target, backup.Options{})
+	if !errors.Is(err, backup.ErrUnsupportedArchive) {
+		t.Errorf("Expected ErrUnsupportedArchive but got: %v", err)
+	}
+}
+
+func hasWarning(progress []backup.Progress, stage backup.Stage) bool {
+	for _, p := range progress {
+		if p.Stage == stage && p.Warning != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func TestBackupDeletedDevice(t *testing.T) {
+	_, service := iottest.NewService(t, "test-project")
+	ctx := context.Background()
+	devices := service.Projects.Locations.Registries.Devices
+	source := iot.LocationName{Project: "test-project", Location: "us-central1"}.Registry("registry")
+	iottest.CreateRegistry(t, service, source)
+	for _, id := range []string{"device-a", "device-b"} {
+		if _, err := devices.CreateIn(source, &iot.Device{Id: id}).Do(); err != nil {
+			t.Fatalf("Failed to create device: %s", err.Error())
+		}
+	}
+
+	// device-b is deleted after it was listed, while device-a is read.
+	var archive bytes.Buffer
+	opts := backup.Options{Progress: func(p backup.Progress) {
+		if p.Stage == backup.StageDevices && p.Done == 1 {
+			if _, err := devices.DeleteByName(source.Device("device-b")).Do(); err != nil {
+				t.Errorf("Failed to delete device: %s", err.Error())
+			}
+		}
+	}}
+	if err := backup.Backup(ctx, service, source, &archive, opts); err != nil {
+		t.Fatalf("Failed to back up registry: %s", err.Error())
+	}
+	var header backup.Header
+	if err := json.NewDecoder(bytes.NewReader(archive.Bytes())).Decode(&header); err != nil {
+		t.Fatalf("Failed to decode header: %s", err.Error())
+	}
+	if header.Devices != 1 {
+		t.Errorf("Expected the header to count 1 device but got: %d", header.Devices)
+	}
+	target := source.Parent().Registry("restored")
+	if err := backup.Restore(ctx, service, bytes.NewReader(archive.Bytes()), target, backup.Options{}); err != nil {
+		t.Errorf("Failed to restore registry: %s", err.Error())
+	}
+}
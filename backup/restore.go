@@ -0,0 +1,169 @@
+// Copyright 2023 ClearBlade Inc.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package backup
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	iot "github.com/clearblade/go-iot"
+)
+
+// Restore recreates the registry of an archive as target, which may be in
+// another project or location, and must not exist yet. Devices get their
+// metadata, credentials and settings back, and their config history is
+// replayed so that the last version is current; version numbers and times
+// are assigned anew. Gateway bindings and the IAM policy are restored once
+// all devices exist.
+//
+// Pub/Sub topics in the registry settings are restored as they are, so they
+// must exist in the target project.
+//
+// An archive with a different number of device records than its header
+// counts, such as a partial file left by a failed Backup, fails with
+// ErrUnsupportedArchive once its records are read, before any gateway
+// bindings are restored.
+func Restore(ctx context.Context, service *iot.Service, archive io.Reader, target iot.RegistryName, opts Options) error {
+	if err := target.Validate(); err != nil {
+		return err
+	}
+	registries := service.Projects.Locations.Registries
+	dec := json.NewDecoder(bufio.NewReader(archive))
+
+	var h Header
+	if err := dec.Decode(&h); err != nil {
+		return fmt.Errorf("%w: %v", ErrUnsupportedArchive, err)
+	}
+	if h.Format != Format || h.Version < 1 || h.Version > FormatVersion || h.DeviceRegistry == nil {
+		return fmt.Errorf("%w: format %q version %d", ErrUnsupportedArchive, h.Format, h.Version)
+	}
+
+	src := h.DeviceRegistry
+	registry := &iot.DeviceRegistry{
+		Id:                       target.Registry,
+		EventNotificationConfigs: src.EventNotificationConfigs,
+		StateNotificationConfig:  src.StateNotificationConfig,
+		MqttConfig:               src.MqttConfig,
+		HttpConfig:               src.HttpConfig,
+		LogLevel:                 src.LogLevel,
+	}
+	for _, c := range src.Credentials {
+		if c.PublicKeyCertificate != nil {
+			registry.Credentials = append(registry.Credentials, &iot.RegistryCredential{
+				PublicKeyCertificate: &iot.PublicKeyCertificate{
+					Format:      c.PublicKeyCertificate.Format,
+					Certificate: c.PublicKeyCertificate.Certificate,
+				},
+			})
+		}
+	}
+	if _, err := registries.CreateIn(target.Parent(), registry).Context(ctx).Do(); err != nil {
+		return err
+	}
+	opts.report(StageRegistry, 1, 1)
+
+	var gateways []*Record
+	var bindingCount, records int
+	for ; ; records++ {
+		var r Record
+		if err := dec.Decode(&r); err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("%w: %v", ErrUnsupportedArchive, err)
+		}
+		if r.Device == nil {
+			return fmt.Errorf("%w: record %d has no device", ErrUnsupportedArchive, records+1)
+		}
+		if err := restoreDevice(ctx, service, target, &r); err != nil {
+			return fmt.Errorf("backup: device %s: %w", r.Device.Id, err)
+		}
+		if len(r.BoundDevices) > 0 {
+			gateways = append(gateways, &Record{Device: r.Device, BoundDevices: r.BoundDevices})
+			bindingCount += len(r.BoundDevices)
+		}
+		opts.report(StageDevices, records+1, h.Devices)
+	}
+	if records != h.Devices {
+		return fmt.Errorf("%w: %d of %d device records", ErrUnsupportedArchive, records, h.Devices)
+	}
+
+	done := 0
+	for _, gateway := range gateways {
+		for _, id := range gateway.BoundDevices {
+			_, err := registries.BindDeviceToGatewayIn(target, &iot.BindDeviceToGatewayRequest{
+				DeviceId:  id,
+				GatewayId: gateway.Device.Id,
+			}).Context(ctx).Do()
+			if err != nil {
+				return fmt.Errorf("backup: binding %s to gateway %s: %w", id, gateway.Device.Id, err)
+			}
+			done++
+			opts.report(StageBindings, done, bindingCount)
+		}
+	}
+
+	if h.Policy != nil && len(h.Policy.Bindings) > 0 {
+		policy := *h.Policy
+		policy.Etag = ""
+		_, err := registries.SetIamPolicy(target.String(), &iot.SetIamPolicyRequest{Policy: &policy}).Context(ctx).Do()
+		if err != nil {
+			return fmt.Errorf("backup: restoring IAM policy: %w", err)
+		}
+		opts.report(StagePolicy, 1, 1)
+	}
+	if h.PolicyOmitted != "" {
+		opts.warn(StagePolicy, "IAM policy not restored, it was not backed up: %s", h.PolicyOmitted)
+	}
+	return nil
+}
+
+func restoreDevice(ctx context.Context, service *iot.Service, target iot.RegistryName, r *Record) error {
+	devices := service.Projects.Locations.Registries.Devices
+	src := r.Device
+	device := &iot.Device{
+		Id:       src.Id,
+		Metadata: src.Metadata,
+		Blocked:  src.Blocked,
+		LogLevel: src.LogLevel,
+	}
+	for _, c := range src.Credentials {
+		if c.PublicKey != nil {
+			device.Credentials = append(device.Credentials, &iot.DeviceCredential{
+				PublicKey:      c.PublicKey,
+				ExpirationTime: c.ExpirationTime,
+			})
+		}
+	}
+	if src.GatewayConfig != nil {
+		device.GatewayConfig = &iot.GatewayConfig{
+			GatewayType:       src.GatewayConfig.GatewayType,
+			GatewayAuthMethod: src.GatewayConfig.GatewayAuthMethod,
+		}
+	}
+
+	configs := r.ConfigVersions
+	if len(configs) == 0 && src.Config != nil {
+		configs = []*iot.DeviceConfig{src.Config}
+	}
+	if len(configs) > 0 {
+		device.Config = &iot.DeviceConfig{BinaryData: configs[0].BinaryData}
+		configs = configs[1:]
+	}
+	if _, err := devices.CreateIn(target, device).Context(ctx).Do(); err != nil {
+		return err
+	}
+	for _, c := range configs {
+		_, err := devices.ModifyCloudToDeviceConfigByName(target.Device(src.Id), &iot.ModifyCloudToDeviceConfigRequest{
+			BinaryData: c.BinaryData,
+		}).Context(ctx).Do()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
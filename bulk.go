@@ -0,0 +1,250 @@
+// Copyright 2023 ClearBlade Inc.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"sync"
+	"time"
+)
+
+// defaultBulkConcurrency is the number of requests a bulk operation runs at
+// the same time by default.
+const defaultBulkConcurrency = 10
+
+// BulkOptions configures a bulk operation.
+type BulkOptions struct {
+	// Concurrency is the number of requests run at the same time. The
+	// default is 10.
+	Concurrency int
+	// RequestsPerSecond, if positive, limits how many requests are started
+	// per second.
+	RequestsPerSecond float64
+}
+
+// BulkStatus is the outcome of an item of a bulk operation.
+type BulkStatus string
+
+const (
+	BulkSucceeded BulkStatus = "SUCCEEDED"
+	// BulkAlreadyExists is the status of a device BulkCreateDevices did not
+	// create because it exists.
+	BulkAlreadyExists BulkStatus = "ALREADY_EXISTS"
+	BulkFailed        BulkStatus = "FAILED"
+)
+
+// BulkResult is the outcome of an item of a bulk operation.
+type BulkResult struct {
+	// Index is the position of the item in the input.
+	Index int
+	// Name is the device of the item. For BulkDeleteDevices and
+	// BulkGetDevices, its Device field is the input ID.
+	Name DeviceName
+	// Input is the input device of a create or patch, and Update the input
+	// of a config change, so that failed items can be run again even if the
+	// input sequence cannot be read twice.
+	Input  *Device
+	Update *ConfigUpdate
+	Status BulkStatus
+	// Err is the error of a failed item.
+	Err error
+	// Device is the device returned by a create, patch or get.
+	Device *Device
+	// Config is the config returned by a config change.
+	Config *DeviceConfig
+}
+
+// BulkReport is the outcome of a bulk operation, with a result for every
+// item that was started, in input order.
+//
+// Once the context of the operation is done, no more items are started, and
+// items in flight fail with the context's error. The operation waits for the
+// next item of its input sequence, so a sequence that blocks has to stop
+// when the context is done too, as ChannelValues does. The items of a slice input
+// that were not started are the ones from len(Results) on.
+type BulkReport struct {
+	Results []*BulkResult
+}
+
+// Count returns the number of items with a status.
+func (r *BulkReport) Count(status BulkStatus) int {
+	n := 0
+	for _, res := range r.Results {
+		if res.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// Failed returns the results of the items that failed, to run them again.
+func (r *BulkReport) Failed() []*BulkResult {
+	var failed []*BulkResult
+	for _, res := range r.Results {
+		if res.Status == BulkFailed {
+			failed = append(failed, res)
+		}
+	}
+	return failed
+}
+
+// Err returns an error summarizing the failed items, or nil if none failed.
+// It wraps the error of the first failed item.
+func (r *BulkReport) Err() error {
+	failed := r.Failed()
+	if len(failed) == 0 {
+		return nil
+	}
+	return fmt.Errorf("iot: %d of %d items failed, first %s: %w", len(failed), len(r.Results), failed[0].Name, failed[0].Err)
+}
+
+// ConfigUpdate is a config change for BulkModifyConfig.
+type ConfigUpdate struct {
+	DeviceID string
+	Request  *ModifyCloudToDeviceConfigRequest
+}
+
+// ChannelValues returns a sequence of the values received from ch until it
+// is closed or ctx is done, to pass a channel to a bulk operation. Pass the
+// context of the operation, so that it returns once the context is done
+// even if nothing more is sent on ch.
+func ChannelValues[T any](ctx context.Context, ch <-chan T) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for {
+			select {
+			case v, ok := <-ch:
+				if !ok || !yield(v) {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// BulkCreateDevices creates devices in a registry. Devices that exist
+// already have the status BulkAlreadyExists. Use slices.Values to pass a
+// slice, or ChannelValues to pass a channel.
+func (r *ProjectsLocationsRegistriesDevicesService) BulkCreateDevices(ctx context.Context, parent RegistryName, devices iter.Seq[*Device], opts *BulkOptions) *BulkReport {
+	return runBulk(ctx, devices, opts, func(d *Device, res *BulkResult) error {
+		res.Name, res.Input = parent.Device(d.Id), d
+		var err error
+		res.Device, err = r.CreateIn(parent, d).Context(ctx).Do()
+		return err
+	})
+}
+
+// BulkPatchDevices patches the fields in updateMask of devices, which are
+// identified by their IDs.
+func (r *ProjectsLocationsRegistriesDevicesService) BulkPatchDevices(ctx context.Context, parent RegistryName, devices iter.Seq[*Device], updateMask string, opts *BulkOptions) *BulkReport {
+	return runBulk(ctx, devices, opts, func(d *Device, res *BulkResult) error {
+		res.Name, res.Input = parent.Device(d.Id), d
+		var err error
+		res.Device, err = r.PatchByName(res.Name, d).UpdateMask(updateMask).Context(ctx).Do()
+		return err
+	})
+}
+
+// BulkGetDevices gets devices by ID.
+func (r *ProjectsLocationsRegistriesDevicesService) BulkGetDevices(ctx context.Context, parent RegistryName, ids iter.Seq[string], opts *BulkOptions) *BulkReport {
+	return runBulk(ctx, ids, opts, func(id string, res *BulkResult) error {
+		res.Name = parent.Device(id)
+		var err error
+		res.Device, err = r.GetByName(res.Name).Context(ctx).Do()
+		return err
+	})
+}
+
+// BulkDeleteDevices deletes devices by ID.
+func (r *ProjectsLocationsRegistriesDevicesService) BulkDeleteDevices(ctx context.Context, parent RegistryName, ids iter.Seq[string], opts *BulkOptions) *BulkReport {
+	return runBulk(ctx, ids, opts, func(id string, res *BulkResult) error {
+		res.Name = parent.Device(id)
+		_, err := r.DeleteByName(res.Name).Context(ctx).Do()
+		return err
+	})
+}
+
+// BulkModifyConfig sends new configs to devices.
+func (r *ProjectsLocationsRegistriesDevicesService) BulkModifyConfig(ctx context.Context, parent RegistryName, updates iter.Seq[*ConfigUpdate], opts *BulkOptions) *BulkReport {
+	return runBulk(ctx, updates, opts, func(u *ConfigUpdate, res *BulkResult) error {
+		res.Name, res.Update = parent.Device(u.DeviceID), u
+		var err error
+		res.Config, err = r.ModifyCloudToDeviceConfigByName(res.Name, u.Request).Context(ctx).Do()
+		return err
+	})
+}
+
+// runBulk runs do for every item of items, with the concurrency and rate
+// of opts, and collects the results.
+func runBulk[T any](ctx context.Context, items iter.Seq[T], opts *BulkOptions, do func(item T, res *BulkResult) error) *BulkReport {
+	concurrency := defaultBulkConcurrency
+	var interval time.Duration
+	if opts != nil {
+		if opts.Concurrency > 0 {
+			concurrency = opts.Concurrency
+		}
+		if opts.RequestsPerSecond > 0 {
+			interval = time.Duration(float64(time.Second) / opts.RequestsPerSecond)
+		}
+	}
+	var ticks <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+
+	type job struct {
+		item T
+		res  *BulkResult
+	}
+	jobs := make(chan job)
+	report := &BulkReport{}
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				err := do(j.item, j.res)
+				switch {
+				case err == nil:
+					j.res.Status = BulkSucceeded
+				case errors.Is(err, ErrAlreadyExists):
+					j.res.Status = BulkAlreadyExists
+				default:
+					j.res.Status, j.res.Err = BulkFailed, err
+				}
+			}
+		}()
+	}
+
+	for item := range items {
+		if ticks != nil && len(report.Results) > 0 {
+			select {
+			case <-ticks:
+			case <-ctx.Done():
+			}
+		}
+		res := &BulkResult{Index: len(report.Results)}
+		if ctx.Err() == nil {
+			select {
+			case jobs <- job{item, res}:
+				report.Results = append(report.Results, res)
+			case <-ctx.Done():
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	return report
+}
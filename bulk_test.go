@@ -0,0 +1,103 @@
+package iot_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	iot "github.com/clearblade/go-iot"
+	"github.com/clearblade/go-iot/iottest"
+)
+
+func TestBulkOperations(t *testing.T) {
+	_, service := iottest.NewService(t, "test-project")
+	ctx := context.Background()
+	registry := iot.LocationName{Project: "test-project", Location: "us-central1"}.Registry("registry")
+	iottest.CreateRegistry(t, service, registry)
+	devices := service.Projects.Locations.Registries.Devices
+	if _, err := devices.CreateIn(registry, &iot.Device{Id: "device-3"}).Do(); err != nil {
+		t.Fatalf("Failed to create device: %s", err.Error())
+	}
+
+	var batch []*iot.Device
+	for i := 0; i < 25; i++ {
+		batch = append(batch, &iot.Device{Id: fmt.Sprintf("device-%d", i), Metadata: map[string]string{"batch": "1"}})
+	}
+	opts := &iot.BulkOptions{Concurrency: 4}
+	report := devices.BulkCreateDevices(ctx, registry, slices.Values(batch), opts)
+	if err := report.Err(); err != nil {
+		t.Fatalf("Failed to create devices: %s", err.Error())
+	}
+	if len(report.Results) != 25 || report.Count(iot.BulkAlreadyExists) != 1 || report.Results[3].Status != iot.BulkAlreadyExists {
+		t.Errorf("Expected 24 created devices and device-3 to exist but got: %d results, %d existing", len(report.Results), report.Count(iot.BulkAlreadyExists))
+	}
+	for i, res := range report.Results {
+		if res.Index != i || res.Name.Device != batch[i].Id || res.Input != batch[i] {
+			t.Errorf("Expected result %d to be for %s but got: %+v", i, batch[i].Id, res)
+		}
+	}
+
+	report = devices.BulkPatchDevices(ctx, registry, slices.Values(batch[:5]), "metadata", opts)
+	if err := report.Err(); err != nil || report.Results[0].Device.Metadata["batch"] != "1" {
+		t.Errorf("Failed to patch devices: %v", err)
+	}
+
+	updates := make(chan *iot.ConfigUpdate)
+	go func() {
+		defer close(updates)
+		for _, id := range []string{"device-0", "device-1", "missing"} {
+			updates <- &iot.ConfigUpdate{DeviceID: id, Request: &iot.ModifyCloudToDeviceConfigRequest{BinaryData: "b24="}}
+		}
+	}()
+	report = devices.BulkModifyConfig(ctx, registry, iot.ChannelValues(ctx, updates), opts)
+	failed := report.Failed()
+	if len(failed) != 1 || failed[0].Name.Device != "missing" || !errors.Is(failed[0].Err, iot.ErrNotFound) {
+		t.Errorf("Expected only the missing device to fail but got: %v", report.Err())
+	}
+	if report.Results[0].Config.Version != 2 {
+		t.Errorf("Expected config version 2 but got: %d", report.Results[0].Config.Version)
+	}
+
+	// The channel is drained, so the failed update is run again from its
+	// result once the device exists.
+	if _, err := devices.CreateIn(registry, &iot.Device{Id: "missing"}).Do(); err != nil {
+		t.Fatalf("Failed to create device: %s", err.Error())
+	}
+	var retry []*iot.ConfigUpdate
+	for _, res := range failed {
+		retry = append(retry, res.Update)
+	}
+	report = devices.BulkModifyConfig(ctx, registry, slices.Values(retry), opts)
+	if err := report.Err(); err != nil || len(report.Results) != 1 || report.Results[0].Name.Device != "missing" {
+		t.Errorf("Failed to run the failed update again: %v", err)
+	}
+
+	report = devices.BulkGetDevices(ctx, registry, slices.Values([]string{"device-0", "device-1"}), opts)
+	if err := report.Err(); err != nil || report.Results[1].Device.Id != "device-1" || report.Results[1].Device.Config.Version != 2 {
+		t.Errorf("Failed to get devices: %v", err)
+	}
+
+	// Canceling an operation reading from a channel returns even though the
+	// channel stays open.
+	unclosed := make(chan string)
+	canceled, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if report := devices.BulkDeleteDevices(canceled, registry, iot.ChannelValues(canceled, unclosed), opts); len(report.Results) != 0 {
+		t.Errorf("Expected no deletes but got: %d", len(report.Results))
+	}
+
+	// A canceled operation starts nothing.
+	canceled, cancel = context.WithCancel(ctx)
+	cancel()
+	ids := []string{"device-0", "device-1"}
+	if report := devices.BulkDeleteDevices(canceled, registry, slices.Values(ids), opts); len(report.Results) != 0 {
+		t.Errorf("Expected no deletes after cancellation but got: %d", len(report.Results))
+	}
+	report = devices.BulkDeleteDevices(ctx, registry, slices.Values(ids), &iot.BulkOptions{RequestsPerSecond: 100})
+	if err := report.Err(); err != nil || report.Count(iot.BulkSucceeded) != 2 {
+		t.Errorf("Failed to delete devices: %v", err)
+	}
+}
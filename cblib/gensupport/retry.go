@@ -89,6 +89,17 @@ func shouldRetry(status int, err error) bool {
 type RetryConfig struct {
 	Backoff     *gax.Backoff
 	ShouldRetry func(err error) bool
+	// MaxAttempts limits the total number of attempts, including the first
+	// one. Zero means the request is retried until the context is done.
+	MaxAttempts int
+}
+
+// maxAttempts returns the attempt limit for r, or 0 if there is none.
+func (r *RetryConfig) maxAttempts() int {
+	if r == nil {
+		return 0
+	}
+	return r.MaxAttempts
 }
 
 // This is kind of hacky; it is necessary because ShouldRetry expects to
@@ -149,16 +149,25 @@ func sendAndRetry(ctx context.Context, client *http.Client, req *http.Request, r
 
 		// Check if we can retry the request. A retry can only be done if the error
 		// is retryable and the request body can be re-created using GetBody (this
-		// will not be possible if the body was unbuffered).
-		if req.GetBody == nil || !errorFunc(status, err) {
+		// will not be possible if the body was unbuffered). Requests without a
+		// body, such as GET and DELETE, can always be resent.
+		if !errorFunc(status, err) {
 			break
 		}
-		attempts++
-		var errBody error
-		req.Body, errBody = req.GetBody()
-		if errBody != nil {
+		if max := retry.maxAttempts(); max > 0 && attempts >= max {
 			break
 		}
+		if req.Body != nil && req.Body != http.NoBody {
+			if req.GetBody == nil {
+				break
+			}
+			var errBody error
+			req.Body, errBody = req.GetBody()
+			if errBody != nil {
+				break
+			}
+		}
+		attempts++
 
 		pause = bo.Pause()
 		if resp != nil && resp.Body != nil {
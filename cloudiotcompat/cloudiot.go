@@ -0,0 +1,151 @@
+// Copyright 2023 ClearBlade Inc.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package cloudiot is a drop-in replacement for the Google Cloud IoT client
+// google.golang.org/api/cloudiot/v1 that talks to ClearBlade IoT Core.
+// Code written against the Google client only needs its import path
+// changed:
+//
+//	import cloudiot "github.com/clearblade/go-iot/cloudiotcompat"
+//	...
+//	service, err := cloudiot.NewService(ctx, option.WithCredentialsFile("clearblade.json"))
+//
+// The types are aliases of the types of package iot, so a Service can also
+// be used with the other packages of this module.
+package cloudiot
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	iot "github.com/clearblade/go-iot"
+	"google.golang.org/api/option"
+)
+
+// OAuth2 scopes of the Google client. They are accepted and ignored.
+const (
+	CloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+	CloudiotScope      = "https://www.googleapis.com/auth/cloudiot"
+)
+
+// Services.
+type (
+	Service                                                 = iot.Service
+	ProjectsService                                         = iot.ProjectsService
+	ProjectsLocationsService                                = iot.ProjectsLocationsService
+	ProjectsLocationsRegistriesService                      = iot.ProjectsLocationsRegistriesService
+	ProjectsLocationsRegistriesDevicesService               = iot.ProjectsLocationsRegistriesDevicesService
+	ProjectsLocationsRegistriesDevicesConfigVersionsService = iot.ProjectsLocationsRegistriesDevicesConfigVersionsService
+	ProjectsLocationsRegistriesDevicesStatesService         = iot.ProjectsLocationsRegistriesDevicesStatesService
+	ProjectsLocationsRegistriesGroupsService                = iot.ProjectsLocationsRegistriesGroupsService
+	ProjectsLocationsRegistriesGroupsDevicesService         = iot.ProjectsLocationsRegistriesGroupsDevicesService
+)
+
+// Resources and messages.
+type (
+	BindDeviceToGatewayRequest       = iot.BindDeviceToGatewayRequest
+	BindDeviceToGatewayResponse      = iot.BindDeviceToGatewayResponse
+	Binding                          = iot.Binding
+	Device                           = iot.Device
+	DeviceConfig                     = iot.DeviceConfig
+	DeviceCredential                 = iot.DeviceCredential
+	DeviceRegistry                   = iot.DeviceRegistry
+	DeviceState                      = iot.DeviceState
+	Empty                            = iot.Empty
+	EventNotificationConfig          = iot.EventNotificationConfig
+	Expr                             = iot.Expr
+	GatewayConfig                    = iot.GatewayConfig
+	GetIamPolicyRequest              = iot.GetIamPolicyRequest
+	GetPolicyOptions                 = iot.GetPolicyOptions
+	HttpConfig                       = iot.HttpConfig
+	ListDeviceConfigVersionsResponse = iot.ListDeviceConfigVersionsResponse
+	ListDeviceRegistriesResponse     = iot.ListDeviceRegistriesResponse
+	ListDeviceStatesResponse         = iot.ListDeviceStatesResponse
+	ListDevicesResponse              = iot.ListDevicesResponse
+	ModifyCloudToDeviceConfigRequest = iot.ModifyCloudToDeviceConfigRequest
+	MqttConfig                       = iot.MqttConfig
+	Policy                           = iot.Policy
+	PublicKeyCertificate             = iot.PublicKeyCertificate
+	PublicKeyCredential              = iot.PublicKeyCredential
+	RegistryCredential               = iot.RegistryCredential
+	SendCommandToDeviceRequest       = iot.SendCommandToDeviceRequest
+	SendCommandToDeviceResponse      = iot.SendCommandToDeviceResponse
+	SetIamPolicyRequest              = iot.SetIamPolicyRequest
+	StateNotificationConfig          = iot.StateNotificationConfig
+	Status                           = iot.Status
+	TestIamPermissionsRequest        = iot.TestIamPermissionsRequest
+	TestIamPermissionsResponse       = iot.TestIamPermissionsResponse
+	UnbindDeviceFromGatewayRequest   = iot.UnbindDeviceFromGatewayRequest
+	UnbindDeviceFromGatewayResponse  = iot.UnbindDeviceFromGatewayResponse
+	X509CertificateDetails           = iot.X509CertificateDetails
+)
+
+// Calls.
+type (
+	ProjectsLocationsRegistriesBindDeviceToGatewayCall              = iot.ProjectsLocationsRegistriesBindDeviceToGatewayCall
+	ProjectsLocationsRegistriesCreateCall                           = iot.ProjectsLocationsRegistriesCreateCall
+	ProjectsLocationsRegistriesDeleteCall                           = iot.ProjectsLocationsRegistriesDeleteCall
+	ProjectsLocationsRegistriesGetCall                              = iot.ProjectsLocationsRegistriesGetCall
+	ProjectsLocationsRegistriesGetIamPolicyCall                     = iot.ProjectsLocationsRegistriesGetIamPolicyCall
+	ProjectsLocationsRegistriesListCall                             = iot.ProjectsLocationsRegistriesListCall
+	ProjectsLocationsRegistriesPatchCall                            = iot.ProjectsLocationsRegistriesPatchCall
+	ProjectsLocationsRegistriesSetIamPolicyCall                     = iot.ProjectsLocationsRegistriesSetIamPolicyCall
+	ProjectsLocationsRegistriesTestIamPermissionsCall               = iot.ProjectsLocationsRegistriesTestIamPermissionsCall
+	ProjectsLocationsRegistriesUnbindDeviceFromGatewayCall          = iot.ProjectsLocationsRegistriesUnbindDeviceFromGatewayCall
+	ProjectsLocationsRegistriesDevicesCreateCall                    = iot.ProjectsLocationsRegistriesDevicesCreateCall
+	ProjectsLocationsRegistriesDevicesDeleteCall                    = iot.ProjectsLocationsRegistriesDevicesDeleteCall
+	ProjectsLocationsRegistriesDevicesGetCall                       = iot.ProjectsLocationsRegistriesDevicesGetCall
+	ProjectsLocationsRegistriesDevicesListCall                      = iot.ProjectsLocationsRegistriesDevicesListCall
+	ProjectsLocationsRegistriesDevicesModifyCloudToDeviceConfigCall = iot.ProjectsLocationsRegistriesDevicesModifyCloudToDeviceConfigCall
+	ProjectsLocationsRegistriesDevicesPatchCall                     = iot.ProjectsLocationsRegistriesDevicesPatchCall
+	ProjectsLocationsRegistriesDevicesSendCommandToDeviceCall       = iot.ProjectsLocationsRegistriesDevicesSendCommandToDeviceCall
+	ProjectsLocationsRegistriesDevicesConfigVersionsListCall        = iot.ProjectsLocationsRegistriesDevicesConfigVersionsListCall
+	ProjectsLocationsRegistriesDevicesStatesListCall                = iot.ProjectsLocationsRegistriesDevicesStatesListCall
+	ProjectsLocationsRegistriesGroupsGetIamPolicyCall               = iot.ProjectsLocationsRegistriesGroupsGetIamPolicyCall
+	ProjectsLocationsRegistriesGroupsSetIamPolicyCall               = iot.ProjectsLocationsRegistriesGroupsSetIamPolicyCall
+	ProjectsLocationsRegistriesGroupsTestIamPermissionsCall         = iot.ProjectsLocationsRegistriesGroupsTestIamPermissionsCall
+	ProjectsLocationsRegistriesGroupsDevicesListCall                = iot.ProjectsLocationsRegistriesGroupsDevicesListCall
+)
+
+// Constructors of the services of a Service.
+var (
+	NewProjectsService                                         = iot.NewProjectsService
+	NewProjectsLocationsService                                = iot.NewProjectsLocationsService
+	NewProjectsLocationsRegistriesService                      = iot.NewProjectsLocationsRegistriesService
+	NewProjectsLocationsRegistriesDevicesService               = iot.NewProjectsLocationsRegistriesDevicesService
+	NewProjectsLocationsRegistriesDevicesConfigVersionsService = iot.NewProjectsLocationsRegistriesDevicesConfigVersionsService
+	NewProjectsLocationsRegistriesDevicesStatesService         = iot.NewProjectsLocationsRegistriesDevicesStatesService
+	NewProjectsLocationsRegistriesGroupsService                = iot.NewProjectsLocationsRegistriesGroupsService
+	NewProjectsLocationsRegistriesGroupsDevicesService         = iot.NewProjectsLocationsRegistriesGroupsDevicesService
+)
+
+// NewService creates a new Service. Options that select credentials, such
+// as option.WithCredentialsFile and option.WithCredentialsJSON, take
+// ClearBlade service account credentials. Without them, the credentials
+// are read from the file named by the CLEARBLADE_CONFIGURATION environment
+// variable.
+//
+// Options that have no ClearBlade equivalent, such as scopes and quota
+// projects, are ignored. Options that would change how requests are
+// authenticated, such as option.WithTokenSource and option.WithAPIKey,
+// return an error.
+func NewService(ctx context.Context, opts ...option.ClientOption) (*Service, error) {
+	serviceOpts, err := serviceOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	return iot.NewService(ctx, serviceOpts...)
+}
+
+// New creates a new Service that sends requests with client.
+//
+// Deprecated: please use NewService instead.
+// To provide a custom HTTP client, use option.WithHTTPClient.
+func New(client *http.Client) (*Service, error) {
+	if client == nil {
+		return nil, errors.New("client is nil")
+	}
+	return iot.NewService(context.Background(), iot.WithHTTPClient(client))
+}
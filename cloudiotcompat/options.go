@@ -0,0 +1,76 @@
+// Copyright 2023 ClearBlade Inc.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cloudiot
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+
+	iot "github.com/clearblade/go-iot"
+	"google.golang.org/api/option"
+)
+
+// unsupportedSettings are the client settings that would change how
+// requests are authenticated, by the options that set them.
+var unsupportedSettings = []struct{ field, option string }{
+	{"TokenSource", "option.WithTokenSource"},
+	{"APIKey", "option.WithAPIKey"},
+	{"ClientCertSource", "option.WithClientCertSource"},
+	{"ImpersonationConfig", "impersonated credentials"},
+	{"GRPCConn", "option.WithGRPCConn"},
+}
+
+// serviceOptions maps Google client options onto ClearBlade service options.
+//
+// A ClientOption applies itself to a settings struct internal to the Google
+// API module, so the options are applied to a new value of that type
+// through reflection and its exported fields read back by name.
+func serviceOptions(opts []option.ClientOption) ([]iot.ServiceOption, error) {
+	if len(opts) == 0 {
+		return nil, nil
+	}
+	apply, _ := reflect.TypeOf((*option.ClientOption)(nil)).Elem().MethodByName("Apply")
+	settings := reflect.New(apply.Type.In(0).Elem())
+	for _, o := range opts {
+		reflect.ValueOf(o).MethodByName("Apply").Call([]reflect.Value{settings})
+	}
+	field := func(name string) reflect.Value {
+		return settings.Elem().FieldByName(name)
+	}
+	isSet := func(name string) bool {
+		v := field(name)
+		return v.IsValid() && !v.IsZero()
+	}
+
+	for _, s := range unsupportedSettings {
+		if isSet(s.field) {
+			return nil, fmt.Errorf("cloudiot: %s is not supported by ClearBlade IoT Core", s.option)
+		}
+	}
+
+	var serviceOpts []iot.ServiceOption
+	if isSet("HTTPClient") {
+		serviceOpts = append(serviceOpts, iot.WithHTTPClient(field("HTTPClient").Interface().(*http.Client)))
+	}
+	if isSet("UserAgent") {
+		serviceOpts = append(serviceOpts, iot.WithDefaultHeaders(http.Header{"User-Agent": {field("UserAgent").String()}}))
+	}
+	switch {
+	case isSet("CredentialsJSON"):
+		serviceOpts = append(serviceOpts, iot.WithServiceAccountCredentials(string(field("CredentialsJSON").Bytes())))
+	case isSet("CredentialsFile"):
+		serviceOpts = append(serviceOpts, iot.WithCredentialsProvider(iot.NewFileCredentialsProvider(field("CredentialsFile").String())))
+	case isSet("Credentials"):
+		// option.WithCredentials passes parsed Google credentials; their
+		// JSON holds the ClearBlade credentials.
+		data := field("Credentials").Elem().FieldByName("JSON")
+		if !data.IsValid() || data.Len() == 0 {
+			return nil, fmt.Errorf("cloudiot: option.WithCredentials requires credentials with JSON")
+		}
+		serviceOpts = append(serviceOpts, iot.WithServiceAccountCredentials(string(data.Bytes())))
+	}
+	return serviceOpts, nil
+}
@@ -0,0 +1,175 @@
+package cloudiot_test
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	cloudiot "github.com/clearblade/go-iot/cloudiotcompat"
+	"github.com/clearblade/go-iot/iottest"
+	"google.golang.org/api/option"
+)
+
+// The functions below are written the way the Cloud IoT Core samples for
+// google.golang.org/api/cloudiot/v1 are, with only the import changed.
+
+func createRegistry(w io.Writer, projectID string, region string, registryID string, topicName string) (*cloudiot.DeviceRegistry, error) {
+	ctx := context.Background()
+	client, err := cloudiot.NewService(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	registry := cloudiot.DeviceRegistry{
+		Id: registryID,
+		EventNotificationConfigs: []*cloudiot.EventNotificationConfig{
+			{
+				PubsubTopicName: topicName,
+			},
+		},
+	}
+
+	parent := fmt.Sprintf("projects/%s/locations/%s", projectID, region)
+	response, err := client.Projects.Locations.Registries.Create(parent, &registry).Do()
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintf(w, "Created registry: %s\n", response.Id)
+	return response, nil
+}
+
+func createUnauth(w io.Writer, projectID string, region string, registryID string, deviceID string) (*cloudiot.Device, error) {
+	ctx := context.Background()
+	client, err := cloudiot.NewService(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	device := cloudiot.Device{
+		Id: deviceID,
+	}
+
+	parent := fmt.Sprintf("projects/%s/locations/%s/registries/%s", projectID, region, registryID)
+	response, err := client.Projects.Locations.Registries.Devices.Create(parent, &device).Do()
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintf(w, "Successfully created device: %s\n", response.Id)
+	return response, nil
+}
+
+func setConfig(w io.Writer, projectID string, region string, registryID string, deviceID string, configData string) (*cloudiot.DeviceConfig, error) {
+	ctx := context.Background()
+	client, err := cloudiot.NewService(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	req := cloudiot.ModifyCloudToDeviceConfigRequest{
+		BinaryData: base64.StdEncoding.EncodeToString([]byte(configData)),
+	}
+
+	path := fmt.Sprintf("projects/%s/locations/%s/registries/%s/devices/%s", projectID, region, registryID, deviceID)
+	response, err := client.Projects.Locations.Registries.Devices.ModifyCloudToDeviceConfig(path, &req).Do()
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintf(w, "Config set!\nVersion now: %d\n", response.Version)
+	return response, nil
+}
+
+func listDevices(w io.Writer, projectID string, region string, registryID string) ([]*cloudiot.Device, error) {
+	ctx := context.Background()
+	client, err := cloudiot.NewService(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	parent := fmt.Sprintf("projects/%s/locations/%s/registries/%s", projectID, region, registryID)
+	response, err := client.Projects.Locations.Registries.Devices.List(parent).Do()
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintln(w, "Devices:")
+	for _, device := range response.Devices {
+		fmt.Fprintf(w, "\t%s\n", device.Id)
+	}
+	return response.Devices, nil
+}
+
+func getDeviceConfigs(w io.Writer, projectID string, region string, registryID string, deviceID string) ([]*cloudiot.DeviceConfig, error) {
+	ctx := context.Background()
+	client, err := cloudiot.NewService(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	path := fmt.Sprintf("projects/%s/locations/%s/registries/%s/devices/%s", projectID, region, registryID, deviceID)
+	response, err := client.Projects.Locations.Registries.Devices.ConfigVersions.List(path).Do()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, config := range response.DeviceConfigs {
+		fmt.Fprintf(w, "%d : %s\n", config.Version, config.BinaryData)
+	}
+	return response.DeviceConfigs, nil
+}
+
+func TestSamples(t *testing.T) {
+	fake := iottest.NewServer("test-project")
+	t.Cleanup(fake.Close)
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	if err := os.WriteFile(path, []byte(fake.Credentials()), 0o600); err != nil {
+		t.Fatalf("Failed to write credentials: %s", err.Error())
+	}
+	t.Setenv("CLEARBLADE_CONFIGURATION", path)
+
+	if _, err := createRegistry(io.Discard, "test-project", "us-central1", "registry", "projects/test-project/topics/events"); err != nil {
+		t.Fatalf("Failed to create registry: %s", err.Error())
+	}
+	if _, err := createUnauth(io.Discard, "test-project", "us-central1", "registry", "device"); err != nil {
+		t.Fatalf("Failed to create device: %s", err.Error())
+	}
+	if _, err := setConfig(io.Discard, "test-project", "us-central1", "registry", "device", "on"); err != nil {
+		t.Fatalf("Failed to set config: %s", err.Error())
+	}
+	devices, err := listDevices(io.Discard, "test-project", "us-central1", "registry")
+	if err != nil || len(devices) != 1 {
+		t.Fatalf("Expected 1 device but got: %d (%v)", len(devices), err)
+	}
+	configs, err := getDeviceConfigs(io.Discard, "test-project", "us-central1", "registry", "device")
+	if err != nil || len(configs) != 2 {
+		t.Fatalf("Expected 2 config versions but got: %d (%v)", len(configs), err)
+	}
+}
+
+func TestNewServiceOptions(t *testing.T) {
+	fake := iottest.NewServer("test-project")
+	t.Cleanup(fake.Close)
+	ctx := context.Background()
+
+	service, err := cloudiot.NewService(ctx,
+		option.WithCredentialsJSON([]byte(fake.Credentials())),
+		option.WithScopes(cloudiot.CloudPlatformScope),
+		option.WithUserAgent("compat-test"),
+	)
+	if err != nil {
+		t.Fatalf("Failed to initialize service: %s", err.Error())
+	}
+	if _, err := service.Projects.Locations.Registries.List("projects/test-project/locations/us-central1").Do(); err != nil {
+		t.Errorf("Failed to list registries: %s", err.Error())
+	}
+
+	if _, err := cloudiot.NewService(ctx, option.WithAPIKey("key")); err == nil {
+		t.Errorf("Expected option.WithAPIKey to be rejected")
+	}
+}
@@ -0,0 +1,186 @@
+// Copyright 2023 ClearBlade Inc.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Environment variables read by the provider returned by
+// NewEnvCredentialsProvider.
+const (
+	EnvSystemKey = "CLEARBLADE_SYSTEM_KEY"
+	EnvToken     = "CLEARBLADE_TOKEN"
+	EnvUrl       = "CLEARBLADE_URL"
+	EnvProject   = "CLEARBLADE_PROJECT"
+)
+
+// CredentialsProvider supplies the service account credentials of a Service.
+// It is consulted before every request that uses them, so implementations
+// can pick up rotated tokens without the Service being rebuilt, and must be
+// safe for concurrent use.
+type CredentialsProvider interface {
+	Credentials(ctx context.Context) (*ServiceAccountCredentials, error)
+}
+
+// CredentialsProviderFunc adapts an ordinary function, such as a call to a
+// local secret agent, to a CredentialsProvider.
+type CredentialsProviderFunc func(ctx context.Context) (*ServiceAccountCredentials, error)
+
+// Credentials calls f(ctx).
+func (f CredentialsProviderFunc) Credentials(ctx context.Context) (*ServiceAccountCredentials, error) {
+	return f(ctx)
+}
+
+// WithCredentialsProvider makes the service obtain its service account
+// credentials from p before every request. It takes precedence over
+// Service.ServiceAccountCredentials.
+func WithCredentialsProvider(p CredentialsProvider) ServiceOption {
+	return func(s *Service) error {
+		if p == nil {
+			return fmt.Errorf("credentials provider cannot be nil")
+		}
+		s.credentialsProvider = p
+		return nil
+	}
+}
+
+// serviceAccountCredentials returns the credentials to use for a request,
+// from the configured provider if there is one.
+func (s *Service) serviceAccountCredentials(ctx context.Context) (*ServiceAccountCredentials, error) {
+	if s.credentialsProvider == nil {
+		if s.ServiceAccountCredentials == nil {
+			return nil, errors.New("no service account credentials configured")
+		}
+		return s.ServiceAccountCredentials, nil
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	credentials, err := s.credentialsProvider.Credentials(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get service account credentials: %w", err)
+	}
+	return credentials, nil
+}
+
+type staticCredentialsProvider struct {
+	credentials *ServiceAccountCredentials
+}
+
+// NewStaticCredentialsProvider returns a provider that always returns the
+// given credentials.
+func NewStaticCredentialsProvider(credentials *ServiceAccountCredentials) CredentialsProvider {
+	return &staticCredentialsProvider{credentials: credentials}
+}
+
+func (p *staticCredentialsProvider) Credentials(ctx context.Context) (*ServiceAccountCredentials, error) {
+	if p.credentials == nil {
+		return nil, errors.New("no static credentials")
+	}
+	return p.credentials, nil
+}
+
+type envCredentialsProvider struct{}
+
+// NewEnvCredentialsProvider returns a provider that reads the credentials
+// from the CLEARBLADE_SYSTEM_KEY, CLEARBLADE_TOKEN, CLEARBLADE_URL and
+// CLEARBLADE_PROJECT environment variables on every call.
+func NewEnvCredentialsProvider() CredentialsProvider {
+	return envCredentialsProvider{}
+}
+
+func (envCredentialsProvider) Credentials(ctx context.Context) (*ServiceAccountCredentials, error) {
+	credentials := &ServiceAccountCredentials{
+		SystemKey: os.Getenv(EnvSystemKey),
+		Token:     os.Getenv(EnvToken),
+		Url:       os.Getenv(EnvUrl),
+		Project:   os.Getenv(EnvProject),
+	}
+	if credentials.SystemKey == "" || credentials.Token == "" || credentials.Url == "" {
+		return nil, fmt.Errorf("%s, %s and %s must be set", EnvSystemKey, EnvToken, EnvUrl)
+	}
+	return credentials, nil
+}
+
+type fileCredentialsProvider struct {
+	path string
+
+	mu          sync.Mutex
+	modTime     time.Time
+	size        int64
+	credentials *ServiceAccountCredentials
+}
+
+// NewFileCredentialsProvider returns a provider that reads the credentials
+// from a JSON file in the format of CLEARBLADE_CONFIGURATION. The file is
+// read again whenever its modification time or size changes, so rotated
+// secrets mounted from disk are picked up on the next request.
+func NewFileCredentialsProvider(path string) CredentialsProvider {
+	return &fileCredentialsProvider{path: path}
+}
+
+func (p *fileCredentialsProvider) Credentials(ctx context.Context) (*ServiceAccountCredentials, error) {
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.credentials != nil && info.ModTime().Equal(p.modTime) && info.Size() == p.size {
+		return p.credentials, nil
+	}
+	credentials, err := readServiceAccountCredentials(p.path)
+	if err != nil {
+		return nil, err
+	}
+	p.credentials, p.modTime, p.size = credentials, info.ModTime(), info.Size()
+	return credentials, nil
+}
+
+// readServiceAccountCredentials parses a service account credentials file.
+func readServiceAccountCredentials(path string) (*ServiceAccountCredentials, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var credentials ServiceAccountCredentials
+	if err := json.Unmarshal(b, &credentials); err != nil {
+		return nil, errors.New("File loaded from " + path + " is invalid. Please make sure it is a json file with the properties systemKey, token, url, and project")
+	}
+	return &credentials, nil
+}
+
+type chainCredentialsProvider struct {
+	providers []CredentialsProvider
+}
+
+// NewChainCredentialsProvider returns a provider that tries each of the given
+// providers in order and returns the first credentials obtained.
+func NewChainCredentialsProvider(providers ...CredentialsProvider) CredentialsProvider {
+	return &chainCredentialsProvider{providers: providers}
+}
+
+func (p *chainCredentialsProvider) Credentials(ctx context.Context) (*ServiceAccountCredentials, error) {
+	var errs []error
+	for _, provider := range p.providers {
+		credentials, err := provider.Credentials(ctx)
+		if err == nil {
+			return credentials, nil
+		}
+		errs = append(errs, err)
+	}
+	if len(errs) == 0 {
+		return nil, errors.New("no credentials providers configured")
+	}
+	return nil, errors.Join(errs...)
+}
@@ -0,0 +1,166 @@
+// Copyright 2023 ClearBlade Inc.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package credentials creates and checks the credentials devices
+// authenticate with: key pairs, optionally wrapped in X.509 certificates,
+// ready to be set on a Device.
+//
+//	privateKey, cred, err := credentials.Generate(credentials.Options{Algorithm: credentials.ES256})
+//	...
+//	device := &iot.Device{Id: "my-device", Credentials: []*iot.DeviceCredential{cred}}
+package credentials
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	iot "github.com/clearblade/go-iot"
+)
+
+// Algorithm is the signing algorithm of a key pair.
+type Algorithm string
+
+const (
+	// RS256 keys are RSA-2048 keys.
+	RS256 Algorithm = "RS256"
+	// ES256 keys are ECDSA keys on the P-256 curve.
+	ES256 Algorithm = "ES256"
+)
+
+// DefaultLifetime is how long generated credentials are valid by default.
+const DefaultLifetime = 365 * 24 * time.Hour
+
+// rsaKeySize is the size of generated RSA keys.
+const rsaKeySize = 2048
+
+// Options configures Generate.
+type Options struct {
+	// Algorithm is the algorithm of the key pair. The default is ES256.
+	Algorithm Algorithm
+
+	// Certificate wraps the public key in an X.509 certificate, producing
+	// an RSA_X509_PEM or ES256_X509_PEM credential instead of an RSA_PEM
+	// or ES256_PEM one. The certificate is self-signed unless Issuer is set.
+	Certificate bool
+
+	// Issuer and IssuerKey, if set, are the CA certificate and its private
+	// key that sign the certificate. A registry with CA credentials only
+	// accepts device certificates signed by one of them.
+	Issuer    *x509.Certificate
+	IssuerKey crypto.Signer
+
+	// Subject is the subject of the certificate. The default has the common
+	// name "unused", which IoT Core does not check.
+	Subject pkix.Name
+
+	// Lifetime is how long the credential is valid. It sets the
+	// ExpirationTime of the credential and the validity of the certificate.
+	// The default is DefaultLifetime.
+	Lifetime time.Duration
+}
+
+// Generate generates a key pair and returns the PEM encoded PKCS #8 private
+// key along with a credential for the public key, with its Format and
+// ExpirationTime set.
+func Generate(opts Options) (privateKey []byte, cred *iot.DeviceCredential, err error) {
+	if opts.Algorithm == "" {
+		opts.Algorithm = ES256
+	}
+	if opts.Lifetime <= 0 {
+		opts.Lifetime = DefaultLifetime
+	}
+	if opts.Issuer != nil || opts.IssuerKey != nil {
+		if opts.Issuer == nil || opts.IssuerKey == nil {
+			return nil, nil, errors.New("credentials: Issuer and IssuerKey must be set together")
+		}
+		opts.Certificate = true
+	}
+
+	var key crypto.Signer
+	var format string
+	switch opts.Algorithm {
+	case RS256:
+		key, err = rsa.GenerateKey(rand.Reader, rsaKeySize)
+		format = "RSA"
+	case ES256:
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		format = "ES256"
+	default:
+		return nil, nil, fmt.Errorf("credentials: unsupported algorithm %q", opts.Algorithm)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("credentials: failed to generate key: %w", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	privateKey = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+	now := time.Now().UTC().Truncate(time.Second)
+	expires := now.Add(opts.Lifetime)
+	var block *pem.Block
+	if opts.Certificate {
+		format += "_X509_PEM"
+		if block, err = certificate(key, opts, now, expires); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		format += "_PEM"
+		der, err := x509.MarshalPKIXPublicKey(key.Public())
+		if err != nil {
+			return nil, nil, err
+		}
+		block = &pem.Block{Type: "PUBLIC KEY", Bytes: der}
+	}
+	cred = &iot.DeviceCredential{
+		ExpirationTime: expires.Format(time.RFC3339),
+		PublicKey: &iot.PublicKeyCredential{
+			Format: format,
+			Key:    string(pem.EncodeToMemory(block)),
+		},
+	}
+	return privateKey, cred, nil
+}
+
+// certificate returns a certificate for the public key of key, valid from
+// notBefore until notAfter.
+func certificate(key crypto.Signer, opts Options, notBefore, notAfter time.Time) (*pem.Block, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+	subject := opts.Subject
+	if subject.CommonName == "" {
+		subject.CommonName = "unused"
+	}
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               subject,
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+	}
+	parent, signer := template, key
+	if opts.Issuer != nil {
+		parent, signer = opts.Issuer, opts.IssuerKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, key.Public(), signer)
+	if err != nil {
+		return nil, fmt.Errorf("credentials: failed to create certificate: %w", err)
+	}
+	return &pem.Block{Type: "CERTIFICATE", Bytes: der}, nil
+}
@@ -0,0 +1,105 @@
+package credentials_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	iot "github.com/clearblade/go-iot"
+	"github.com/clearblade/go-iot/credentials"
+	"github.com/clearblade/go-iot/iottest"
+	"github.com/clearblade/go-iot/jwtauth"
+)
+
+var registry = iot.LocationName{Project: "test-project", Location: "us-central1"}.Registry("registry")
+
+// newCA returns a self-signed CA certificate and its key.
+func newCA(t *testing.T) (*x509.Certificate, *ecdsa.PrivateKey, []byte) {
+	t.Helper()
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(10 * 365 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatalf("Failed to create CA certificate: %s", err.Error())
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return cert, key, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestGenerate(t *testing.T) {
+	_, service := iottest.NewService(t, "test-project")
+	iottest.CreateRegistry(t, service, registry)
+	devices := service.Projects.Locations.Registries.Devices
+	ca, caKey, _ := newCA(t)
+
+	for i, test := range []struct {
+		opts   credentials.Options
+		format string
+	}{
+		{credentials.Options{}, "ES256_PEM"},
+		{credentials.Options{Algorithm: credentials.RS256}, "RSA_PEM"},
+		{credentials.Options{Algorithm: credentials.ES256, Certificate: true}, "ES256_X509_PEM"},
+		{credentials.Options{Algorithm: credentials.RS256, Certificate: true}, "RSA_X509_PEM"},
+		{credentials.Options{Issuer: ca, IssuerKey: caKey, Lifetime: 24 * time.Hour}, "ES256_X509_PEM"},
+	} {
+		privateKey, cred, err := credentials.Generate(test.opts)
+		if err != nil {
+			t.Fatalf("%s: failed to generate credential: %s", test.format, err.Error())
+		}
+		if cred.PublicKey.Format != test.format {
+			t.Errorf("Expected format %s but got: %s", test.format, cred.PublicKey.Format)
+		}
+		expires, err := time.Parse(time.RFC3339, cred.ExpirationTime)
+		lifetime := test.opts.Lifetime
+		if lifetime == 0 {
+			lifetime = credentials.DefaultLifetime
+		}
+		if err != nil || time.Until(expires) > lifetime || time.Until(expires) < lifetime-time.Minute {
+			t.Errorf("%s: expected the credential to expire in %s but got: %s", test.format, lifetime, cred.ExpirationTime)
+		}
+
+		id := "device-" + string(rune('a'+i))
+		if _, err := devices.CreateIn(registry, &iot.Device{Id: id, Credentials: []*iot.DeviceCredential{cred}}).Do(); err != nil {
+			t.Fatalf("%s: failed to create device: %s", test.format, err.Error())
+		}
+		device, err := devices.GetByName(registry.Device(id)).Do()
+		if err != nil {
+			t.Fatalf("%s: failed to get device: %s", test.format, err.Error())
+		}
+		key, err := jwtauth.ParsePrivateKey(privateKey)
+		if err != nil {
+			t.Fatalf("%s: failed to parse private key: %s", test.format, err.Error())
+		}
+		token, _ := jwtauth.NewToken(key, "test-project", time.Hour)
+		if _, err := jwtauth.Verify(token, "test-project", device.Credentials); err != nil {
+			t.Errorf("%s: failed to verify token: %s", test.format, err.Error())
+		}
+	}
+
+	_, cred, _ := credentials.Generate(credentials.Options{Issuer: ca, IssuerKey: caKey})
+	block, _ := pem.Decode([]byte(cred.PublicKey.Key))
+	cert, _ := x509.ParseCertificate(block.Bytes)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	if _, err := cert.Verify(x509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}}); err != nil {
+		t.Errorf("Expected the certificate to chain to the CA: %s", err.Error())
+	}
+
+	if _, _, err := credentials.Generate(credentials.Options{Issuer: ca}); err == nil {
+		t.Errorf("Expected an error for an issuer without a key")
+	}
+}
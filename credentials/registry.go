@@ -0,0 +1,170 @@
+// Copyright 2023 ClearBlade Inc.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package credentials
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	iot "github.com/clearblade/go-iot"
+	"github.com/clearblade/go-iot/jwtauth"
+)
+
+var (
+	// ErrCertificateRequired is returned for device credentials that are
+	// not X.509 certificates, in a registry with CA certificates.
+	ErrCertificateRequired = errors.New("credentials: registry requires X.509 device certificates")
+	// ErrUntrustedCertificate is returned for device certificates that are
+	// not signed by any of the CA certificates of the registry.
+	ErrUntrustedCertificate = errors.New("credentials: certificate is not signed by a registry CA")
+	// ErrCertificateExpired is returned for certificates outside of their
+	// validity period.
+	ErrCertificateExpired = errors.New("credentials: certificate expired or not yet valid")
+	// ErrDuplicateCertificate is returned for device certificates that are
+	// also registry CA certificates.
+	ErrDuplicateCertificate = errors.New("credentials: device certificate is a registry certificate")
+)
+
+// ParseCertificate parses a PEM encoded X.509 certificate.
+func ParseCertificate(pemData string) (*x509.Certificate, error) {
+	block, _ := pem.Decode([]byte(pemData))
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.New("credentials: no PEM encoded certificate found")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("credentials: invalid certificate: %w", err)
+	}
+	return cert, nil
+}
+
+// Details returns the X509CertificateDetails the server reports for cert.
+func Details(cert *x509.Certificate) *iot.X509CertificateDetails {
+	return &iot.X509CertificateDetails{
+		Issuer:             cert.Issuer.String(),
+		Subject:            cert.Subject.String(),
+		StartTime:          cert.NotBefore.UTC().Format(time.RFC3339),
+		ExpiryTime:         cert.NotAfter.UTC().Format(time.RFC3339),
+		PublicKeyType:      cert.PublicKeyAlgorithm.String(),
+		SignatureAlgorithm: cert.SignatureAlgorithm.String(),
+	}
+}
+
+// NewRegistryCredential returns a registry credential for a PEM encoded CA
+// certificate, with its X509Details populated.
+func NewRegistryCredential(certPEM []byte) (*iot.RegistryCredential, error) {
+	cert, err := ParseCertificate(string(certPEM))
+	if err != nil {
+		return nil, err
+	}
+	if !cert.IsCA {
+		return nil, fmt.Errorf("credentials: certificate of %s is not a CA certificate", cert.Subject)
+	}
+	return &iot.RegistryCredential{
+		PublicKeyCertificate: &iot.PublicKeyCertificate{
+			Format:      "X509_CERTIFICATE_PEM",
+			Certificate: string(certPEM),
+			X509Details: Details(cert),
+		},
+	}, nil
+}
+
+// RegistryCAs parses the CA certificates of a registry, and populates their
+// X509Details.
+func RegistryCAs(registry *iot.DeviceRegistry) ([]*x509.Certificate, error) {
+	var cas []*x509.Certificate
+	for i, cred := range registry.Credentials {
+		if cred == nil || cred.PublicKeyCertificate == nil {
+			continue
+		}
+		c := cred.PublicKeyCertificate
+		if c.Format != "X509_CERTIFICATE_PEM" {
+			return nil, fmt.Errorf("credentials: registry credential %d has unsupported format %q", i, c.Format)
+		}
+		cert, err := ParseCertificate(c.Certificate)
+		if err != nil {
+			return nil, fmt.Errorf("registry credential %d: %w", i, err)
+		}
+		c.X509Details = Details(cert)
+		cas = append(cas, cert)
+	}
+	return cas, nil
+}
+
+// VerifyDevice checks the credentials of device as the server does when it
+// is created or its credentials are changed: if the registry has CA
+// certificates, every credential must be a certificate signed by one of them,
+// valid now, and not itself a registry certificate.
+func VerifyDevice(registry *iot.DeviceRegistry, device *iot.Device) error {
+	cas, err := RegistryCAs(registry)
+	if err != nil {
+		return err
+	}
+	for i, cred := range device.Credentials {
+		if err := verifyCredential(cas, cred, time.Now()); err != nil {
+			return fmt.Errorf("credential %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// VerifyCredential checks a single device credential against the CA
+// certificates of registry, as VerifyDevice does.
+func VerifyCredential(registry *iot.DeviceRegistry, cred *iot.DeviceCredential) error {
+	cas, err := RegistryCAs(registry)
+	if err != nil {
+		return err
+	}
+	return verifyCredential(cas, cred, time.Now())
+}
+
+func verifyCredential(cas []*x509.Certificate, cred *iot.DeviceCredential, now time.Time) error {
+	if cred == nil || cred.PublicKey == nil {
+		return errors.New("credentials: credential has no public key")
+	}
+	if _, err := jwtauth.ParsePublicKey(cred.PublicKey); err != nil {
+		return err
+	}
+	if len(cas) == 0 {
+		return nil
+	}
+	if !strings.HasSuffix(cred.PublicKey.Format, "_X509_PEM") {
+		return fmt.Errorf("%w, got %s", ErrCertificateRequired, cred.PublicKey.Format)
+	}
+	cert, err := ParseCertificate(cred.PublicKey.Key)
+	if err != nil {
+		return err
+	}
+	roots := x509.NewCertPool()
+	for _, ca := range cas {
+		if bytes.Equal(ca.Raw, cert.Raw) {
+			return ErrDuplicateCertificate
+		}
+		roots.AddCert(ca)
+	}
+	if now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+		return fmt.Errorf("%w: valid from %s until %s", ErrCertificateExpired,
+			cert.NotBefore.UTC().Format(time.RFC3339), cert.NotAfter.UTC().Format(time.RFC3339))
+	}
+	_, err = cert.Verify(x509.VerifyOptions{
+		Roots:       roots,
+		CurrentTime: now,
+		KeyUsages:   []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	var invalid x509.CertificateInvalidError
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &invalid) && invalid.Reason == x509.Expired:
+		// One of the CAs is outside of its validity period.
+		return fmt.Errorf("%w: %v", ErrCertificateExpired, err)
+	}
+	return fmt.Errorf("%w: issuer %s: %v", ErrUntrustedCertificate, cert.Issuer, err)
+}
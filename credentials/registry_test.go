@@ -0,0 +1,71 @@
+package credentials_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	iot "github.com/clearblade/go-iot"
+	"github.com/clearblade/go-iot/credentials"
+)
+
+func TestVerifyDevice(t *testing.T) {
+	ca, caKey, caPEM := newCA(t)
+	otherCA, otherKey, _ := newCA(t)
+
+	registryCred, err := credentials.NewRegistryCredential(caPEM)
+	if err != nil {
+		t.Fatalf("Failed to create registry credential: %s", err.Error())
+	}
+	details := registryCred.PublicKeyCertificate.X509Details
+	if details.Subject != "CN=test CA" || details.Issuer != "CN=test CA" || details.PublicKeyType != "ECDSA" || details.SignatureAlgorithm != "ECDSA-SHA256" {
+		t.Errorf("Unexpected certificate details: %+v", details)
+	}
+	if details.ExpiryTime != ca.NotAfter.UTC().Format(time.RFC3339) {
+		t.Errorf("Expected expiry time %s but got: %s", ca.NotAfter, details.ExpiryTime)
+	}
+
+	registry := &iot.DeviceRegistry{Credentials: []*iot.RegistryCredential{
+		{PublicKeyCertificate: &iot.PublicKeyCertificate{Format: "X509_CERTIFICATE_PEM", Certificate: string(caPEM)}},
+	}}
+	if _, err := credentials.RegistryCAs(registry); err != nil {
+		t.Fatalf("Failed to parse registry CAs: %s", err.Error())
+	}
+	if registry.Credentials[0].PublicKeyCertificate.X509Details.Subject != "CN=test CA" {
+		t.Errorf("Expected the registry certificate details to be populated")
+	}
+
+	_, signed, _ := credentials.Generate(credentials.Options{Issuer: ca, IssuerKey: caKey})
+	_, selfSigned, _ := credentials.Generate(credentials.Options{Certificate: true})
+	_, publicKey, _ := credentials.Generate(credentials.Options{})
+	_, otherSigned, _ := credentials.Generate(credentials.Options{Issuer: otherCA, IssuerKey: otherKey})
+	duplicate := &iot.DeviceCredential{PublicKey: &iot.PublicKeyCredential{Format: "ES256_X509_PEM", Key: string(caPEM)}}
+
+	for _, test := range []struct {
+		name string
+		cred *iot.DeviceCredential
+		want error
+	}{
+		{"signed", signed, nil},
+		{"self-signed", selfSigned, credentials.ErrUntrustedCertificate},
+		{"public key", publicKey, credentials.ErrCertificateRequired},
+		{"other CA", otherSigned, credentials.ErrUntrustedCertificate},
+		{"registry certificate", duplicate, credentials.ErrDuplicateCertificate},
+	} {
+		err := credentials.VerifyDevice(registry, &iot.Device{Credentials: []*iot.DeviceCredential{test.cred}})
+		if test.want == nil && err != nil {
+			t.Errorf("%s: failed to verify device: %s", test.name, err.Error())
+		}
+		if test.want != nil && !errors.Is(err, test.want) {
+			t.Errorf("%s: expected %v but got: %v", test.name, test.want, err)
+		}
+	}
+
+	// Without CA certificates, any valid credential is accepted.
+	if err := credentials.VerifyCredential(&iot.DeviceRegistry{}, publicKey); err != nil {
+		t.Errorf("Failed to verify credential: %s", err.Error())
+	}
+	if _, err := credentials.NewRegistryCredential([]byte(signed.PublicKey.Key)); err == nil {
+		t.Errorf("Expected an error for a certificate that is not a CA")
+	}
+}
@@ -0,0 +1,263 @@
+// Copyright 2023 ClearBlade Inc.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package credentials
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	iot "github.com/clearblade/go-iot"
+)
+
+const (
+	defaultRotationTimeout      = 10 * time.Minute
+	defaultRotationPollInterval = 10 * time.Second
+)
+
+// ErrDeviceNotReturned is returned by RotateDeviceCredential when the device
+// shows no activity after being told to switch keys. The rotation has been
+// rolled back.
+var ErrDeviceNotReturned = errors.New("credentials: device did not return after the key switch")
+
+// RotateDeviceOptions configures RotateDeviceCredential. At least one of
+// Config, Command and Notify must be set, to tell the device to switch keys.
+type RotateDeviceOptions struct {
+	// Credential is the new credential. If nil, one is generated with
+	// Options, and its private key is returned.
+	Credential *iot.DeviceCredential
+
+	// Options configures the generated key.
+	Options Options
+
+	// Config, if set, is sent to the device as its new configuration once
+	// the new credential has been added.
+	Config []byte
+
+	// Command and CommandSubfolder, if Command is set, are sent to the
+	// device as a command once the new credential has been added.
+	Command          []byte
+	CommandSubfolder string
+
+	// Notify, if set, is called once the new credential has been added. It
+	// can deliver the private key to the device by other means. privateKey
+	// is nil if Credential was set.
+	Notify func(ctx context.Context, privateKey []byte, cred *iot.DeviceCredential) error
+
+	// Replace reports whether an existing credential is removed once the
+	// device has switched. The default removes all of them.
+	Replace func(cred *iot.DeviceCredential) bool
+
+	// Timeout is how long to wait for the device to return after the switch
+	// before rolling back. The default is ten minutes.
+	Timeout time.Duration
+
+	// PollInterval is how often the device is fetched while waiting. The
+	// default is ten seconds.
+	PollInterval time.Duration
+}
+
+// DeviceRotation is the result of RotateDeviceCredential.
+type DeviceRotation struct {
+	// PrivateKey is the PEM encoded private key of a generated credential.
+	PrivateKey []byte
+	// Credential is the new credential.
+	Credential *iot.DeviceCredential
+	// ReturnedAt is the LastHeartbeatTime or LastEventTime that showed the
+	// device was back after the switch.
+	ReturnedAt time.Time
+}
+
+// RotateDeviceCredential replaces the credentials of a device without
+// locking it out. It adds the new credential next to the existing ones,
+// tells the device to switch, and waits until its LastHeartbeatTime or
+// LastEventTime shows it connected after the switch. Only then are the old
+// credentials removed. If the device does not return within the timeout, or
+// ctx is done first, the new credential is removed again and the error
+// returned.
+//
+// With Config, the switch is the device's acknowledgement of the config
+// version that was sent, and only activity after the acknowledgement counts,
+// so the device should reconnect with the new key as soon as it has the
+// config. Without Config, there is no evidence tied to the switch: activity
+// after the device was told to switch counts, so the device must stop using
+// the old key at once.
+func RotateDeviceCredential(ctx context.Context, service *iot.Service, name iot.DeviceName, opts RotateDeviceOptions) (*DeviceRotation, error) {
+	if opts.Config == nil && opts.Command == nil && opts.Notify == nil {
+		return nil, errors.New("credentials: one of Config, Command or Notify is required")
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultRotationTimeout
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = defaultRotationPollInterval
+	}
+	if opts.Replace == nil {
+		opts.Replace = func(*iot.DeviceCredential) bool { return true }
+	}
+	devices := service.Projects.Locations.Registries.Devices
+
+	device, err := devices.GetByName(name).FieldMask("credentials").Context(ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+	if len(device.Credentials) >= maxDeviceCredentials {
+		return nil, fmt.Errorf("credentials: device %s already has %d credentials", name.Device, len(device.Credentials))
+	}
+	var replaced []*iot.DeviceCredential
+	for _, c := range device.Credentials {
+		if opts.Replace(c) {
+			replaced = append(replaced, c)
+		}
+	}
+
+	r := &DeviceRotation{Credential: opts.Credential}
+	if r.Credential == nil {
+		if r.PrivateKey, r.Credential, err = Generate(opts.Options); err != nil {
+			return nil, err
+		}
+	}
+	creds := append(device.Credentials, r.Credential)
+	if _, err := devices.PatchByName(name, &iot.Device{Credentials: creds}).UpdateMask("credentials").Context(ctx).Do(); err != nil {
+		return nil, err
+	}
+
+	// From here on, failures remove the new credential again.
+	rollback := func(cause error) error {
+		ctx := context.WithoutCancel(ctx)
+		if err := removeCredentials(ctx, service, name, []*iot.DeviceCredential{r.Credential}); err != nil {
+			return fmt.Errorf("credentials: rollback failed: %w (after: %v)", err, cause)
+		}
+		return cause
+	}
+
+	var version int64
+	if opts.Config != nil {
+		config, err := devices.ModifyCloudToDeviceConfigByName(name, &iot.ModifyCloudToDeviceConfigRequest{
+			BinaryData: base64.StdEncoding.EncodeToString(opts.Config),
+		}).Context(ctx).Do()
+		if err != nil {
+			return nil, rollback(err)
+		}
+		version = config.Version
+	}
+	if opts.Command != nil {
+		_, err := devices.SendCommandToDeviceByName(name, &iot.SendCommandToDeviceRequest{
+			BinaryData: base64.StdEncoding.EncodeToString(opts.Command),
+			Subfolder:  opts.CommandSubfolder,
+		}).Context(ctx).Do()
+		if err != nil {
+			return nil, rollback(err)
+		}
+	}
+	if opts.Notify != nil {
+		if err := opts.Notify(ctx, r.PrivateKey, r.Credential); err != nil {
+			return nil, rollback(err)
+		}
+	}
+
+	if r.ReturnedAt, err = waitForReturn(ctx, service, name, version, opts); err != nil {
+		return nil, rollback(err)
+	}
+	if err := removeCredentials(ctx, service, name, replaced); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// waitForReturn polls the device until it shows activity after the switch,
+// and returns the time of that activity.
+//
+// If version is set, the switch is the device's acknowledgement of that
+// config version. Otherwise it is the latest activity the server recorded
+// for the device once it was told to switch, so that only server
+// timestamps are compared.
+func waitForReturn(ctx context.Context, service *iot.Service, name iot.DeviceName, version int64, opts RotateDeviceOptions) (time.Time, error) {
+	devices := service.Projects.Locations.Registries.Devices
+	timeout := time.NewTimer(opts.Timeout)
+	defer timeout.Stop()
+	var switched time.Time
+	for {
+		device, err := devices.GetByName(name).Context(ctx).Do()
+		if err != nil {
+			return time.Time{}, err
+		}
+		switch {
+		case !switched.IsZero():
+			if t := latest(device.LastHeartbeatTime, device.LastEventTime); t.After(switched) {
+				return t, nil
+			}
+		case version == 0:
+			switched = latest(device.LastHeartbeatTime, device.LastEventTime, device.LastStateTime,
+				device.LastConfigSendTime, device.LastConfigAckTime)
+			if switched.IsZero() {
+				// The device never connected, so any activity is new.
+				switched = time.Unix(0, 0)
+			}
+		default:
+			if switched, err = devices.ConfigAckTime(ctx, name, device, version); err != nil {
+				return time.Time{}, err
+			}
+		}
+		select {
+		case <-time.After(opts.PollInterval):
+		case <-timeout.C:
+			return time.Time{}, fmt.Errorf("%w within %s", ErrDeviceNotReturned, opts.Timeout)
+		case <-ctx.Done():
+			return time.Time{}, ctx.Err()
+		}
+	}
+}
+
+// latest returns the latest of the timestamps, or the zero time if none can
+// be parsed.
+func latest(timestamps ...string) time.Time {
+	var t time.Time
+	for _, ts := range timestamps {
+		if parsed, err := time.Parse(time.RFC3339Nano, ts); err == nil && parsed.After(t) {
+			t = parsed
+		}
+	}
+	return t
+}
+
+// removeCredentials removes the credentials with the same keys as remove
+// from the device.
+func removeCredentials(ctx context.Context, service *iot.Service, name iot.DeviceName, remove []*iot.DeviceCredential) error {
+	if len(remove) == 0 {
+		return nil
+	}
+	devices := service.Projects.Locations.Registries.Devices
+	device, err := devices.GetByName(name).FieldMask("credentials").Context(ctx).Do()
+	if err != nil {
+		return err
+	}
+	var creds []*iot.DeviceCredential
+	for _, c := range device.Credentials {
+		if !containsKey(remove, c) {
+			creds = append(creds, c)
+		}
+	}
+	if len(creds) == len(device.Credentials) {
+		return nil
+	}
+	d := &iot.Device{Credentials: creds}
+	if len(creds) == 0 {
+		d.NullFields = []string{"Credentials"}
+	}
+	_, err = devices.PatchByName(name, d).UpdateMask("credentials").Context(ctx).Do()
+	return err
+}
+
+func containsKey(creds []*iot.DeviceCredential, cred *iot.DeviceCredential) bool {
+	for _, c := range creds {
+		if c.PublicKey != nil && cred.PublicKey != nil && c.PublicKey.Key == cred.PublicKey.Key {
+			return true
+		}
+	}
+	return false
+}
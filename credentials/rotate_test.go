@@ -0,0 +1,106 @@
+package credentials_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	iot "github.com/clearblade/go-iot"
+	"github.com/clearblade/go-iot/credentials"
+	"github.com/clearblade/go-iot/iottest"
+)
+
+func TestRotateDeviceCredential(t *testing.T) {
+	fake, service := iottest.NewService(t, "test-project")
+	iottest.CreateRegistry(t, service, registry)
+	ctx := context.Background()
+	devices := service.Projects.Locations.Registries.Devices
+	name := registry.Device("device")
+
+	_, old, _ := credentials.Generate(credentials.Options{})
+	if _, err := devices.CreateIn(name.Parent(), &iot.Device{Id: "device", Credentials: []*iot.DeviceCredential{old}}).Do(); err != nil {
+		t.Fatalf("Failed to create device: %s", err.Error())
+	}
+
+	// The device switches keys when it receives the command, and keeps
+	// sending heartbeats.
+	heartbeats, stop := context.WithCancel(ctx)
+	defer stop()
+	go func() {
+		for heartbeats.Err() == nil {
+			if commands, _ := fake.Commands(name.String()); len(commands) > 0 {
+				fake.Heartbeat(name.String())
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}()
+	rotation, err := credentials.RotateDeviceCredential(ctx, service, name, credentials.RotateDeviceOptions{
+		Command:      []byte("switch-key"),
+		PollInterval: 10 * time.Millisecond,
+		Timeout:      5 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("Failed to rotate credential: %s", err.Error())
+	}
+	if rotation.PrivateKey == nil || rotation.ReturnedAt.IsZero() {
+		t.Errorf("Expected a private key and return time but got: %+v", rotation)
+	}
+	device, err := devices.GetByName(name).Do()
+	if err != nil {
+		t.Fatalf("Failed to get device: %s", err.Error())
+	}
+	if len(device.Credentials) != 1 || device.Credentials[0].PublicKey.Key != rotation.Credential.PublicKey.Key {
+		t.Errorf("Expected only the new credential but got %d credentials", len(device.Credentials))
+	}
+
+	// A device that keeps sending heartbeats but never acknowledges the
+	// switch config keeps its credentials.
+	_, err = credentials.RotateDeviceCredential(ctx, service, name, credentials.RotateDeviceOptions{
+		Config:       []byte("switch-key"),
+		PollInterval: 10 * time.Millisecond,
+		Timeout:      100 * time.Millisecond,
+	})
+	if !errors.Is(err, credentials.ErrDeviceNotReturned) {
+		t.Errorf("Expected ErrDeviceNotReturned but got: %v", err)
+	}
+	device, err = devices.GetByName(name).Do()
+	if err != nil {
+		t.Fatalf("Failed to get device: %s", err.Error())
+	}
+	if len(device.Credentials) != 1 || device.Credentials[0].PublicKey.Key != rotation.Credential.PublicKey.Key {
+		t.Errorf("Expected the rotation to be rolled back but got %d credentials", len(device.Credentials))
+	}
+	if device.Config.Version != 2 {
+		t.Errorf("Expected the switch config to be sent but got version: %d", device.Config.Version)
+	}
+
+	// A device that acknowledges the switch config and reconnects completes
+	// the rotation.
+	go func() {
+		for heartbeats.Err() == nil {
+			if fake.AckConfig(name.String(), 3) == nil {
+				return
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}()
+	rotated, err := credentials.RotateDeviceCredential(ctx, service, name, credentials.RotateDeviceOptions{
+		Config:       []byte("switch-key"),
+		PollInterval: 10 * time.Millisecond,
+		Timeout:      5 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("Failed to rotate credential: %s", err.Error())
+	}
+	device, err = devices.GetByName(name).Do()
+	if err != nil {
+		t.Fatalf("Failed to get device: %s", err.Error())
+	}
+	if len(device.Credentials) != 1 || device.Credentials[0].PublicKey.Key != rotated.Credential.PublicKey.Key {
+		t.Errorf("Expected only the new credential but got %d credentials", len(device.Credentials))
+	}
+	if ackTime, _ := time.Parse(time.RFC3339Nano, device.Config.DeviceAckTime); !rotated.ReturnedAt.After(ackTime) {
+		t.Errorf("Expected the device to return after its ack at %s but got: %s", ackTime, rotated.ReturnedAt)
+	}
+}
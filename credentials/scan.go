@@ -0,0 +1,191 @@
+// Copyright 2023 ClearBlade Inc.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package credentials
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	iot "github.com/clearblade/go-iot"
+)
+
+// maxDeviceCredentials is the number of credentials a device may have.
+const maxDeviceCredentials = 3
+
+// DefaultOverlap is how long RotateExpiring keeps replaced credentials valid
+// by default.
+const DefaultOverlap = 7 * 24 * time.Hour
+
+// Expiring is a device credential that expires within the window of a scan.
+type Expiring struct {
+	// Device is the name of the device.
+	Device iot.DeviceName
+	// Index is the index of the credential in the device's Credentials.
+	Index int
+	// Credential is the expiring credential.
+	Credential *iot.DeviceCredential
+	// ExpiresAt is the parsed ExpirationTime of the credential. It is in
+	// the past for credentials that have already expired.
+	ExpiresAt time.Time
+}
+
+// ScanExpiring lists every device of every registry in parent and returns
+// the credentials that have expired or expire within window, keyed by
+// registry name. Registries without such credentials are left out.
+func ScanExpiring(ctx context.Context, service *iot.Service, parent iot.LocationName, window time.Duration) (map[string][]*Expiring, error) {
+	deadline := time.Now().Add(window)
+	found := make(map[string][]*Expiring)
+	for registry, err := range service.Projects.Locations.Registries.ListIn(parent).All(ctx) {
+		if err != nil {
+			return nil, err
+		}
+		registryName := parent.Registry(registry.Id)
+		devices := service.Projects.Locations.Registries.Devices.ListIn(registryName).FieldMask("credentials")
+		for device, err := range devices.All(ctx) {
+			if err != nil {
+				return nil, err
+			}
+			for i, cred := range device.Credentials {
+				if cred == nil {
+					continue
+				}
+				expires, ok, err := expiration(cred)
+				if err != nil {
+					return nil, fmt.Errorf("credentials: device %s: credential %d has an invalid expiration time: %w", device.Id, i, err)
+				}
+				if ok && expires.Before(deadline) {
+					found[registryName.String()] = append(found[registryName.String()], &Expiring{
+						Device:     registryName.Device(device.Id),
+						Index:      i,
+						Credential: cred,
+						ExpiresAt:  expires,
+					})
+				}
+			}
+		}
+	}
+	return found, nil
+}
+
+// RotateOptions configures RotateExpiring.
+type RotateOptions struct {
+	// Options configures the generated keys. Unless Options.Algorithm is
+	// set, each new key has the algorithm of the credential it replaces, and
+	// is wrapped in a certificate if the replaced credential was one.
+	Options Options
+
+	// Overlap is how long the replaced credentials stay valid, so that
+	// devices can switch to their new key. The default is DefaultOverlap.
+	Overlap time.Duration
+}
+
+// Rotation is the result of rotating the credentials of a device.
+type Rotation struct {
+	// Device is the name of the device.
+	Device iot.DeviceName
+	// PrivateKey is the PEM encoded new private key, to install on the
+	// device.
+	PrivateKey []byte
+	// Credential is the credential added to the device.
+	Credential *iot.DeviceCredential
+	// Err is the error rotating the device's credentials, if any.
+	Err error
+}
+
+// RotateExpiring adds a newly generated credential to each device with
+// expiring credentials, and patches the expiration time of the expiring ones
+// so that they stay valid for the overlap period. Credentials that have
+// already expired are removed to make room for the new one. It returns a
+// Rotation for each device, in the order they first appear in expiring;
+// failures are reported in Rotation.Err and do not stop other devices from
+// being rotated.
+func RotateExpiring(ctx context.Context, service *iot.Service, expiring []*Expiring, opts RotateOptions) []*Rotation {
+	if opts.Overlap <= 0 {
+		opts.Overlap = DefaultOverlap
+	}
+	var rotations []*Rotation
+	seen := make(map[iot.DeviceName]bool)
+	for _, e := range expiring {
+		if seen[e.Device] {
+			continue
+		}
+		seen[e.Device] = true
+		r := &Rotation{Device: e.Device}
+		r.PrivateKey, r.Credential, r.Err = rotate(ctx, service, e, opts)
+		rotations = append(rotations, r)
+	}
+	return rotations
+}
+
+func rotate(ctx context.Context, service *iot.Service, e *Expiring, opts RotateOptions) ([]byte, *iot.DeviceCredential, error) {
+	devices := service.Projects.Locations.Registries.Devices
+	device, err := devices.GetByName(e.Device).FieldMask("credentials").Context(ctx).Do()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	genOpts := opts.Options
+	if genOpts.Algorithm == "" && e.Credential.PublicKey != nil {
+		genOpts.Algorithm, genOpts.Certificate = formatOptions(e.Credential.PublicKey.Format)
+	}
+	privateKey, cred, err := Generate(genOpts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	keepUntil := now.Add(opts.Overlap)
+	var creds []*iot.DeviceCredential
+	for _, c := range device.Credentials {
+		expires, ok, err := expiration(c)
+		if err != nil {
+			return nil, nil, fmt.Errorf("credentials: invalid expiration time %q: %w", c.ExpirationTime, err)
+		}
+		if ok {
+			if !expires.After(now) {
+				continue
+			}
+			if expires.Before(keepUntil) {
+				c.ExpirationTime = keepUntil.UTC().Format(time.RFC3339)
+			}
+		}
+		creds = append(creds, c)
+	}
+	creds = append(creds, cred)
+	if len(creds) > maxDeviceCredentials {
+		return nil, nil, fmt.Errorf("credentials: device %s already has %d valid credentials", e.Device.Device, len(creds)-1)
+	}
+	_, err = devices.PatchByName(e.Device, &iot.Device{Credentials: creds}).UpdateMask("credentials").Context(ctx).Do()
+	if err != nil {
+		return nil, nil, err
+	}
+	return privateKey, cred, nil
+}
+
+// expiration returns the parsed ExpirationTime of cred, and whether it
+// expires at all. The API reports credentials that never expire with an
+// empty time or the Unix epoch.
+func expiration(cred *iot.DeviceCredential) (time.Time, bool, error) {
+	if cred.ExpirationTime == "" {
+		return time.Time{}, false, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, cred.ExpirationTime)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return t, t.Unix() > 0, nil
+}
+
+// formatOptions returns the algorithm of a credential format, and whether
+// it is a certificate.
+func formatOptions(format string) (Algorithm, bool) {
+	alg := ES256
+	if strings.HasPrefix(format, "RSA") {
+		alg = RS256
+	}
+	return alg, strings.HasSuffix(format, "_X509_PEM")
+}
@@ -0,0 +1,104 @@
+package credentials_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	iot "github.com/clearblade/go-iot"
+	"github.com/clearblade/go-iot/credentials"
+	"github.com/clearblade/go-iot/iottest"
+	"github.com/clearblade/go-iot/jwtauth"
+)
+
+func TestScanAndRotateExpiring(t *testing.T) {
+	_, service := iottest.NewService(t, "test-project")
+	iottest.CreateRegistry(t, service, registry)
+	ctx := context.Background()
+	parent := iot.LocationName{Project: "test-project", Location: "us-central1"}
+	if _, err := service.Projects.Locations.Registries.CreateIn(parent, &iot.DeviceRegistry{Id: "other"}).Do(); err != nil {
+		t.Fatalf("Failed to create registry: %s", err.Error())
+	}
+
+	expiringIn := func(d time.Duration) *iot.DeviceCredential {
+		_, cred, err := credentials.Generate(credentials.Options{Algorithm: credentials.RS256, Lifetime: d})
+		if err != nil {
+			t.Fatalf("Failed to generate credential: %s", err.Error())
+		}
+		return cred
+	}
+	expired := expiringIn(time.Hour)
+	expired.ExpirationTime = time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	// The API reports credentials without an expiration time with the epoch.
+	never := &iot.DeviceCredential{PublicKey: expiringIn(time.Hour).PublicKey, ExpirationTime: "1970-01-01T00:00:00Z"}
+	for _, d := range []struct {
+		registry, id string
+		creds        []*iot.DeviceCredential
+	}{
+		{"registry", "soon", []*iot.DeviceCredential{expiringIn(24 * time.Hour), expired}},
+		{"registry", "later", []*iot.DeviceCredential{expiringIn(60 * 24 * time.Hour)}},
+		{"registry", "never", []*iot.DeviceCredential{{PublicKey: expiringIn(time.Hour).PublicKey}, never}},
+		{"other", "also-soon", []*iot.DeviceCredential{expiringIn(48 * time.Hour), never}},
+	} {
+		_, err := service.Projects.Locations.Registries.Devices.CreateIn(parent.Registry(d.registry), &iot.Device{Id: d.id, Credentials: d.creds}).Do()
+		if err != nil {
+			t.Fatalf("Failed to create device %s: %s", d.id, err.Error())
+		}
+	}
+
+	found, err := credentials.ScanExpiring(ctx, service, parent, 30*24*time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to scan credentials: %s", err.Error())
+	}
+	soon := found[parent.Registry("registry").String()]
+	other := found[parent.Registry("other").String()]
+	if len(found) != 2 || len(soon) != 2 || len(other) != 1 {
+		t.Fatalf("Expected 2 expiring credentials in registry and 1 in other but got: %v", found)
+	}
+	if soon[0].Device.Device != "soon" || soon[1].Index != 1 || soon[1].ExpiresAt.After(time.Now()) {
+		t.Errorf("Expected the expiring and expired credentials of soon but got: %+v %+v", soon[0], soon[1])
+	}
+
+	rotations := credentials.RotateExpiring(ctx, service, append(soon, other...), credentials.RotateOptions{Overlap: 24 * time.Hour})
+	if len(rotations) != 2 {
+		t.Fatalf("Expected 2 rotations but got: %d", len(rotations))
+	}
+	for _, r := range rotations {
+		if r.Err != nil {
+			t.Fatalf("Failed to rotate %s: %s", r.Device, r.Err.Error())
+		}
+	}
+
+	device, err := service.Projects.Locations.Registries.Devices.GetByName(rotations[0].Device).Do()
+	if err != nil {
+		t.Fatalf("Failed to get device: %s", err.Error())
+	}
+	if len(device.Credentials) != 2 {
+		t.Fatalf("Expected the expired credential to be replaced but got %d credentials", len(device.Credentials))
+	}
+	if device.Credentials[1].PublicKey.Format != "RSA_PEM" {
+		t.Errorf("Expected the new key to keep the RSA_PEM format but got: %s", device.Credentials[1].PublicKey.Format)
+	}
+	kept, _ := time.Parse(time.RFC3339, device.Credentials[0].ExpirationTime)
+	if d := time.Until(kept); d < 23*time.Hour || d > 24*time.Hour {
+		t.Errorf("Expected the old credential to stay valid for the overlap but got: %s", device.Credentials[0].ExpirationTime)
+	}
+
+	alsoSoon, err := service.Projects.Locations.Registries.Devices.GetByName(rotations[1].Device).Do()
+	if err != nil {
+		t.Fatalf("Failed to get device: %s", err.Error())
+	}
+	if len(alsoSoon.Credentials) != 3 || alsoSoon.Credentials[1].PublicKey.Key != never.PublicKey.Key {
+		t.Errorf("Expected the credential without expiration to be kept but got %d credentials", len(alsoSoon.Credentials))
+	}
+
+	key, err := jwtauth.ParsePrivateKey(rotations[0].PrivateKey)
+	if err != nil {
+		t.Fatalf("Failed to parse private key: %s", err.Error())
+	}
+	token, _ := jwtauth.NewToken(key, "test-project", time.Hour)
+	match, err := jwtauth.Verify(token, "test-project", device.Credentials)
+	if err != nil || match.Index != 1 {
+		t.Errorf("Expected the new key to match the new credential but got: %v", err)
+	}
+}
@@ -0,0 +1,54 @@
+package iot_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	iot "github.com/clearblade/go-iot"
+	"github.com/clearblade/go-iot/iottest"
+)
+
+func TestFileCredentialsProviderPicksUpRotatedFile(t *testing.T) {
+	t.Setenv(iot.EnvToken, "")
+	fake := iottest.NewServer("test-project")
+	defer fake.Close()
+
+	var credentials iot.ServiceAccountCredentials
+	if err := json.Unmarshal([]byte(fake.Credentials()), &credentials); err != nil {
+		t.Fatalf("Failed to parse credentials: %s", err.Error())
+	}
+	valid := credentials
+	credentials.Token = "revoked"
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	writeCredentials := func(c iot.ServiceAccountCredentials, modTime time.Time) {
+		b, _ := json.Marshal(c)
+		if err := os.WriteFile(path, b, 0o600); err != nil {
+			t.Fatalf("Failed to write credentials: %s", err.Error())
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatalf("Failed to set modification time: %s", err.Error())
+		}
+	}
+	writeCredentials(credentials, time.Now().Add(-time.Minute))
+
+	service, err := iot.NewService(context.Background(), iot.WithCredentialsProvider(iot.NewChainCredentialsProvider(
+		iot.NewEnvCredentialsProvider(),
+		iot.NewFileCredentialsProvider(path),
+	)))
+	if err != nil {
+		t.Fatalf("Failed to initialize service: %s", err.Error())
+	}
+	parent := "projects/test-project/locations/us-central1"
+	if _, err := service.Projects.Locations.Registries.List(parent).Do(); err == nil {
+		t.Errorf("Expected list with revoked credentials to fail")
+	}
+
+	writeCredentials(valid, time.Now())
+	if _, err := service.Projects.Locations.Registries.List(parent).Do(); err != nil {
+		t.Errorf("Failed to list registries with rotated credentials: %s", err.Error())
+	}
+}
@@ -0,0 +1,486 @@
+// Copyright 2023 ClearBlade Inc.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package device implements the device side of ClearBlade IoT Core: an MQTT
+// 3.1.1 client that authenticates with JWTs signed by the device's private
+// key, publishes telemetry events and state, and receives configuration and
+// commands.
+//
+//	key, err := device.ParsePrivateKey(pemBytes)
+//	...
+//	client, err := device.Connect(ctx, device.Config{
+//		Name:       iot.LocationName{Project: "my-project", Location: "us-central1"}.Registry("my-registry").Device("my-device"),
+//		PrivateKey: key,
+//	})
+//	...
+//	defer client.Close()
+//	err = client.PublishEvent(ctx, "", []byte(`{"temperature":21}`))
+package device
+
+import (
+	"bufio"
+	"context"
+	"crypto"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	iot "github.com/clearblade/go-iot"
+	"github.com/clearblade/go-iot/internal/mqtt"
+	"github.com/clearblade/go-iot/jwtauth"
+)
+
+const (
+	defaultTokenLifetime = time.Hour
+	defaultKeepAlive     = time.Minute
+	// maxTokenRefreshMargin is how long before its token expires a
+	// connection is replaced, at most.
+	maxTokenRefreshMargin = time.Minute
+	minReconnectDelay     = 500 * time.Millisecond
+	maxReconnectDelay     = time.Minute
+	// reconnectTimeout is how long a reconnect attempt may take, up to
+	// the broker's CONNACK and the restored subscriptions.
+	reconnectTimeout = 30 * time.Second
+	// dispatchQueueLength is the number of received messages that can wait
+	// for their handler. Messages received while the queue is full are
+	// dropped.
+	dispatchQueueLength = 64
+)
+
+var (
+	// ErrClosed is returned by calls on a closed Client.
+	ErrClosed = errors.New("device: client closed")
+	// ErrConnectionRefused is returned by Connect when the server rejects
+	// the device's credentials.
+	ErrConnectionRefused = errors.New("device: connection refused")
+)
+
+// Config configures a Client.
+type Config struct {
+	// Name is the name of the device. It is used as the MQTT client ID, and
+	// must use the device ID rather than its numeric ID.
+	Name iot.DeviceName
+
+	// PrivateKey signs the JWTs the client authenticates with. It must be
+	// an *rsa.PrivateKey (RS256) or a P-256 *ecdsa.PrivateKey (ES256)
+	// matching one of the device's credentials.
+	PrivateKey crypto.Signer
+
+	// Broker is the URL of the MQTT server, "ssl://host:port" or
+	// "tcp://host:port". The default is the ClearBlade bridge for the
+	// device's location, ssl://{location}-mqtt.clearblade.com:8883.
+	Broker string
+
+	// TLSConfig is used for ssl:// brokers. The default verifies the server
+	// against the system roots.
+	TLSConfig *tls.Config
+
+	// TokenLifetime is how long each JWT is valid, at most
+	// jwtauth.MaxLifetime. The client reconnects with a new token shortly
+	// before the current one expires. The default is one hour.
+	TokenLifetime time.Duration
+
+	// Audience is the JWT audience. The default is the device's project.
+	Audience string
+
+	// KeepAlive is the MQTT keep alive interval. The default is one minute.
+	KeepAlive time.Duration
+
+	// OnConnectionLost, if set, is called when the connection drops, before
+	// the client reconnects.
+	OnConnectionLost func(err error)
+}
+
+// Client is an MQTT connection for a single device. It reconnects
+// automatically when the connection drops or its token is about to expire,
+// and restores its subscriptions. A Client is safe for concurrent use.
+type Client struct {
+	cfg      Config
+	deviceID string
+	dispatch chan received
+	done     chan struct{}
+	stopped  chan struct{}
+	dropped  atomic.Int64
+
+	mu      sync.Mutex
+	conn    *conn
+	changed chan struct{} // closed and replaced when conn changes
+	subs    []*subscription
+	closed  bool
+}
+
+type subscription struct {
+	filter  string
+	qos     byte
+	handler func(topic string, payload []byte)
+}
+
+type received struct {
+	handler func(topic string, payload []byte)
+	topic   string
+	payload []byte
+}
+
+// Connect connects a device to the MQTT bridge. It fails if the first
+// connection attempt does; later connection losses are recovered from in the
+// background.
+func Connect(ctx context.Context, cfg Config) (*Client, error) {
+	if err := cfg.Name.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.Name.IsNumeric() {
+		return nil, fmt.Errorf("device: name %s must use the device ID, not its numeric ID", cfg.Name)
+	}
+	if cfg.PrivateKey == nil {
+		return nil, errors.New("device: a private key is required")
+	}
+	if _, err := jwtauth.Algorithm(cfg.PrivateKey); err != nil {
+		return nil, err
+	}
+	if cfg.Broker == "" {
+		cfg.Broker = fmt.Sprintf("ssl://%s-mqtt.clearblade.com:8883", cfg.Name.Location)
+	}
+	if cfg.TokenLifetime <= 0 {
+		cfg.TokenLifetime = defaultTokenLifetime
+	}
+	if cfg.KeepAlive <= 0 {
+		cfg.KeepAlive = defaultKeepAlive
+	}
+	if cfg.Audience == "" {
+		cfg.Audience = cfg.Name.Project
+	}
+	c := &Client{
+		cfg:      cfg,
+		deviceID: cfg.Name.Device,
+		dispatch: make(chan received, dispatchQueueLength),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+		changed:  make(chan struct{}),
+	}
+	cn, err := c.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.setConn(cn)
+	go c.run(cn)
+	go c.dispatchLoop()
+	return c, nil
+}
+
+// PublishEvent publishes a telemetry event to /devices/{id}/events, or to
+// /devices/{id}/events/{subfolder} if subfolder is not empty. It returns
+// once the server has acknowledged the event. If the connection drops first,
+// the event is sent again after reconnecting.
+func (c *Client) PublishEvent(ctx context.Context, subfolder string, payload []byte) error {
+	topic := "/devices/" + c.deviceID + "/events"
+	if subfolder != "" {
+		topic += "/" + subfolder
+	}
+	return c.publish(ctx, topic, payload)
+}
+
+// PublishState reports the device state to /devices/{id}/state.
+func (c *Client) PublishState(ctx context.Context, payload []byte) error {
+	return c.publish(ctx, "/devices/"+c.deviceID+"/state", payload)
+}
+
+// SubscribeConfig subscribes to /devices/{id}/config. The server sends the
+// current configuration right away and every update after it. Handlers of a
+// client are called one at a time, in the order messages arrive. They may
+// publish, but while they fall more than 64 messages behind, further
+// messages are dropped; see Dropped.
+func (c *Client) SubscribeConfig(ctx context.Context, handler func(config []byte)) error {
+	return c.subscribe(ctx, subscription{
+		filter:  "/devices/" + c.deviceID + "/config",
+		qos:     1,
+		handler: func(topic string, payload []byte) { handler(payload) },
+	})
+}
+
+// SubscribeCommands subscribes to the commands sent to the device. Commands
+// sent to a subfolder are passed with it; others with an empty subfolder.
+func (c *Client) SubscribeCommands(ctx context.Context, handler func(subfolder string, payload []byte)) error {
+	prefix := "/devices/" + c.deviceID + "/commands"
+	return c.subscribe(ctx, subscription{
+		filter: prefix + "/#",
+		qos:    0,
+		handler: func(topic string, payload []byte) {
+			subfolder := ""
+			if len(topic) > len(prefix)+1 {
+				subfolder = topic[len(prefix)+1:]
+			}
+			handler(subfolder, payload)
+		},
+	})
+}
+
+// Close disconnects from the server and stops reconnecting.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return ErrClosed
+	}
+	c.closed = true
+	close(c.done)
+	c.mu.Unlock()
+	<-c.stopped
+	return nil
+}
+
+func (c *Client) publish(ctx context.Context, topic string, payload []byte) error {
+	for {
+		cn, err := c.connection(ctx)
+		if err != nil {
+			return err
+		}
+		err = cn.publish(ctx, topic, payload)
+		if !errors.Is(err, errConnectionLost) {
+			return err
+		}
+	}
+}
+
+func (c *Client) subscribe(ctx context.Context, sub subscription) error {
+	// Register the subscription first, so that a connection made from now on
+	// restores it.
+	c.mu.Lock()
+	c.subs = append(c.subs, &sub)
+	c.mu.Unlock()
+	cn, err := c.connection(ctx)
+	if err == nil {
+		err = cn.subscribe(ctx, []*subscription{&sub})
+	}
+	if errors.Is(err, errConnectionLost) {
+		// The subscription is restored when the client reconnects.
+		return nil
+	}
+	if err != nil {
+		c.mu.Lock()
+		for i, s := range c.subs {
+			if s == &sub {
+				c.subs = append(c.subs[:i], c.subs[i+1:]...)
+				break
+			}
+		}
+		c.mu.Unlock()
+	}
+	return err
+}
+
+// connection waits for a live connection.
+func (c *Client) connection(ctx context.Context) (*conn, error) {
+	for {
+		c.mu.Lock()
+		cn, changed, closed := c.conn, c.changed, c.closed
+		c.mu.Unlock()
+		if closed {
+			return nil, ErrClosed
+		}
+		if cn != nil && !cn.isDone() {
+			return cn, nil
+		}
+		select {
+		case <-changed:
+		case <-c.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (c *Client) setConn(cn *conn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conn = cn
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+// run replaces the connection when it drops or its token is about to
+// expire, until the client is closed.
+func (c *Client) run(cn *conn) {
+	defer close(c.stopped)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-c.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	for {
+		margin := c.cfg.TokenLifetime / 10
+		if margin > maxTokenRefreshMargin {
+			margin = maxTokenRefreshMargin
+		}
+		refresh := time.NewTimer(time.Until(cn.expires.Add(-margin)))
+		select {
+		case <-c.done:
+			refresh.Stop()
+			cn.disconnect()
+			return
+		case <-cn.done:
+			if c.cfg.OnConnectionLost != nil {
+				c.cfg.OnConnectionLost(cn.err)
+			}
+		case <-refresh.C:
+			cn.disconnect()
+		}
+		refresh.Stop()
+		c.setConn(nil)
+
+		delay := minReconnectDelay
+		for {
+			dialCtx, cancelDial := context.WithTimeout(ctx, reconnectTimeout)
+			next, err := c.dial(dialCtx)
+			cancelDial()
+			if err == nil {
+				cn = next
+				break
+			}
+			select {
+			case <-c.done:
+				return
+			case <-time.After(delay):
+			}
+			if delay *= 2; delay > maxReconnectDelay {
+				delay = maxReconnectDelay
+			}
+		}
+		c.setConn(cn)
+	}
+}
+
+func (c *Client) dispatchLoop() {
+	for {
+		select {
+		case m := <-c.dispatch:
+			m.handler(m.topic, m.payload)
+		case <-c.stopped:
+			return
+		}
+	}
+}
+
+// deliver queues a received message for the handler of its subscription. It
+// is called by the read loop of the connection, so it drops the message
+// rather than wait for a full queue: a handler waiting for an
+// acknowledgement from the same read loop would never return.
+func (c *Client) deliver(topic string, payload []byte) {
+	c.mu.Lock()
+	var handler func(string, []byte)
+	for _, sub := range c.subs {
+		if mqtt.MatchTopic(sub.filter, topic) {
+			handler = sub.handler
+		}
+	}
+	c.mu.Unlock()
+	if handler == nil {
+		return
+	}
+	select {
+	case c.dispatch <- received{handler: handler, topic: topic, payload: payload}:
+	default:
+		c.dropped.Add(1)
+	}
+}
+
+// Dropped returns the number of received messages that were dropped because
+// the handlers were too far behind.
+func (c *Client) Dropped() int64 {
+	return c.dropped.Load()
+}
+
+// dial opens an authenticated connection and restores the subscriptions.
+func (c *Client) dial(ctx context.Context) (*conn, error) {
+	u, err := url.Parse(c.cfg.Broker)
+	if err != nil {
+		return nil, fmt.Errorf("device: invalid broker URL: %w", err)
+	}
+	var nc net.Conn
+	switch u.Scheme {
+	case "ssl", "tls", "mqtts":
+		cfg := c.cfg.TLSConfig.Clone()
+		if cfg == nil {
+			cfg = &tls.Config{}
+		}
+		if cfg.ServerName == "" {
+			cfg.ServerName = u.Hostname()
+		}
+		nc, err = (&tls.Dialer{Config: cfg}).DialContext(ctx, "tcp", u.Host)
+	case "tcp", "mqtt":
+		nc, err = (&net.Dialer{}).DialContext(ctx, "tcp", u.Host)
+	default:
+		return nil, fmt.Errorf("device: unsupported broker scheme %q", u.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	expires := now.Add(c.cfg.TokenLifetime)
+	token, err := jwtauth.Sign(c.cfg.PrivateKey, jwtauth.Claims{Audience: c.cfg.Audience, IssuedAt: now, ExpiresAt: expires})
+	if err != nil {
+		nc.Close()
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		nc.SetDeadline(deadline)
+	}
+	br := bufio.NewReader(nc)
+	err = mqtt.Write(nc, &mqtt.Connect{
+		ClientID:     c.cfg.Name.String(),
+		Username:     "unused",
+		Password:     token,
+		KeepAlive:    uint16(c.cfg.KeepAlive / time.Second),
+		CleanSession: true,
+	})
+	var p mqtt.Packet
+	if err == nil {
+		p, err = mqtt.Read(br)
+	}
+	if err != nil {
+		nc.Close()
+		return nil, fmt.Errorf("device: failed to connect: %w", err)
+	}
+	ack, ok := p.(*mqtt.Connack)
+	if !ok {
+		nc.Close()
+		return nil, fmt.Errorf("device: expected CONNACK but got %T", p)
+	}
+	if ack.ReturnCode != mqtt.Accepted {
+		nc.Close()
+		return nil, fmt.Errorf("%w: return code %d", ErrConnectionRefused, ack.ReturnCode)
+	}
+	nc.SetDeadline(time.Time{})
+
+	cn := &conn{
+		nc:        nc,
+		keepAlive: c.cfg.KeepAlive,
+		expires:   expires,
+		pending:   make(map[uint16]chan mqtt.Packet),
+		done:      make(chan struct{}),
+		deliver:   c.deliver,
+	}
+	go cn.readLoop(br)
+	go cn.pingLoop()
+
+	c.mu.Lock()
+	subs := append([]*subscription(nil), c.subs...)
+	c.mu.Unlock()
+	if len(subs) > 0 {
+		if err := cn.subscribe(ctx, subs); err != nil {
+			cn.close(err)
+			return nil, err
+		}
+	}
+	return cn, nil
+}
@@ -0,0 +1,331 @@
+package device_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	iot "github.com/clearblade/go-iot"
+	"github.com/clearblade/go-iot/device"
+	"github.com/clearblade/go-iot/device/mqtttest"
+)
+
+var deviceName = iot.LocationName{Project: "test-project", Location: "us-central1"}.Registry("registry").Device("device")
+
+// verifyJWT checks the signature and claims of a token signed by key.
+func verifyJWT(token string, key crypto.PublicKey) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return errors.New("malformed token")
+	}
+	var header struct{ Alg string }
+	var claims struct {
+		Aud      string
+		Iat, Exp int64
+	}
+	b, _ := base64.RawURLEncoding.DecodeString(parts[0])
+	json.Unmarshal(b, &header)
+	b, _ = base64.RawURLEncoding.DecodeString(parts[1])
+	json.Unmarshal(b, &claims)
+	sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if header.Alg != "RS256" {
+			return fmt.Errorf("unexpected algorithm %s", header.Alg)
+		}
+		if err := rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig); err != nil {
+			return err
+		}
+	case *ecdsa.PublicKey:
+		if header.Alg != "ES256" || len(sig) != 64 {
+			return fmt.Errorf("unexpected algorithm %s", header.Alg)
+		}
+		r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+		if !ecdsa.Verify(k, digest[:], r, s) {
+			return errors.New("invalid signature")
+		}
+	}
+	if claims.Aud != "test-project" {
+		return fmt.Errorf("unexpected audience %s", claims.Aud)
+	}
+	if claims.Exp <= claims.Iat || claims.Exp < time.Now().Unix() {
+		return errors.New("token expired")
+	}
+	return nil
+}
+
+func connect(t *testing.T, broker *mqtttest.Broker, key crypto.Signer) *device.Client {
+	t.Helper()
+	broker.Authenticate = func(clientID, username, password string) error {
+		if clientID != deviceName.String() {
+			return fmt.Errorf("unexpected client ID %s", clientID)
+		}
+		return verifyJWT(password, key.Public())
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client, err := device.Connect(ctx, device.Config{Name: deviceName, PrivateKey: key, Broker: broker.URL})
+	if err != nil {
+		t.Fatalf("Failed to connect: %s", err.Error())
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	sec1, _ := x509.MarshalECPrivateKey(ecKey)
+	pkcs8, _ := x509.MarshalPKCS8PrivateKey(ecKey)
+	p384, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	p384Bytes, _ := x509.MarshalECPrivateKey(p384)
+
+	for _, test := range []struct {
+		name  string
+		block *pem.Block
+		ok    bool
+	}{
+		{"PKCS1", &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}, true},
+		{"SEC1", &pem.Block{Type: "EC PRIVATE KEY", Bytes: sec1}, true},
+		{"PKCS8", &pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}, true},
+		{"P-384", &pem.Block{Type: "EC PRIVATE KEY", Bytes: p384Bytes}, false},
+	} {
+		_, err := device.ParsePrivateKey(pem.EncodeToMemory(test.block))
+		if (err == nil) != test.ok {
+			t.Errorf("%s: unexpected result: %v", test.name, err)
+		}
+	}
+	if _, err := device.ParsePrivateKey([]byte("not a key")); err == nil {
+		t.Errorf("Expected an error for invalid PEM data")
+	}
+}
+
+func TestPublish(t *testing.T) {
+	broker := mqtttest.NewBroker()
+	defer broker.Close()
+	ctx := context.Background()
+
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	for _, key := range []crypto.Signer{rsaKey, ecKey} {
+		client := connect(t, broker, key)
+		if err := client.PublishEvent(ctx, "", []byte("event")); err != nil {
+			t.Errorf("Failed to publish event: %s", err.Error())
+		}
+		if err := client.PublishEvent(ctx, "alerts", []byte("alert")); err != nil {
+			t.Errorf("Failed to publish event: %s", err.Error())
+		}
+		if err := client.PublishState(ctx, []byte("state")); err != nil {
+			t.Errorf("Failed to publish state: %s", err.Error())
+		}
+		client.Close()
+	}
+
+	if msgs := broker.Messages("/devices/device/events"); len(msgs) != 2 || string(msgs[0].Payload) != "event" {
+		t.Errorf("Expected 2 events but got: %v", msgs)
+	}
+	if msgs := broker.Messages("/devices/device/events/alerts"); len(msgs) != 2 || msgs[0].QoS != 1 {
+		t.Errorf("Expected 2 alerts at QoS 1 but got: %v", msgs)
+	}
+	if msgs := broker.Messages("/devices/device/state"); len(msgs) != 2 || msgs[1].ClientID != deviceName.String() {
+		t.Errorf("Expected 2 states but got: %v", msgs)
+	}
+}
+
+func TestConnectRefused(t *testing.T) {
+	broker := mqtttest.NewBroker()
+	defer broker.Close()
+	broker.Authenticate = func(clientID, username, password string) error {
+		return errors.New("denied")
+	}
+
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	_, err := device.Connect(context.Background(), device.Config{Name: deviceName, PrivateKey: key, Broker: broker.URL})
+	if !errors.Is(err, device.ErrConnectionRefused) {
+		t.Errorf("Expected ErrConnectionRefused but got: %v", err)
+	}
+
+	numeric := deviceName
+	numeric.Device = "2820000000000001"
+	if _, err := device.Connect(context.Background(), device.Config{Name: numeric, PrivateKey: key, Broker: broker.URL}); err == nil {
+		t.Errorf("Expected an error for a numeric device ID")
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	broker := mqtttest.NewBroker()
+	defer broker.Close()
+	ctx := context.Background()
+	broker.Publish("/devices/device/config", []byte("config-1"), true)
+
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	client := connect(t, broker, key)
+
+	configs := make(chan string, 10)
+	if err := client.SubscribeConfig(ctx, func(config []byte) { configs <- string(config) }); err != nil {
+		t.Fatalf("Failed to subscribe to config: %s", err.Error())
+	}
+	type command struct{ subfolder, payload string }
+	commands := make(chan command, 10)
+	err := client.SubscribeCommands(ctx, func(subfolder string, payload []byte) {
+		commands <- command{subfolder, string(payload)}
+	})
+	if err != nil {
+		t.Fatalf("Failed to subscribe to commands: %s", err.Error())
+	}
+
+	expect := func(ch <-chan string, want string) {
+		t.Helper()
+		select {
+		case got := <-ch:
+			if got != want {
+				t.Errorf("Expected %s but got: %s", want, got)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for %s", want)
+		}
+	}
+	expect(configs, "config-1")
+	broker.Publish("/devices/device/config", []byte("config-2"), true)
+	expect(configs, "config-2")
+
+	broker.Publish("/devices/device/commands/reboot", []byte("now"), false)
+	broker.Publish("/devices/device/commands", []byte("plain"), false)
+	broker.Publish("/devices/other/commands", []byte("not mine"), false)
+	for _, want := range []command{{"reboot", "now"}, {"", "plain"}} {
+		select {
+		case got := <-commands:
+			if got != want {
+				t.Errorf("Expected %v but got: %v", want, got)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for %v", want)
+		}
+	}
+
+	// Subscriptions are restored after the connection drops.
+	if !broker.Disconnect(deviceName.String()) {
+		t.Fatalf("Expected the device to be connected")
+	}
+	expect(configs, "config-2")
+	if err := client.PublishState(ctx, []byte("reconnected")); err != nil {
+		t.Errorf("Failed to publish state: %s", err.Error())
+	}
+	if n := broker.Connections(); n != 2 {
+		t.Errorf("Expected 2 connections but got: %d", n)
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	broker := mqtttest.NewBroker()
+	defer broker.Close()
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	var lost []error
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	client, err := device.Connect(ctx, device.Config{
+		Name:          deviceName,
+		PrivateKey:    key,
+		Broker:        broker.URL,
+		TokenLifetime: 2 * time.Second,
+		OnConnectionLost: func(err error) {
+			mu.Lock()
+			lost = append(lost, err)
+			mu.Unlock()
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to connect: %s", err.Error())
+	}
+	defer client.Close()
+
+	// The token is refreshed by reconnecting before it expires.
+	time.Sleep(2500 * time.Millisecond)
+	if n := broker.Connections(); n < 2 {
+		t.Errorf("Expected a new connection for the refreshed token but got %d connections", n)
+	}
+	mu.Lock()
+	if len(lost) != 0 {
+		t.Errorf("Expected no lost connections but got: %v", lost)
+	}
+	mu.Unlock()
+
+	broker.Disconnect(deviceName.String())
+	if err := client.PublishEvent(ctx, "", []byte("after")); err != nil {
+		t.Errorf("Failed to publish event: %s", err.Error())
+	}
+	if err := client.Close(); err != nil {
+		t.Errorf("Failed to close: %s", err.Error())
+	}
+	if err := client.PublishEvent(ctx, "", []byte("closed")); !errors.Is(err, device.ErrClosed) {
+		t.Errorf("Expected ErrClosed but got: %v", err)
+	}
+}
+
+func TestHandlerPublishes(t *testing.T) {
+	broker := mqtttest.NewBroker()
+	defer broker.Close()
+	ctx := context.Background()
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	client := connect(t, broker, key)
+
+	// Handlers that publish wait for the read loop, which keeps reading
+	// while they fall behind.
+	var mu sync.Mutex
+	var handled int64
+	var errs []error
+	err := client.SubscribeCommands(ctx, func(subfolder string, payload []byte) {
+		publishCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		err := client.PublishEvent(publishCtx, "", payload)
+		mu.Lock()
+		handled++
+		if err != nil {
+			errs = append(errs, err)
+		}
+		mu.Unlock()
+	})
+	if err != nil {
+		t.Fatalf("Failed to subscribe to commands: %s", err.Error())
+	}
+	for i := 0; i < 200; i++ {
+		broker.Publish("/devices/device/commands", []byte(fmt.Sprint(i)), false)
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		mu.Lock()
+		n := handled
+		mu.Unlock()
+		if n+client.Dropped() == 200 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected 200 commands to be handled or dropped but got: %d handled, %d dropped", n, client.Dropped())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(errs) != 0 {
+		t.Errorf("Failed to publish from a handler: %v", errs[0])
+	}
+}
@@ -0,0 +1,193 @@
+// Copyright 2023 ClearBlade Inc.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package device
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/clearblade/go-iot/internal/mqtt"
+)
+
+// errConnectionLost is returned for requests whose connection dropped before
+// they were acknowledged.
+var errConnectionLost = errors.New("device: connection lost")
+
+// conn is a single authenticated MQTT connection.
+type conn struct {
+	nc        net.Conn
+	keepAlive time.Duration
+	expires   time.Time // when the token of the connection expires
+	deliver   func(topic string, payload []byte)
+
+	wmu sync.Mutex // serializes writes
+
+	mu      sync.Mutex
+	nextID  uint16
+	pending map[uint16]chan mqtt.Packet // acknowledgements by packet ID
+	done    chan struct{}
+	err     error
+}
+
+func (cn *conn) write(p mqtt.Packet) error {
+	cn.wmu.Lock()
+	defer cn.wmu.Unlock()
+	return mqtt.Write(cn.nc, p)
+}
+
+func (cn *conn) isDone() bool {
+	select {
+	case <-cn.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// close closes the connection, failing all requests waiting for an
+// acknowledgement. Only the first error is kept.
+func (cn *conn) close(err error) {
+	cn.mu.Lock()
+	defer cn.mu.Unlock()
+	if cn.isDone() {
+		return
+	}
+	cn.err = err
+	close(cn.done)
+	cn.nc.Close()
+}
+
+// disconnect closes the connection gracefully.
+func (cn *conn) disconnect() {
+	_ = cn.write(&mqtt.Disconnect{})
+	cn.close(errors.New("device: disconnected"))
+}
+
+// request sends a packet that needs an acknowledgement and waits for it.
+func (cn *conn) request(ctx context.Context, build func(id uint16) mqtt.Packet) (mqtt.Packet, error) {
+	cn.mu.Lock()
+	if cn.isDone() {
+		cn.mu.Unlock()
+		return nil, errConnectionLost
+	}
+	cn.nextID++
+	if cn.nextID == 0 {
+		cn.nextID++
+	}
+	id := cn.nextID
+	ack := make(chan mqtt.Packet, 1)
+	cn.pending[id] = ack
+	cn.mu.Unlock()
+	defer func() {
+		cn.mu.Lock()
+		delete(cn.pending, id)
+		cn.mu.Unlock()
+	}()
+
+	if err := cn.write(build(id)); err != nil {
+		cn.close(err)
+		return nil, fmt.Errorf("%w: %v", errConnectionLost, err)
+	}
+	select {
+	case p := <-ack:
+		return p, nil
+	case <-cn.done:
+		return nil, fmt.Errorf("%w: %v", errConnectionLost, cn.err)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (cn *conn) publish(ctx context.Context, topic string, payload []byte) error {
+	_, err := cn.request(ctx, func(id uint16) mqtt.Packet {
+		return &mqtt.Publish{Topic: topic, QoS: 1, PacketID: id, Payload: payload}
+	})
+	return err
+}
+
+func (cn *conn) subscribe(ctx context.Context, subs []*subscription) error {
+	p, err := cn.request(ctx, func(id uint16) mqtt.Packet {
+		sub := &mqtt.Subscribe{PacketID: id}
+		for _, s := range subs {
+			sub.Subscriptions = append(sub.Subscriptions, mqtt.Subscription{Filter: s.filter, QoS: s.qos})
+		}
+		return sub
+	})
+	if err != nil {
+		return err
+	}
+	ack, ok := p.(*mqtt.Suback)
+	if !ok {
+		return fmt.Errorf("device: expected SUBACK but got %T", p)
+	}
+	for i, code := range ack.ReturnCodes {
+		if code == mqtt.SubackFailure && i < len(subs) {
+			return fmt.Errorf("device: subscription to %s was rejected", subs[i].filter)
+		}
+	}
+	return nil
+}
+
+func (cn *conn) readLoop(br *bufio.Reader) {
+	for {
+		// The server answers pings sent every keep alive interval, so a
+		// silent connection is dead.
+		cn.nc.SetReadDeadline(time.Now().Add(cn.keepAlive * 3 / 2))
+		p, err := mqtt.Read(br)
+		if err != nil {
+			cn.close(err)
+			return
+		}
+		switch p := p.(type) {
+		case *mqtt.Publish:
+			cn.deliver(p.Topic, p.Payload)
+			if p.QoS > 0 {
+				if err := cn.write(&mqtt.Puback{PacketID: p.PacketID}); err != nil {
+					cn.close(err)
+					return
+				}
+			}
+		case *mqtt.Puback:
+			cn.acknowledge(p.PacketID, p)
+		case *mqtt.Suback:
+			cn.acknowledge(p.PacketID, p)
+		}
+	}
+}
+
+// acknowledge passes an acknowledgement to the request waiting for it.
+// Duplicates are dropped, so that they cannot block the read loop.
+func (cn *conn) acknowledge(id uint16, p mqtt.Packet) {
+	cn.mu.Lock()
+	ack := cn.pending[id]
+	cn.mu.Unlock()
+	if ack != nil {
+		select {
+		case ack <- p:
+		default:
+		}
+	}
+}
+
+func (cn *conn) pingLoop() {
+	ticker := time.NewTicker(cn.keepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := cn.write(&mqtt.Pingreq{}); err != nil {
+				cn.close(err)
+				return
+			}
+		case <-cn.done:
+			return
+		}
+	}
+}
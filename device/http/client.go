@@ -0,0 +1,281 @@
+// Copyright 2023 ClearBlade Inc.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package http implements a device client for the ClearBlade IoT Core HTTP
+// bridge, for devices that cannot keep an MQTT connection open. Enable it on
+// a registry with HttpConfig.HttpEnabledState set to "HTTP_ENABLED".
+//
+//	key, err := device.ParsePrivateKey(pemBytes)
+//	...
+//	client, err := http.NewClient(http.Config{
+//		Name:       iot.LocationName{Project: "my-project", Location: "us-central1"}.Registry("my-registry").Device("my-device"),
+//		PrivateKey: key,
+//	})
+//	...
+//	err = client.PublishEvent(ctx, "", []byte(`{"temperature":21}`))
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	iot "github.com/clearblade/go-iot"
+	"github.com/clearblade/go-iot/jwtauth"
+)
+
+const (
+	defaultTokenLifetime = time.Hour
+	defaultPollInterval  = 5 * time.Second
+	// maxTokenRefreshMargin is how long before it expires a token is
+	// replaced, at most.
+	maxTokenRefreshMargin = time.Minute
+)
+
+// Config configures a Client.
+type Config struct {
+	// Name is the name of the device.
+	Name iot.DeviceName
+
+	// PrivateKey signs the JWTs the client authenticates with. It must be
+	// an *rsa.PrivateKey (RS256) or a P-256 *ecdsa.PrivateKey (ES256)
+	// matching one of the device's credentials.
+	PrivateKey crypto.Signer
+
+	// Endpoint is the base URL of the HTTP bridge. The default is the
+	// ClearBlade bridge for the device's location,
+	// https://{location}-mqtt.clearblade.com.
+	Endpoint string
+
+	// HTTPClient sends the requests. The default is http.DefaultClient.
+	HTTPClient *http.Client
+
+	// TokenLifetime is how long each JWT is valid, at most
+	// jwtauth.MaxLifetime. Tokens are reused until shortly before they
+	// expire. The default is one hour.
+	TokenLifetime time.Duration
+
+	// Audience is the JWT audience. The default is the device's project.
+	Audience string
+
+	// PollInterval is how long Config waits between requests while no newer
+	// configuration is available. The default is five seconds.
+	PollInterval time.Duration
+}
+
+// Client is an HTTP bridge client for a single device. A Client is safe for
+// concurrent use.
+type Client struct {
+	cfg  Config
+	base string // URL of the device
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// DeviceConfig is a configuration returned by the bridge.
+type DeviceConfig struct {
+	// Version is the version of the configuration.
+	Version int64
+	// CloudUpdateTime is when the configuration was stored.
+	CloudUpdateTime string
+	// Data is the decoded configuration.
+	Data []byte
+}
+
+// NewClient returns a client for the device. No request is sent until a
+// method is called.
+func NewClient(cfg Config) (*Client, error) {
+	if err := cfg.Name.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.PrivateKey == nil {
+		return nil, errors.New("http: a private key is required")
+	}
+	if _, err := jwtauth.Algorithm(cfg.PrivateKey); err != nil {
+		return nil, err
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = fmt.Sprintf("https://%s-mqtt.clearblade.com", cfg.Name.Location)
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+	if cfg.TokenLifetime <= 0 {
+		cfg.TokenLifetime = defaultTokenLifetime
+	}
+	if cfg.Audience == "" {
+		cfg.Audience = cfg.Name.Project
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = defaultPollInterval
+	}
+	return &Client{
+		cfg:  cfg,
+		base: cfg.Endpoint + "/v1/" + cfg.Name.String(),
+	}, nil
+}
+
+// PublishEvent publishes a telemetry event, to the given subfolder if it is
+// not empty.
+func (c *Client) PublishEvent(ctx context.Context, subfolder string, payload []byte) error {
+	body := map[string]string{"binaryData": base64.StdEncoding.EncodeToString(payload)}
+	if subfolder != "" {
+		body["subFolder"] = subfolder
+	}
+	return c.do(ctx, http.MethodPost, c.base+":publishEvent", body, nil)
+}
+
+// SetState reports the device state.
+func (c *Client) SetState(ctx context.Context, state []byte) error {
+	body := map[string]interface{}{
+		"state": map[string]string{"binaryData": base64.StdEncoding.EncodeToString(state)},
+	}
+	return c.do(ctx, http.MethodPost, c.base+":setState", body, nil)
+}
+
+// Config returns the configuration of the device. If localVersion is zero,
+// the current configuration is returned right away. Otherwise localVersion
+// is the version the device already has, and Config long-polls the bridge
+// until a newer version is available or ctx is done.
+func (c *Client) Config(ctx context.Context, localVersion int64) (*DeviceConfig, error) {
+	u := c.base + "/config?local_version=" + url.QueryEscape(strconv.FormatInt(localVersion, 10))
+	for {
+		var res struct {
+			Version         string `json:"version"`
+			CloudUpdateTime string `json:"cloudUpdateTime"`
+			BinaryData      string `json:"binaryData"`
+		}
+		err := c.do(ctx, http.MethodGet, u, nil, &res)
+		var ierr *iot.Error
+		if errors.As(err, &ierr) && (ierr.Code == http.StatusNotModified || ierr.Status == "DEADLINE_EXCEEDED") {
+			// The bridge gave up waiting for a newer version.
+			err, res.Version = nil, ""
+		}
+		if err != nil {
+			return nil, err
+		}
+		if res.Version != "" {
+			version, err := strconv.ParseInt(res.Version, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("http: invalid config version %q", res.Version)
+			}
+			if localVersion == 0 || version > localVersion {
+				data, err := base64.StdEncoding.DecodeString(res.BinaryData)
+				if err != nil {
+					return nil, fmt.Errorf("http: invalid config data: %w", err)
+				}
+				return &DeviceConfig{Version: version, CloudUpdateTime: res.CloudUpdateTime, Data: data}, nil
+			}
+		}
+		select {
+		case <-time.After(c.cfg.PollInterval):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// do sends a request and decodes the response into target. A request
+// rejected as unauthenticated is sent once more with a new token, in case
+// the bridge considered the token expired before the client did.
+func (c *Client) do(ctx context.Context, method, u string, body, target interface{}) error {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	err := c.send(ctx, method, u, data, target, false)
+	if errors.Is(err, iot.ErrUnauthenticated) {
+		err = c.send(ctx, method, u, data, target, true)
+	}
+	return err
+}
+
+func (c *Client) send(ctx context.Context, method, u string, data []byte, target interface{}, newToken bool) error {
+	token, err := c.bearerToken(newToken)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Cache-Control", "no-cache")
+	if data != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	res, err := c.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return c.responseError(res.StatusCode, b)
+	}
+	if target == nil || len(b) == 0 {
+		return nil
+	}
+	return json.Unmarshal(b, target)
+}
+
+// bearerToken returns the current token, or signs a new one if it is about
+// to expire or renew is set.
+func (c *Client) bearerToken(renew bool) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	margin := c.cfg.TokenLifetime / 10
+	if margin > maxTokenRefreshMargin {
+		margin = maxTokenRefreshMargin
+	}
+	now := time.Now()
+	if !renew && c.token != "" && now.Before(c.expires.Add(-margin)) {
+		return c.token, nil
+	}
+	expires := now.Add(c.cfg.TokenLifetime)
+	token, err := jwtauth.Sign(c.cfg.PrivateKey, jwtauth.Claims{Audience: c.cfg.Audience, IssuedAt: now, ExpiresAt: expires})
+	if err != nil {
+		return "", err
+	}
+	c.token, c.expires = token, expires
+	return token, nil
+}
+
+// responseError returns an *iot.Error for a failed response.
+func (c *Client) responseError(code int, body []byte) error {
+	e := &iot.Error{Code: code, Resource: c.cfg.Name.String()}
+	var res struct {
+		Error struct {
+			Message string `json:"message"`
+			Status  string `json:"status"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &res) == nil {
+		e.Message, e.Status = res.Error.Message, res.Error.Status
+	} else {
+		e.Message = string(bytes.TrimSpace(body))
+	}
+	if e.Status == "" {
+		e.Status = iot.StatusForCode(code)
+	}
+	return e
+}
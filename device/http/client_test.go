@@ -0,0 +1,256 @@
+package http_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	iot "github.com/clearblade/go-iot"
+	devicehttp "github.com/clearblade/go-iot/device/http"
+)
+
+var deviceName = iot.LocationName{Project: "test-project", Location: "us-central1"}.Registry("registry").Device("device")
+
+// bridge is a stand-in for the HTTP bridge of a single device.
+type bridge struct {
+	key *ecdsa.PublicKey
+
+	mu            sync.Mutex
+	events        []string
+	states        []string
+	version       int64
+	config        string
+	updated       chan struct{} // closed when the config changes
+	rejectTokens  int
+	seenTokens    map[string]bool
+	pollTimeout   time.Duration
+	configQueries []string
+}
+
+func newBridge(key *ecdsa.PublicKey) *bridge {
+	return &bridge{key: key, updated: make(chan struct{}), seenTokens: make(map[string]bool), pollTimeout: 100 * time.Millisecond}
+}
+
+func (b *bridge) setConfig(config string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.version++
+	b.config = config
+	close(b.updated)
+	b.updated = make(chan struct{})
+}
+
+func (b *bridge) verify(token string) bool {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return false
+	}
+	sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	if len(sig) != 64 {
+		return false
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+	if !ecdsa.Verify(b.key, digest[:], r, s) {
+		return false
+	}
+	var claims struct{ Aud string }
+	b64, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	json.Unmarshal(b64, &claims)
+	return claims.Aud == "test-project"
+}
+
+func (b *bridge) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fail := func(code int, status string) {
+		w.WriteHeader(code)
+		fmt.Fprintf(w, `{"error":{"code":%d,"message":"%s","status":"%s"}}`, code, strings.ToLower(status), status)
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	b.mu.Lock()
+	reject := b.rejectTokens > 0
+	if reject {
+		b.rejectTokens--
+	}
+	b.seenTokens[token] = true
+	b.mu.Unlock()
+	if reject || !b.verify(token) {
+		fail(http.StatusUnauthorized, "UNAUTHENTICATED")
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/v1/")
+	switch {
+	case path == deviceName.String()+":publishEvent":
+		var body struct{ BinaryData, SubFolder string }
+		json.NewDecoder(r.Body).Decode(&body)
+		data, _ := base64.StdEncoding.DecodeString(body.BinaryData)
+		b.mu.Lock()
+		b.events = append(b.events, body.SubFolder+":"+string(data))
+		b.mu.Unlock()
+		w.Write([]byte("{}"))
+	case path == deviceName.String()+":setState":
+		var body struct{ State struct{ BinaryData string } }
+		json.NewDecoder(r.Body).Decode(&body)
+		data, _ := base64.StdEncoding.DecodeString(body.State.BinaryData)
+		b.mu.Lock()
+		b.states = append(b.states, string(data))
+		b.mu.Unlock()
+		w.Write([]byte("{}"))
+	case path == deviceName.String()+"/config":
+		local, _ := strconv.ParseInt(r.URL.Query().Get("local_version"), 10, 64)
+		b.mu.Lock()
+		b.configQueries = append(b.configQueries, r.URL.RawQuery)
+		version, updated, timeout := b.version, b.updated, b.pollTimeout
+		b.mu.Unlock()
+		if local != 0 && version <= local {
+			select {
+			case <-updated:
+			case <-time.After(timeout):
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+		b.mu.Lock()
+		fmt.Fprintf(w, `{"version":"%d","cloudUpdateTime":"2023-01-01T00:00:00Z","binaryData":"%s"}`,
+			b.version, base64.StdEncoding.EncodeToString([]byte(b.config)))
+		b.mu.Unlock()
+	default:
+		fail(http.StatusNotFound, "NOT_FOUND")
+	}
+}
+
+func newClient(t *testing.T) (*devicehttp.Client, *bridge) {
+	t.Helper()
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	b := newBridge(&key.PublicKey)
+	srv := httptest.NewServer(b)
+	t.Cleanup(srv.Close)
+	client, err := devicehttp.NewClient(devicehttp.Config{
+		Name:         deviceName,
+		PrivateKey:   key,
+		Endpoint:     srv.URL,
+		PollInterval: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create client: %s", err.Error())
+	}
+	return client, b
+}
+
+func TestPublishEventAndSetState(t *testing.T) {
+	client, b := newClient(t)
+	ctx := context.Background()
+
+	if err := client.PublishEvent(ctx, "", []byte("event")); err != nil {
+		t.Errorf("Failed to publish event: %s", err.Error())
+	}
+	if err := client.PublishEvent(ctx, "alerts", []byte("alert")); err != nil {
+		t.Errorf("Failed to publish event: %s", err.Error())
+	}
+	if err := client.SetState(ctx, []byte("state")); err != nil {
+		t.Errorf("Failed to set state: %s", err.Error())
+	}
+	if got := strings.Join(b.events, ","); got != ":event,alerts:alert" {
+		t.Errorf("Expected 2 events but got: %s", got)
+	}
+	if len(b.states) != 1 || b.states[0] != "state" {
+		t.Errorf("Expected 1 state but got: %v", b.states)
+	}
+	if len(b.seenTokens) != 1 {
+		t.Errorf("Expected the token to be reused but got %d tokens", len(b.seenTokens))
+	}
+}
+
+func TestTokenExpiry(t *testing.T) {
+	client, b := newClient(t)
+	ctx := context.Background()
+
+	b.rejectTokens = 1
+	if err := client.SetState(ctx, []byte("state")); err != nil {
+		t.Errorf("Failed to set state: %s", err.Error())
+	}
+	if len(b.seenTokens) != 2 {
+		t.Errorf("Expected a new token after the first was rejected but got %d tokens", len(b.seenTokens))
+	}
+
+	b.rejectTokens = 2
+	err := client.SetState(ctx, []byte("state"))
+	if !errors.Is(err, iot.ErrUnauthenticated) {
+		t.Errorf("Expected ErrUnauthenticated but got: %v", err)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	client, b := newClient(t)
+	ctx := context.Background()
+	b.setConfig("config-1")
+
+	config, err := client.Config(ctx, 0)
+	if err != nil {
+		t.Fatalf("Failed to get config: %s", err.Error())
+	}
+	if config.Version != 1 || string(config.Data) != "config-1" {
+		t.Errorf("Expected version 1 of the config but got: %d %s", config.Version, config.Data)
+	}
+
+	// The bridge times out the first poll, and the update arrives during a
+	// later one.
+	go func() {
+		time.Sleep(250 * time.Millisecond)
+		b.setConfig("config-2")
+	}()
+	config, err = client.Config(ctx, 1)
+	if err != nil {
+		t.Fatalf("Failed to get config: %s", err.Error())
+	}
+	if config.Version != 2 || string(config.Data) != "config-2" {
+		t.Errorf("Expected version 2 of the config but got: %d %s", config.Version, config.Data)
+	}
+	b.mu.Lock()
+	if n := len(b.configQueries); n < 3 || b.configQueries[1] != "local_version=1" {
+		t.Errorf("Expected several polls with the local version but got: %v", b.configQueries)
+	}
+	b.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err := client.Config(ctx, 2); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded but got: %v", err)
+	}
+}
+
+func TestStatusWithoutBody(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	for code, want := range map[int]error{
+		http.StatusBadRequest:         iot.ErrInvalidArgument,
+		http.StatusConflict:           iot.ErrAlreadyExists,
+		http.StatusTooManyRequests:    iot.ErrResourceExhausted,
+		http.StatusServiceUnavailable: iot.ErrUnavailable,
+	} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, http.StatusText(code), code)
+		}))
+		client, err := devicehttp.NewClient(devicehttp.Config{Name: deviceName, PrivateKey: key, Endpoint: srv.URL})
+		if err != nil {
+			t.Fatalf("Failed to create client: %s", err.Error())
+		}
+		if err := client.SetState(context.Background(), []byte("state")); !errors.Is(err, want) {
+			t.Errorf("Expected %v for status %d but got: %v", want, code, err)
+		}
+		srv.Close()
+	}
+}
@@ -0,0 +1,18 @@
+// Copyright 2023 ClearBlade Inc.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package device
+
+import (
+	"crypto"
+
+	"github.com/clearblade/go-iot/jwtauth"
+)
+
+// ParsePrivateKey parses a PEM encoded RSA or P-256 private key in PKCS #1,
+// SEC 1 or PKCS #8 form, matching a device's RSA_PEM, RSA_X509_PEM, ES256_PEM
+// or ES256_X509_PEM credential. It is the same as jwtauth.ParsePrivateKey.
+func ParsePrivateKey(pemBytes []byte) (crypto.Signer, error) {
+	return jwtauth.ParsePrivateKey(pemBytes)
+}
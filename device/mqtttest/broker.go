@@ -0,0 +1,310 @@
+// Copyright 2023 ClearBlade Inc.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package mqtttest provides an in-process MQTT 3.1.1 broker, for use in tests
+// of code built on the device package.
+//
+//	broker := mqtttest.NewBroker()
+//	defer broker.Close()
+//
+//	client, err := device.Connect(ctx, device.Config{Name: name, PrivateKey: key, Broker: broker.URL})
+package mqtttest
+
+import (
+	"bufio"
+	"net"
+	"sync"
+
+	"github.com/clearblade/go-iot/internal/mqtt"
+)
+
+// Message is a message published to the broker.
+type Message struct {
+	ClientID string // empty for messages injected with Broker.Publish
+	Topic    string
+	Payload  []byte
+	QoS      byte
+}
+
+// Broker is an MQTT broker supporting QoS 0 and 1, retained messages and
+// clean sessions only. The zero value is not usable; create one with
+// NewBroker.
+type Broker struct {
+	// URL is the address of the broker, of the form tcp://ipaddr:port.
+	URL string
+
+	// Authenticate, if set, is called with the fields of every CONNECT
+	// packet. Connections for which it returns an error are refused as
+	// having bad credentials. It must be set before the first connection.
+	Authenticate func(clientID, username, password string) error
+
+	ln net.Listener
+	wg sync.WaitGroup
+
+	mu          sync.Mutex
+	clients     map[string]*client // keyed by client ID
+	retained    map[string]Message // keyed by topic
+	messages    []Message
+	connections int
+	closed      bool
+}
+
+type client struct {
+	id string
+	nc net.Conn
+
+	wmu sync.Mutex
+
+	mu     sync.Mutex
+	subs   map[string]byte // granted QoS by topic filter
+	nextID uint16
+}
+
+// NewBroker starts a broker on a loopback address. The caller should call
+// Close when finished, to shut it down.
+func NewBroker() *Broker {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic("mqtttest: failed to listen on a port: " + err.Error())
+	}
+	b := &Broker{
+		URL:      "tcp://" + ln.Addr().String(),
+		ln:       ln,
+		clients:  make(map[string]*client),
+		retained: make(map[string]Message),
+	}
+	b.wg.Add(1)
+	go b.serve()
+	return b
+}
+
+// Close shuts down the broker, disconnecting all clients.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	b.closed = true
+	for _, c := range b.clients {
+		c.nc.Close()
+	}
+	b.mu.Unlock()
+	b.ln.Close()
+	b.wg.Wait()
+}
+
+// Publish sends a message to the clients subscribed to topic, as the IoT Core
+// bridge does for configs and commands. A retained message is also sent to
+// clients that subscribe later, until it is replaced.
+func (b *Broker) Publish(topic string, payload []byte, retain bool) {
+	b.route(Message{Topic: topic, Payload: payload, QoS: 1}, retain)
+}
+
+// Messages returns the messages published by clients on topics matching
+// filter, in the order they were received.
+func (b *Broker) Messages(filter string) []Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var msgs []Message
+	for _, m := range b.messages {
+		if mqtt.MatchTopic(filter, m.Topic) {
+			msgs = append(msgs, m)
+		}
+	}
+	return msgs
+}
+
+// Connections reports how many connections the broker has accepted.
+func (b *Broker) Connections() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.connections
+}
+
+// Disconnect drops the connection of the given client, without a DISCONNECT
+// packet, as a network failure would. It reports whether the client was
+// connected.
+func (b *Broker) Disconnect(clientID string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c, ok := b.clients[clientID]
+	if ok {
+		c.nc.Close()
+	}
+	return ok
+}
+
+func (b *Broker) serve() {
+	defer b.wg.Done()
+	for {
+		nc, err := b.ln.Accept()
+		if err != nil {
+			return
+		}
+		b.wg.Add(1)
+		go func() {
+			defer b.wg.Done()
+			b.handle(nc)
+		}()
+	}
+}
+
+func (b *Broker) handle(nc net.Conn) {
+	defer nc.Close()
+	br := bufio.NewReader(nc)
+	p, err := mqtt.Read(br)
+	if err != nil {
+		return
+	}
+	connect, ok := p.(*mqtt.Connect)
+	if !ok {
+		return
+	}
+	if b.Authenticate != nil {
+		if err := b.Authenticate(connect.ClientID, connect.Username, connect.Password); err != nil {
+			mqtt.Write(nc, &mqtt.Connack{ReturnCode: mqtt.RefusedBadUsernamePassword})
+			return
+		}
+	}
+
+	c := &client{id: connect.ClientID, nc: nc, subs: make(map[string]byte)}
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return
+	}
+	if old, ok := b.clients[c.id]; ok {
+		// A new connection with the same client ID takes over.
+		old.nc.Close()
+	}
+	b.clients[c.id] = c
+	b.connections++
+	b.mu.Unlock()
+	defer func() {
+		b.mu.Lock()
+		if b.clients[c.id] == c {
+			delete(b.clients, c.id)
+		}
+		b.mu.Unlock()
+	}()
+
+	if err := c.write(&mqtt.Connack{ReturnCode: mqtt.Accepted}); err != nil {
+		return
+	}
+	for {
+		p, err := mqtt.Read(br)
+		if err != nil {
+			return
+		}
+		switch p := p.(type) {
+		case *mqtt.Publish:
+			m := Message{ClientID: c.id, Topic: p.Topic, Payload: p.Payload, QoS: p.QoS}
+			b.mu.Lock()
+			b.messages = append(b.messages, m)
+			b.mu.Unlock()
+			b.route(m, p.Retain)
+			if p.QoS > 0 {
+				if err := c.write(&mqtt.Puback{PacketID: p.PacketID}); err != nil {
+					return
+				}
+			}
+		case *mqtt.Subscribe:
+			b.subscribe(c, p)
+		case *mqtt.Pingreq:
+			if err := c.write(&mqtt.Pingresp{}); err != nil {
+				return
+			}
+		case *mqtt.Disconnect:
+			return
+		}
+	}
+}
+
+func (b *Broker) subscribe(c *client, p *mqtt.Subscribe) {
+	ack := &mqtt.Suback{PacketID: p.PacketID}
+	c.mu.Lock()
+	for _, s := range p.Subscriptions {
+		qos := s.QoS
+		if qos > 1 {
+			qos = 1
+		}
+		c.subs[s.Filter] = qos
+		ack.ReturnCodes = append(ack.ReturnCodes, qos)
+	}
+	c.mu.Unlock()
+	if err := c.write(ack); err != nil {
+		return
+	}
+
+	b.mu.Lock()
+	var retained []Message
+	for topic, m := range b.retained {
+		for _, s := range p.Subscriptions {
+			if mqtt.MatchTopic(s.Filter, topic) {
+				retained = append(retained, m)
+				break
+			}
+		}
+	}
+	b.mu.Unlock()
+	for _, m := range retained {
+		c.send(m, true)
+	}
+}
+
+// route sends m to every subscribed client.
+func (b *Broker) route(m Message, retain bool) {
+	b.mu.Lock()
+	if retain {
+		if len(m.Payload) == 0 {
+			delete(b.retained, m.Topic)
+		} else {
+			b.retained[m.Topic] = m
+		}
+	}
+	clients := make([]*client, 0, len(b.clients))
+	for _, c := range b.clients {
+		clients = append(clients, c)
+	}
+	b.mu.Unlock()
+	for _, c := range clients {
+		c.send(m, false)
+	}
+}
+
+func (c *client) write(p mqtt.Packet) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	return mqtt.Write(c.nc, p)
+}
+
+// send sends m to the client if it is subscribed to its topic, flagged as
+// retained if it is sent on subscription.
+func (c *client) send(m Message, retained bool) {
+	c.mu.Lock()
+	qos, matched := byte(0), false
+	for filter, granted := range c.subs {
+		if mqtt.MatchTopic(filter, m.Topic) {
+			matched = true
+			if granted > qos {
+				qos = granted
+			}
+		}
+	}
+	if !matched {
+		c.mu.Unlock()
+		return
+	}
+	if m.QoS < qos {
+		qos = m.QoS
+	}
+	p := &mqtt.Publish{Topic: m.Topic, QoS: qos, Payload: m.Payload, Retain: retained}
+	if qos > 0 {
+		c.nextID++
+		if c.nextID == 0 {
+			c.nextID++
+		}
+		p.PacketID = c.nextID
+	}
+	c.mu.Unlock()
+	// Deliveries are not retried, so acknowledgements are not tracked.
+	c.write(p)
+}
@@ -0,0 +1,148 @@
+package deviceio_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	iot "github.com/clearblade/go-iot"
+	"github.com/clearblade/go-iot/credentials"
+	"github.com/clearblade/go-iot/deviceio"
+	"github.com/clearblade/go-iot/iottest"
+)
+
+func TestImportExport(t *testing.T) {
+	_, service := iottest.NewService(t, "test-project")
+	ctx := context.Background()
+	registry := iot.LocationName{Project: "test-project", Location: "us-central1"}.Registry("registry")
+	iottest.CreateRegistry(t, service, registry)
+
+	_, ecCred, err := credentials.Generate(credentials.Options{Algorithm: credentials.ES256})
+	if err != nil {
+		t.Fatalf("Failed to generate credential: %s", err.Error())
+	}
+	_, rsaCred, err := credentials.Generate(credentials.Options{Algorithm: credentials.RS256, Certificate: true})
+	if err != nil {
+		t.Fatalf("Failed to generate credential: %s", err.Error())
+	}
+	keyDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(keyDir, "sensor-1.pem"), []byte(rsaCred.PublicKey.Key), 0o600); err != nil {
+		t.Fatalf("Failed to write key: %s", err.Error())
+	}
+
+	schema := deviceio.Schema{
+		Columns: map[string]string{
+			"Serial":  deviceio.FieldID,
+			"Key":     deviceio.FieldCredential,
+			"Gateway": deviceio.FieldGateway,
+			"Type":    deviceio.FieldGatewayType,
+			"Auth":    deviceio.FieldGatewayAuthMethod,
+			"Station": "",
+		},
+		Metadata: true,
+		KeyDir:   keyDir,
+	}
+
+	invalid := "Serial,Key,Type,Auth,Gateway\n" +
+		"sensor-1,missing.pem,,,\n" +
+		"sensor-1,,SENSOR,ASSOCIATION_ONLY,\n"
+	rows, err := deviceio.ReadCSV(strings.NewReader(invalid), schema)
+	var rowErr *deviceio.RowError
+	if !errors.As(err, &rowErr) || rowErr.Line != 2 || rowErr.Column != "Key" {
+		t.Fatalf("Expected an error for the key on line 2 but got: %v", err)
+	}
+	for _, want := range []string{`line 3: column "Type": unknown gateway type "SENSOR"`, "line 3: device sensor-1 is also on line 2"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error to contain %q but got: %s", want, err.Error())
+		}
+	}
+	if len(rows) != 0 {
+		t.Errorf("Expected no valid rows but got: %d", len(rows))
+	}
+
+	file := "Serial,Key,Gateway,Type,Auth,Line,Station\n" +
+		"gateway-1,,,GATEWAY,ASSOCIATION_ONLY,A,north\n" +
+		"sensor-1,sensor-1.pem,gateway-1,,,A,north\n" +
+		"sensor-2,\"" + ecCred.PublicKey.Key + "\",gateway-1,,,B,south\n"
+	rows, err = deviceio.ReadCSV(strings.NewReader(file), schema)
+	if err != nil {
+		t.Fatalf("Failed to read CSV: %s", err.Error())
+	}
+	if len(rows) != 3 || rows[2].Line != 4 {
+		t.Fatalf("Expected 3 rows but got: %d", len(rows))
+	}
+	if got := rows[1].Device.Credentials[0].PublicKey.Format; got != "RSA_X509_PEM" {
+		t.Errorf("Expected format RSA_X509_PEM but got: %s", got)
+	}
+	if got := rows[2].Device.Credentials[0].PublicKey.Format; got != "ES256_PEM" {
+		t.Errorf("Expected format ES256_PEM but got: %s", got)
+	}
+
+	report, err := deviceio.Import(ctx, service, registry, rows, nil)
+	if err != nil {
+		t.Fatalf("Failed to import: %s", err.Error())
+	}
+	if err := report.Err(); err != nil {
+		t.Fatalf("Failed to import: %s", err.Error())
+	}
+	// Running it again binds the existing devices again.
+	report, err = deviceio.Import(ctx, service, registry, rows, nil)
+	if err != nil || report.Count(iot.BulkAlreadyExists) != 3 || report.Err() != nil {
+		t.Fatalf("Expected 3 existing devices but got: %d (%v)", report.Count(iot.BulkAlreadyExists), err)
+	}
+	bound, err := service.Projects.Locations.Registries.Devices.ListIn(registry).GatewayListOptionsAssociationsGatewayId("gateway-1").Do()
+	if err != nil || len(bound.Devices) != 2 {
+		t.Fatalf("Expected 2 bound devices but got: %v (%v)", bound, err)
+	}
+	sensor, err := service.Projects.Locations.Registries.Devices.GetByName(registry.Device("sensor-2")).Do()
+	if err != nil {
+		t.Fatalf("Failed to get device: %s", err.Error())
+	}
+	if sensor.Metadata["Line"] != "B" || len(sensor.Metadata) != 1 {
+		t.Errorf("Expected metadata Line=B but got: %v", sensor.Metadata)
+	}
+
+	if _, err := deviceio.Import(ctx, service, registry, []*deviceio.Row{{Line: 2, Device: &iot.Device{Id: "123"}}}, nil); err == nil {
+		t.Errorf("Expected numeric device ID to be rejected")
+	}
+
+	var out bytes.Buffer
+	if err := deviceio.Export(ctx, service, registry, &out, deviceio.NDJSON, []string{"id", "gatewayType", "metadata.Line"}); err != nil {
+		t.Fatalf("Failed to export: %s", err.Error())
+	}
+	want := `{"id":"gateway-1","gatewayType":"GATEWAY","metadata.Line":"A"}
+{"id":"sensor-1","gatewayType":"","metadata.Line":"A"}
+{"id":"sensor-2","gatewayType":"","metadata.Line":"B"}
+`
+	if out.String() != want {
+		t.Errorf("Expected NDJSON export:\n%s\nbut got:\n%s", want, out.String())
+	}
+
+	out.Reset()
+	if err := deviceio.Export(ctx, service, registry, &out, deviceio.CSV, nil); err != nil {
+		t.Fatalf("Failed to export: %s", err.Error())
+	}
+	exported, err := deviceio.ReadCSV(&out, deviceio.DefaultSchema())
+	if err != nil {
+		t.Fatalf("Failed to read export: %s", err.Error())
+	}
+	if len(exported) != 3 {
+		t.Fatalf("Expected 3 exported devices but got: %d", len(exported))
+	}
+	d := exported[2].Device
+	if d.Id != "sensor-2" || d.Metadata["Line"] != "B" || len(d.Metadata) != 1 || d.Credentials[0].PublicKey.Key != strings.TrimSpace(ecCred.PublicKey.Key) {
+		t.Errorf("Expected sensor-2 to round trip but got: %+v", d)
+	}
+	if exported[0].Device.GatewayConfig.GatewayAuthMethod != "ASSOCIATION_ONLY" {
+		t.Errorf("Expected gateway auth method to round trip but got: %+v", exported[0].Device.GatewayConfig)
+	}
+
+	nd, err := deviceio.ReadNDJSON(strings.NewReader(`{"id":"sensor-3","blocked":true,"metadata":{"Line":"C"}}`+"\n"), deviceio.DefaultSchema())
+	if err != nil || len(nd) != 1 || !nd[0].Device.Blocked || nd[0].Device.Metadata["Line"] != "C" {
+		t.Errorf("Expected an NDJSON row for sensor-3 but got: %v (%v)", nd, err)
+	}
+}
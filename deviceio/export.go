@@ -0,0 +1,275 @@
+// Copyright 2023 ClearBlade Inc.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package deviceio
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	iot "github.com/clearblade/go-iot"
+)
+
+// Format is the format of an export.
+type Format string
+
+const (
+	// CSV exports have a header row and a row per device.
+	CSV Format = "csv"
+	// NDJSON exports have a JSON object per device on each line.
+	NDJSON Format = "ndjson"
+)
+
+// DefaultColumns are the columns exported by default.
+var DefaultColumns = []string{
+	FieldID, "numId", FieldBlocked, FieldLogLevel, FieldGatewayType, FieldGatewayAuthMethod,
+	FieldCredential, FieldCredentialExpiration, "lastHeartbeatTime", "lastEventTime", FieldMetadata,
+}
+
+// outputFields maps the output-only fields to the API fields they are
+// listed with. The ID, name and numeric ID are always listed.
+var outputFields = map[string]string{
+	"name":               "",
+	"numId":              "",
+	"configVersion":      "config",
+	"lastHeartbeatTime":  "lastHeartbeatTime",
+	"lastEventTime":      "lastEventTime",
+	"lastStateTime":      "lastStateTime",
+	"lastConfigAckTime":  "lastConfigAckTime",
+	"lastConfigSendTime": "lastConfigSendTime",
+	"lastErrorTime":      "lastErrorTime",
+	"lastErrorStatus":    "lastErrorStatus",
+}
+
+// apiField returns the API field a column is listed with, and whether the
+// column can be exported.
+func apiField(column string) (string, bool) {
+	switch column {
+	case FieldID:
+		return "", true
+	case FieldCredential, FieldCredentialExpiration:
+		return "credentials", true
+	case FieldGatewayType, FieldGatewayAuthMethod:
+		return "gatewayConfig", true
+	case FieldBlocked, FieldLogLevel, FieldMetadata:
+		return column, true
+	}
+	if strings.HasPrefix(column, MetadataPrefix) && column != MetadataPrefix {
+		return FieldMetadata, true
+	}
+	f, ok := outputFields[column]
+	return f, ok
+}
+
+// Export writes the devices of a registry with the given columns, or
+// DefaultColumns if columns is nil. Devices are listed a page at a time and
+// written as they arrive, with only the fields the columns need.
+//
+// The credential columns hold the first credential of a device, metadata
+// holds all metadata as a JSON object, and lastErrorStatus the message of
+// the status. Bindings are not exported, so the gateway column cannot be
+// selected.
+func Export(ctx context.Context, service *iot.Service, parent iot.RegistryName, w io.Writer, format Format, columns []string) error {
+	if columns == nil {
+		columns = DefaultColumns
+	}
+	fields := map[string]bool{}
+	for _, column := range columns {
+		f, ok := apiField(column)
+		if !ok {
+			return fmt.Errorf("deviceio: column %q cannot be exported", column)
+		}
+		if f != "" {
+			fields[f] = true
+		}
+	}
+	var enc encoder
+	switch format {
+	case CSV:
+		enc = &csvEncoder{w: csv.NewWriter(w), columns: columns}
+	case NDJSON:
+		enc = &ndjsonEncoder{w: bufio.NewWriter(w), columns: columns}
+	default:
+		return fmt.Errorf("deviceio: unknown format %q", format)
+	}
+
+	call := service.Projects.Locations.Registries.Devices.ListIn(parent)
+	if len(fields) > 0 {
+		mask := make([]string, 0, len(fields))
+		for f := range fields {
+			mask = append(mask, f)
+		}
+		sort.Strings(mask)
+		call.FieldMask(strings.Join(mask, ","))
+	}
+	if err := enc.header(); err != nil {
+		return err
+	}
+	for d, err := range call.All(ctx) {
+		if err != nil {
+			return err
+		}
+		values := make([]interface{}, len(columns))
+		for i, column := range columns {
+			values[i] = value(d, column)
+		}
+		if err := enc.device(values); err != nil {
+			return err
+		}
+	}
+	return enc.flush()
+}
+
+// value returns the value of a column of d: a string, a bool for blocked,
+// an int64 for configVersion or a map for metadata.
+func value(d *iot.Device, column string) interface{} {
+	var cred *iot.DeviceCredential
+	if len(d.Credentials) > 0 {
+		cred = d.Credentials[0]
+	}
+	gateway := d.GatewayConfig
+	if gateway == nil {
+		gateway = &iot.GatewayConfig{}
+	}
+	switch column {
+	case FieldID:
+		return d.Id
+	case "name":
+		return d.Name
+	case "numId":
+		return strconv.FormatUint(d.NumId, 10)
+	case FieldBlocked:
+		return d.Blocked
+	case FieldLogLevel:
+		return d.LogLevel
+	case FieldGatewayType:
+		return gateway.GatewayType
+	case FieldGatewayAuthMethod:
+		return gateway.GatewayAuthMethod
+	case FieldCredential:
+		if cred != nil && cred.PublicKey != nil {
+			return cred.PublicKey.Key
+		}
+		return ""
+	case FieldCredentialExpiration:
+		if cred != nil {
+			return cred.ExpirationTime
+		}
+		return ""
+	case FieldMetadata:
+		if d.Metadata == nil {
+			return map[string]string{}
+		}
+		return d.Metadata
+	case "configVersion":
+		if d.Config != nil {
+			return d.Config.Version
+		}
+		return int64(0)
+	case "lastHeartbeatTime":
+		return d.LastHeartbeatTime
+	case "lastEventTime":
+		return d.LastEventTime
+	case "lastStateTime":
+		return d.LastStateTime
+	case "lastConfigAckTime":
+		return d.LastConfigAckTime
+	case "lastConfigSendTime":
+		return d.LastConfigSendTime
+	case "lastErrorTime":
+		return d.LastErrorTime
+	case "lastErrorStatus":
+		if d.LastErrorStatus != nil {
+			return d.LastErrorStatus.Message
+		}
+		return ""
+	}
+	return d.Metadata[strings.TrimPrefix(column, MetadataPrefix)]
+}
+
+type encoder interface {
+	header() error
+	device(values []interface{}) error
+	flush() error
+}
+
+type csvEncoder struct {
+	w       *csv.Writer
+	columns []string
+}
+
+func (e *csvEncoder) header() error {
+	return e.w.Write(e.columns)
+}
+
+func (e *csvEncoder) device(values []interface{}) error {
+	record := make([]string, len(values))
+	for i, v := range values {
+		switch v := v.(type) {
+		case string:
+			record[i] = v
+		case bool:
+			record[i] = strconv.FormatBool(v)
+		case int64:
+			record[i] = strconv.FormatInt(v, 10)
+		case map[string]string:
+			if len(v) > 0 {
+				b, err := json.Marshal(v)
+				if err != nil {
+					return err
+				}
+				record[i] = string(b)
+			}
+		}
+	}
+	return e.w.Write(record)
+}
+
+func (e *csvEncoder) flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// ndjsonEncoder writes the keys of each object in column order.
+type ndjsonEncoder struct {
+	w       *bufio.Writer
+	columns []string
+}
+
+func (e *ndjsonEncoder) header() error {
+	return nil
+}
+
+func (e *ndjsonEncoder) device(values []interface{}) error {
+	e.w.WriteByte('{')
+	for i, v := range values {
+		if i > 0 {
+			e.w.WriteByte(',')
+		}
+		key, err := json.Marshal(e.columns[i])
+		if err != nil {
+			return err
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		e.w.Write(key)
+		e.w.WriteByte(':')
+		e.w.Write(b)
+	}
+	e.w.WriteString("}\n")
+	return nil
+}
+
+func (e *ndjsonEncoder) flush() error {
+	return e.w.Flush()
+}
@@ -0,0 +1,88 @@
+// Copyright 2023 ClearBlade Inc.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package deviceio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+
+	iot "github.com/clearblade/go-iot"
+)
+
+// Import creates the devices of rows in a registry and binds them to their
+// gateways. It returns the report of BulkCreateDevices, in which devices
+// that could not be bound have failed.
+//
+// The device and gateway IDs of all rows are checked before any device is
+// created. If any are invalid, nothing is created and the error joins a
+// *RowError for every invalid row.
+//
+// Devices that exist already are not changed, but are still bound, so an
+// import that failed part way can be run again. Bindings are made once all
+// devices are created, so a file can hold gateways along with the devices
+// bound to them.
+func Import(ctx context.Context, service *iot.Service, parent iot.RegistryName, rows []*Row, opts *iot.BulkOptions) (*iot.BulkReport, error) {
+	if err := parent.Validate(); err != nil {
+		return nil, err
+	}
+	if err := checkIDs(parent, rows); err != nil {
+		return nil, err
+	}
+
+	devices := service.Projects.Locations.Registries.Devices
+	report := devices.BulkCreateDevices(ctx, parent, rowDevices(rows), opts)
+	for _, res := range report.Results {
+		gateway := rows[res.Index].Gateway
+		if gateway == "" || res.Status == iot.BulkFailed {
+			continue
+		}
+		_, err := service.Projects.Locations.Registries.BindDeviceToGatewayIn(parent, &iot.BindDeviceToGatewayRequest{
+			DeviceId:  res.Name.Device,
+			GatewayId: gateway,
+		}).Context(ctx).Do()
+		if err != nil {
+			res.Status, res.Err = iot.BulkFailed, fmt.Errorf("binding to gateway %s: %w", gateway, err)
+		}
+	}
+	return report, nil
+}
+
+// checkIDs checks the device and gateway IDs of rows.
+func checkIDs(parent iot.RegistryName, rows []*Row) error {
+	var errs []error
+	seen := map[string]int{}
+	for _, row := range rows {
+		name := parent.Device(row.Device.Id)
+		switch prev, ok := seen[row.Device.Id]; {
+		case ok:
+			errs = append(errs, &RowError{Line: row.Line, Err: fmt.Errorf("device %s is also on line %d", row.Device.Id, prev)})
+		case name.IsNumeric():
+			errs = append(errs, &RowError{Line: row.Line, Err: fmt.Errorf("numeric device ID %s: numeric IDs are assigned by the server", row.Device.Id)})
+		default:
+			if err := name.Validate(); err != nil {
+				errs = append(errs, &RowError{Line: row.Line, Err: err})
+			}
+		}
+		seen[row.Device.Id] = row.Line
+		if row.Gateway != "" {
+			if err := parent.Device(row.Gateway).Validate(); err != nil {
+				errs = append(errs, &RowError{Line: row.Line, Err: fmt.Errorf("gateway: %w", err)})
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func rowDevices(rows []*Row) iter.Seq[*iot.Device] {
+	return func(yield func(*iot.Device) bool) {
+		for _, row := range rows {
+			if !yield(row.Device) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,460 @@
+// Copyright 2023 ClearBlade Inc.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package deviceio reads device lists from CSV and NDJSON files, creates the
+// devices, and exports the devices of a registry to either format:
+//
+//	f, err := os.Open("devices.csv")
+//	...
+//	rows, err := deviceio.ReadCSV(f, deviceio.DefaultSchema())
+//	...
+//	report, err := deviceio.Import(ctx, service, registry, rows, nil)
+//	...
+//	err = deviceio.Export(ctx, service, registry, w, deviceio.CSV, nil)
+//
+// A CSV file starts with a header row naming its columns. An NDJSON file
+// holds a JSON object per line, whose keys are the columns. A Schema maps
+// the columns onto the fields below; empty values leave a field unset.
+//
+//	id                        device ID, required
+//	credential                public key or X.509 certificate, as PEM or
+//	                          the path of a PEM file; the format is
+//	                          detected from the PEM data
+//	credentialExpirationTime  RFC 3339 expiration of the row's credentials
+//	gateway                   ID of a gateway to bind the device to
+//	gatewayType               GATEWAY or NON_GATEWAY
+//	gatewayAuthMethod         auth method of a gateway
+//	blocked                   true or false
+//	logLevel                  NONE, ERROR, INFO or DEBUG
+//	metadata                  JSON object of metadata
+//	metadata.<key>            metadata value for key
+//
+// Several columns can map to credential, for devices with more than one
+// key. Export also writes the output-only fields name, numId,
+// configVersion, lastHeartbeatTime, lastEventTime, lastStateTime,
+// lastConfigAckTime, lastConfigSendTime, lastErrorTime and lastErrorStatus,
+// which are ignored when reading.
+package deviceio
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/x509"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	iot "github.com/clearblade/go-iot"
+	"github.com/clearblade/go-iot/jwtauth"
+)
+
+// The fields columns are mapped onto.
+const (
+	FieldID                   = "id"
+	FieldCredential           = "credential"
+	FieldCredentialExpiration = "credentialExpirationTime"
+	FieldGateway              = "gateway"
+	FieldGatewayType          = "gatewayType"
+	FieldGatewayAuthMethod    = "gatewayAuthMethod"
+	FieldBlocked              = "blocked"
+	FieldLogLevel             = "logLevel"
+	FieldMetadata             = "metadata"
+	// MetadataPrefix followed by a key is the field of a metadata value.
+	MetadataPrefix = "metadata."
+)
+
+// inputFields are the fields that can be read.
+var inputFields = map[string]bool{
+	FieldID:                   true,
+	FieldCredential:           true,
+	FieldCredentialExpiration: true,
+	FieldGateway:              true,
+	FieldGatewayType:          true,
+	FieldGatewayAuthMethod:    true,
+	FieldBlocked:              true,
+	FieldLogLevel:             true,
+	FieldMetadata:             true,
+}
+
+var (
+	gatewayTypes       = map[string]bool{"GATEWAY": true, "NON_GATEWAY": true}
+	gatewayAuthMethods = map[string]bool{"ASSOCIATION_ONLY": true, "DEVICE_AUTH_TOKEN_ONLY": true, "ASSOCIATION_AND_DEVICE_AUTH_TOKEN": true}
+	logLevels          = map[string]bool{"NONE": true, "ERROR": true, "INFO": true, "DEBUG": true}
+)
+
+// Schema maps the columns of a file onto device fields.
+type Schema struct {
+	// Columns maps column names to fields. An empty field ignores the
+	// column. If Columns is nil, columns named after a field are mapped to
+	// it.
+	Columns map[string]string
+
+	// Metadata maps the columns that are not mapped otherwise to metadata
+	// values with the column name as key. Otherwise they are ignored.
+	Metadata bool
+
+	// KeyDir is the directory relative credential paths are read from. The
+	// default is the current directory.
+	KeyDir string
+}
+
+// DefaultSchema returns the schema of files with columns named after the
+// fields, and metadata in the other columns. It reads the files Export
+// writes.
+func DefaultSchema() Schema {
+	return Schema{Metadata: true}
+}
+
+// field returns the field of a column, or "" if the column is ignored.
+func (s Schema) field(column string) string {
+	if s.Columns != nil {
+		if f, ok := s.Columns[column]; ok {
+			return f
+		}
+	} else if inputFields[column] || strings.HasPrefix(column, MetadataPrefix) {
+		return column
+	} else if _, ok := outputFields[column]; ok {
+		return ""
+	}
+	if s.Metadata {
+		return MetadataPrefix + column
+	}
+	return ""
+}
+
+// validate checks that the schema maps columns onto known fields.
+func (s Schema) validate() error {
+	for column, f := range s.Columns {
+		if f != "" && !inputFields[f] && (!strings.HasPrefix(f, MetadataPrefix) || f == MetadataPrefix) {
+			return fmt.Errorf("deviceio: column %q is mapped to unknown field %q", column, f)
+		}
+	}
+	return nil
+}
+
+// Row is a device read from a file.
+type Row struct {
+	// Line is the line of the row in the file.
+	Line   int
+	Device *iot.Device
+	// Gateway is the ID of the gateway to bind the device to, if any.
+	Gateway string
+}
+
+// RowError is an invalid value in a row of a file.
+type RowError struct {
+	Line int
+	// Column is the column of the value, or "" if the row as a whole is
+	// invalid.
+	Column string
+	Err    error
+}
+
+func (e *RowError) Error() string {
+	if e.Column == "" {
+		return fmt.Sprintf("line %d: %s", e.Line, e.Err)
+	}
+	return fmt.Sprintf("line %d: column %q: %s", e.Line, e.Column, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// ReadCSV reads the rows of a CSV file with a header row.
+//
+// Every row is checked. If any are invalid, the returned error joins a
+// *RowError for every invalid value, and the rows hold only the valid ones.
+func ReadCSV(r io.Reader, schema Schema) ([]*Row, error) {
+	if err := schema.validate(); err != nil {
+		return nil, err
+	}
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("deviceio: reading header: %w", err)
+	}
+	rd := newReader(schema)
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("deviceio: %w", err)
+		}
+		line, _ := cr.FieldPos(0)
+		if len(record) != len(header) {
+			rd.errs = append(rd.errs, &RowError{Line: line, Err: fmt.Errorf("%d values for %d columns", len(record), len(header))})
+			continue
+		}
+		rd.read(line, header, record)
+	}
+	return rd.result()
+}
+
+// ReadNDJSON reads the rows of an NDJSON file. Values may be JSON strings,
+// numbers or booleans, and the metadata field may also be an object.
+//
+// Every row is checked as by ReadCSV.
+func ReadNDJSON(r io.Reader, schema Schema) ([]*Row, error) {
+	if err := schema.validate(); err != nil {
+		return nil, err
+	}
+	rd := newReader(schema)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(data, &object); err != nil {
+			rd.errs = append(rd.errs, &RowError{Line: line, Err: err})
+			continue
+		}
+		columns := make([]string, 0, len(object))
+		for column := range object {
+			columns = append(columns, column)
+		}
+		sort.Strings(columns)
+		values := make([]string, len(columns))
+		ok := true
+		for i, column := range columns {
+			v, err := jsonValue(object[column])
+			if err != nil {
+				rd.errs = append(rd.errs, &RowError{Line: line, Column: column, Err: err})
+				ok = false
+			}
+			values[i] = v
+		}
+		if ok {
+			rd.read(line, columns, values)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("deviceio: %w", err)
+	}
+	return rd.result()
+}
+
+// jsonValue returns the text of an NDJSON value.
+func jsonValue(raw json.RawMessage) (string, error) {
+	var v interface{}
+	d := json.NewDecoder(bytes.NewReader(raw))
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil {
+		return "", err
+	}
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case map[string]interface{}:
+		return string(raw), nil
+	}
+	return "", errors.New("arrays are not supported")
+}
+
+// reader turns the values of rows into devices.
+type reader struct {
+	schema Schema
+	rows   []*Row
+	errs   []error
+	ids    map[string]int
+}
+
+func newReader(schema Schema) *reader {
+	return &reader{schema: schema, ids: map[string]int{}}
+}
+
+// read adds the row with values for columns, or the errors in it.
+func (rd *reader) read(line int, columns, values []string) {
+	row := &Row{Line: line, Device: &iot.Device{}}
+	d := row.Device
+	var expiration, authMethod string
+	nerrs := len(rd.errs)
+	fail := func(column string, err error) {
+		rd.errs = append(rd.errs, &RowError{Line: line, Column: column, Err: err})
+	}
+
+	for i, column := range columns {
+		f, v := rd.schema.field(column), strings.TrimSpace(values[i])
+		if f == "" || v == "" {
+			continue
+		}
+		switch {
+		case f == FieldID:
+			d.Id = v
+		case f == FieldCredential:
+			cred, err := rd.credential(v)
+			if err != nil {
+				fail(column, err)
+				continue
+			}
+			d.Credentials = append(d.Credentials, &iot.DeviceCredential{PublicKey: cred})
+		case f == FieldCredentialExpiration:
+			if _, err := time.Parse(time.RFC3339, v); err != nil {
+				fail(column, fmt.Errorf("invalid RFC 3339 time %q", v))
+				continue
+			}
+			expiration = v
+		case f == FieldGateway:
+			row.Gateway = v
+		case f == FieldGatewayType:
+			if !gatewayTypes[v] {
+				fail(column, fmt.Errorf("unknown gateway type %q", v))
+				continue
+			}
+			if d.GatewayConfig == nil {
+				d.GatewayConfig = &iot.GatewayConfig{}
+			}
+			d.GatewayConfig.GatewayType = v
+		case f == FieldGatewayAuthMethod:
+			if !gatewayAuthMethods[v] {
+				fail(column, fmt.Errorf("unknown gateway auth method %q", v))
+				continue
+			}
+			authMethod = v
+		case f == FieldBlocked:
+			blocked, err := strconv.ParseBool(v)
+			if err != nil {
+				fail(column, fmt.Errorf("invalid boolean %q", v))
+				continue
+			}
+			d.Blocked = blocked
+		case f == FieldLogLevel:
+			if !logLevels[v] {
+				fail(column, fmt.Errorf("unknown log level %q", v))
+				continue
+			}
+			d.LogLevel = v
+		case f == FieldMetadata:
+			var metadata map[string]string
+			if err := json.Unmarshal([]byte(v), &metadata); err != nil {
+				fail(column, fmt.Errorf("metadata must be a JSON object of strings: %w", err))
+				continue
+			}
+			for key, value := range metadata {
+				setMetadata(d, key, value)
+			}
+		default:
+			setMetadata(d, strings.TrimPrefix(f, MetadataPrefix), v)
+		}
+	}
+
+	if d.Id == "" {
+		fail("", errors.New("missing device ID"))
+	} else if prev, ok := rd.ids[d.Id]; ok {
+		fail("", fmt.Errorf("device %s is also on line %d", d.Id, prev))
+	} else {
+		rd.ids[d.Id] = line
+	}
+	if expiration != "" {
+		if len(d.Credentials) == 0 {
+			fail("", errors.New("credential expiration time without a credential"))
+		}
+		for _, cred := range d.Credentials {
+			cred.ExpirationTime = expiration
+		}
+	}
+	isGateway := d.GatewayConfig != nil && d.GatewayConfig.GatewayType == "GATEWAY"
+	if authMethod != "" {
+		if !isGateway {
+			fail("", errors.New("gateway auth method of a device that is not a gateway"))
+		} else {
+			d.GatewayConfig.GatewayAuthMethod = authMethod
+		}
+	}
+	if row.Gateway != "" && isGateway {
+		fail("", errors.New("gateways cannot be bound to a gateway"))
+	}
+	if len(rd.errs) == nerrs {
+		rd.rows = append(rd.rows, row)
+	}
+}
+
+func (rd *reader) result() ([]*Row, error) {
+	return rd.rows, errors.Join(rd.errs...)
+}
+
+func setMetadata(d *iot.Device, key, value string) {
+	if d.Metadata == nil {
+		d.Metadata = map[string]string{}
+	}
+	d.Metadata[key] = value
+}
+
+// credential returns the credential for the PEM data or file in v.
+func (rd *reader) credential(v string) (*iot.PublicKeyCredential, error) {
+	data := v
+	if !strings.HasPrefix(v, "-----BEGIN") {
+		path := v
+		if !filepath.IsAbs(path) && rd.schema.KeyDir != "" {
+			path = filepath.Join(rd.schema.KeyDir, path)
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		data = string(b)
+	}
+	return PublicKeyCredential(data)
+}
+
+// PublicKeyCredential returns the credential for a PEM encoded public key
+// or X.509 certificate, with the format detected from its PEM block type
+// and key algorithm.
+func PublicKeyCredential(data string) (*iot.PublicKeyCredential, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, errors.New("deviceio: no PEM data found in credential")
+	}
+	var key interface{}
+	var suffix string
+	switch block.Type {
+	case "PUBLIC KEY":
+		k, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("deviceio: invalid public key: %w", err)
+		}
+		key, suffix = k, "_PEM"
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("deviceio: invalid certificate: %w", err)
+		}
+		key, suffix = cert.PublicKey, "_X509_PEM"
+	default:
+		return nil, fmt.Errorf("deviceio: unsupported PEM block %q, expected a public key or certificate", block.Type)
+	}
+	alg, err := jwtauth.Algorithm(key)
+	if err != nil {
+		return nil, err
+	}
+	format := "ES256" + suffix
+	if alg == "RS256" {
+		format = "RSA" + suffix
+	}
+	return &iot.PublicKeyCredential{Format: format, Key: data}, nil
+}
@@ -0,0 +1,190 @@
+// Copyright 2023 ClearBlade Inc.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"google.golang.org/api/googleapi"
+)
+
+// Sentinel errors matched by the *Error values returned from calls and
+// GetRegistryCredentials. Use them with errors.Is:
+//
+//	if errors.Is(err, iot.ErrDeviceNotFound) {
+//		...
+//	}
+var (
+	// ErrNotFound matches any error for a resource that does not exist.
+	ErrNotFound = errors.New("iot: not found")
+	// ErrRegistryNotFound matches errors for a registry that does not exist.
+	ErrRegistryNotFound = errors.New("iot: registry not found")
+	// ErrDeviceNotFound matches errors for a device that does not exist.
+	ErrDeviceNotFound = errors.New("iot: device not found")
+	// ErrAlreadyExists matches errors for creating a resource that exists.
+	ErrAlreadyExists = errors.New("iot: already exists")
+	// ErrInvalidArgument matches errors for rejected request parameters.
+	ErrInvalidArgument = errors.New("iot: invalid argument")
+	// ErrFailedPrecondition matches errors for requests that are not allowed
+	// in the current state of a resource, such as deleting a non-empty registry.
+	ErrFailedPrecondition = errors.New("iot: failed precondition")
+	// ErrUnauthenticated matches errors for missing or rejected tokens.
+	ErrUnauthenticated = errors.New("iot: unauthenticated")
+	// ErrPermissionDenied matches errors for tokens without the required access.
+	ErrPermissionDenied = errors.New("iot: permission denied")
+	// ErrResourceExhausted matches errors for requests that were rate limited.
+	ErrResourceExhausted = errors.New("iot: resource exhausted")
+	// ErrUnavailable matches errors for server failures that may be retried.
+	ErrUnavailable = errors.New("iot: unavailable")
+	// ErrInvalidName matches errors for resource names that do not have the
+	// form the call requires, detected before any request is sent.
+	ErrInvalidName = errors.New("iot: invalid resource name")
+	// ErrNotImplemented is returned by calls ClearBlade IoT Core does not
+	// support.
+	ErrNotImplemented = errors.New("iot: not implemented")
+)
+
+// Error is a failure reported by ClearBlade IoT Core. Err holds the
+// underlying error, such as the *googleapi.Error of a webhook response.
+type Error struct {
+	// Code is the HTTP status code of the response.
+	Code int
+	// Status is the canonical status reported by the server, such as
+	// "NOT_FOUND", or derived from Code if the server did not report one.
+	Status string
+	// Message is the error message reported by the server.
+	Message string
+	// Resource is the name of the resource the call was made for.
+	Resource string
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *Error) Error() string {
+	msg := e.Message
+	if msg == "" {
+		msg = http.StatusText(e.Code)
+	}
+	if e.Resource != "" {
+		return fmt.Sprintf("iot: %s: %s (%d %s)", e.Resource, msg, e.Code, e.Status)
+	}
+	return fmt.Sprintf("iot: %s (%d %s)", msg, e.Code, e.Status)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether e matches one of the sentinel errors of this package.
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.Status == "NOT_FOUND"
+	case ErrRegistryNotFound:
+		return e.Status == "NOT_FOUND" && resourceKind(e.Resource) == "registry"
+	case ErrDeviceNotFound:
+		return e.Status == "NOT_FOUND" && resourceKind(e.Resource) == "device"
+	case ErrAlreadyExists:
+		return e.Status == "ALREADY_EXISTS"
+	case ErrInvalidArgument:
+		return e.Status == "INVALID_ARGUMENT"
+	case ErrFailedPrecondition:
+		return e.Status == "FAILED_PRECONDITION"
+	case ErrUnauthenticated:
+		return e.Status == "UNAUTHENTICATED"
+	case ErrPermissionDenied:
+		return e.Status == "PERMISSION_DENIED"
+	case ErrResourceExhausted:
+		return e.Status == "RESOURCE_EXHAUSTED"
+	case ErrUnavailable:
+		return e.Retryable()
+	}
+	return false
+}
+
+// Retryable reports whether the request may succeed if it is sent again.
+func (e *Error) Retryable() bool {
+	return e.Code >= 500 || e.Code == http.StatusTooManyRequests || e.Code == http.StatusRequestTimeout
+}
+
+// StatusForCode returns the canonical status, as used in Error.Status, for
+// an HTTP status code. It is used for responses without a status of their
+// own.
+func StatusForCode(code int) string {
+	switch code {
+	case http.StatusBadRequest:
+		return "INVALID_ARGUMENT"
+	case http.StatusUnauthorized:
+		return "UNAUTHENTICATED"
+	case http.StatusForbidden:
+		return "PERMISSION_DENIED"
+	case http.StatusNotFound:
+		return "NOT_FOUND"
+	case http.StatusConflict:
+		return "ALREADY_EXISTS"
+	case http.StatusPreconditionFailed:
+		return "FAILED_PRECONDITION"
+	case http.StatusTooManyRequests:
+		return "RESOURCE_EXHAUSTED"
+	case http.StatusNotImplemented:
+		return "UNIMPLEMENTED"
+	case http.StatusServiceUnavailable:
+		return "UNAVAILABLE"
+	case http.StatusGatewayTimeout, http.StatusRequestTimeout:
+		return "DEADLINE_EXCEEDED"
+	}
+	if code >= 500 {
+		return "INTERNAL"
+	}
+	return "UNKNOWN"
+}
+
+// resourceKind returns "registry" or "device" for registry and device names.
+func resourceKind(name string) string {
+	parts := strings.Split(name, "/")
+	switch {
+	case len(parts) == 6 && parts[4] == "registries":
+		return "registry"
+	case len(parts) == 8 && parts[6] == "devices":
+		return "device"
+	}
+	return ""
+}
+
+// newError returns an *Error for a failed response about resource. Other
+// errors are returned unchanged.
+func newError(err error, resource string) error {
+	var herr *googleapi.Error
+	if !errors.As(err, &herr) {
+		return err
+	}
+	e := &Error{Code: herr.Code, Message: herr.Message, Resource: resource, Err: err}
+	if e.Message == "" {
+		e.Message = strings.TrimSpace(herr.Body)
+	}
+	var body struct {
+		Error struct {
+			Status string `json:"status"`
+		} `json:"error"`
+	}
+	if json.Unmarshal([]byte(herr.Body), &body) == nil {
+		e.Status = body.Error.Status
+	}
+	if e.Status == "" || strings.Contains(e.Status, " ") {
+		// Some webhooks report the HTTP status text instead.
+		e.Status = StatusForCode(e.Code)
+	}
+	return e
+}
+
+// invalidNameError returns the error for a resource name that does not
+// match the form required by a call.
+func invalidNameError(name string, err error) error {
+	return fmt.Errorf("%w %q: %v", ErrInvalidName, name, err)
+}
@@ -0,0 +1,53 @@
+package iot_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	iot "github.com/clearblade/go-iot"
+	"github.com/clearblade/go-iot/iottest"
+	"google.golang.org/api/googleapi"
+)
+
+func TestErrorsMatchSentinels(t *testing.T) {
+	fake, service := iottest.NewService(t, "test-project", iot.WithRetryConfig(nil))
+	parent := "projects/test-project/locations/us-central1"
+	registry := parent + "/registries/registry"
+	registries := service.Projects.Locations.Registries
+	if _, err := registries.Create(parent, &iot.DeviceRegistry{Id: "registry"}).Do(); err != nil {
+		t.Fatalf("Failed to create registry: %s", err.Error())
+	}
+
+	_, err := registries.Devices.Get(registry + "/devices/missing").Do()
+	var apiErr *iot.Error
+	if !errors.As(err, &apiErr) || apiErr.Code != http.StatusNotFound || apiErr.Resource != registry+"/devices/missing" {
+		t.Errorf("Expected a not found *iot.Error for the device but got: %v", err)
+	}
+	if !errors.Is(err, iot.ErrDeviceNotFound) || !errors.Is(err, iot.ErrNotFound) || errors.Is(err, iot.ErrRegistryNotFound) {
+		t.Errorf("Expected a device not found error but got: %v", err)
+	}
+	var herr *googleapi.Error
+	if !errors.As(err, &herr) {
+		t.Errorf("Expected the *googleapi.Error to be wrapped but got: %v", err)
+	}
+
+	if _, err := registries.Devices.Get(parent + "/registries/missing/devices/device").Do(); !errors.Is(err, iot.ErrRegistryNotFound) {
+		t.Errorf("Expected a registry not found error but got: %v", err)
+	}
+	if _, err := registries.Create(parent, &iot.DeviceRegistry{Id: "registry"}).Do(); !errors.Is(err, iot.ErrAlreadyExists) {
+		t.Errorf("Expected an already exists error but got: %v", err)
+	}
+	if _, err := registries.Devices.Get("devices/device").Do(); !errors.Is(err, iot.ErrInvalidName) {
+		t.Errorf("Expected an invalid name error but got: %v", err)
+	}
+	if _, err := registries.GetIamPolicy(registry, &iot.GetIamPolicyRequest{}).Do(); !errors.Is(err, iot.ErrNotImplemented) {
+		t.Errorf("Expected a not implemented error but got: %v", err)
+	}
+
+	fake.FailNext(http.StatusServiceUnavailable, 1)
+	_, err = registries.Get(registry).Do()
+	if !errors.Is(err, iot.ErrUnavailable) || !errors.As(err, &apiErr) || !apiErr.Retryable() || apiErr.Status != "UNAVAILABLE" {
+		t.Errorf("Expected a retryable unavailable error but got: %v", err)
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright 2023 ClearBlade Inc.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iot
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/clearblade/go-iot/cblib/gensupport"
+)
+
+// fieldSelection is a parsed partial response selector. Each key is a JSON
+// field name; a nil value selects the whole field, otherwise only the
+// fields selected within it.
+type fieldSelection map[string]fieldSelection
+
+// parseFields parses a partial response selector as built by
+// googleapi.CombineFields, such as "devices(id,config/version),nextPageToken".
+// Nested paths may be separated by "/" or ".", and snake_case names are
+// accepted for their camelCase JSON fields.
+func parseFields(fields string) (fieldSelection, error) {
+	selection, rest, err := parseFieldList(fields)
+	if err == nil && rest != "" {
+		err = fmt.Errorf("unexpected %q", rest)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid fields %q: %w", fields, err)
+	}
+	return selection, nil
+}
+
+// parseFieldList parses comma-separated selectors from the start of s up to
+// an unmatched ")" or the end of s, and returns what follows them.
+func parseFieldList(s string) (fieldSelection, string, error) {
+	selection := make(fieldSelection)
+	for {
+		end := strings.IndexAny(s, ",()")
+		if end < 0 {
+			end = len(s)
+		}
+		path := strings.FieldsFunc(s[:end], func(r rune) bool { return r == '/' || r == '.' || r == ' ' })
+		if len(path) == 0 {
+			return nil, s, fmt.Errorf("empty field name")
+		}
+		s = s[end:]
+		var sub fieldSelection
+		if strings.HasPrefix(s, "(") {
+			var err error
+			if sub, s, err = parseFieldList(s[1:]); err != nil {
+				return nil, s, err
+			}
+			if !strings.HasPrefix(s, ")") {
+				return nil, s, fmt.Errorf("missing \")\"")
+			}
+			s = s[1:]
+		}
+		selection.insert(path, sub)
+		if !strings.HasPrefix(s, ",") {
+			return selection, s, nil
+		}
+		s = s[1:]
+	}
+}
+
+// insert selects the field at path, narrowed to sub if it is not nil.
+func (sel fieldSelection) insert(path []string, sub fieldSelection) {
+	name := jsonFieldName(path[0])
+	child, ok := sel[name]
+	if ok && child == nil {
+		// The whole field is already selected.
+		return
+	}
+	if len(path) == 1 && sub == nil {
+		sel[name] = nil
+		return
+	}
+	if child == nil {
+		child = make(fieldSelection)
+		sel[name] = child
+	}
+	if len(path) > 1 {
+		child.insert(path[1:], sub)
+		return
+	}
+	for k, v := range sub {
+		child.insert([]string{k}, v)
+	}
+}
+
+// jsonFieldName converts a snake_case field name such as
+// "last_heartbeat_time" to the camelCase name used in JSON.
+func jsonFieldName(name string) string {
+	parts := strings.Split(name, "_")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// prune returns v reduced to the selected fields. Selections apply to each
+// element of an array.
+func (sel fieldSelection) prune(v interface{}) interface{} {
+	if sel == nil {
+		return v
+	}
+	switch v := v.(type) {
+	case map[string]interface{}:
+		pruned := make(map[string]interface{}, len(sel))
+		for name, child := range sel {
+			if value, ok := v[name]; ok {
+				pruned[name] = child.prune(value)
+			}
+		}
+		return pruned
+	case []interface{}:
+		for i := range v {
+			v[i] = sel.prune(v[i])
+		}
+	}
+	return v
+}
+
+// decodeResponse decodes the body of res into target. The ClearBlade webhook
+// does not support partial responses, so when fields is set the response is
+// reduced to the selected fields here before it is decoded. The next page
+// token of list responses is always kept, since paging depends on it.
+func decodeResponse(target interface{}, res *http.Response, fields string) error {
+	if fields == "" || res.StatusCode == http.StatusNoContent {
+		return gensupport.DecodeResponse(target, res)
+	}
+	selection, err := parseFields(fields)
+	if err != nil {
+		return err
+	}
+	var raw interface{}
+	if err := json.NewDecoder(res.Body).Decode(&raw); err != nil {
+		return err
+	}
+	pruned := selection.prune(raw)
+	if m, ok := raw.(map[string]interface{}); ok {
+		if token, ok := m["nextPageToken"]; ok {
+			pruned.(map[string]interface{})["nextPageToken"] = token
+		}
+	}
+	b, err := json.Marshal(pruned)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, target)
+}
@@ -0,0 +1,83 @@
+package iot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFieldSelectionPrune(t *testing.T) {
+	device := `{"id":"d","numId":"1","blocked":true,"config":{"version":"2","binaryData":"eA=="},"metadata":{"a":"b"}}`
+	tests := []struct {
+		fields string
+		want   string
+	}{
+		{"id", `{"id":"d"}`},
+		{"id,config.version", `{"id":"d","config":{"version":"2"}}`},
+		{"config/version,config", `{"config":{"version":"2","binaryData":"eA=="}}`},
+		{"config(binary_data),num_id", `{"numId":"1","config":{"binaryData":"eA=="}}`},
+		{"missing", `{}`},
+	}
+	for _, test := range tests {
+		selection, err := parseFields(test.fields)
+		if err != nil {
+			t.Errorf("Failed to parse fields %q: %s", test.fields, err.Error())
+			continue
+		}
+		var v, want interface{}
+		_ = json.Unmarshal([]byte(device), &v)
+		_ = json.Unmarshal([]byte(test.want), &want)
+		if got := selection.prune(v); !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected fields %q to select %s but got: %v", test.fields, test.want, got)
+		}
+	}
+
+	for _, fields := range []string{"", "id,", "config(version", "id)", "(id)"} {
+		if _, err := parseFields(fields); err == nil {
+			t.Errorf("Expected fields %q to be invalid", fields)
+		}
+	}
+}
+
+func TestFieldsPrunesListResponses(t *testing.T) {
+	t.Setenv("CLEARBLADE_CONFIGURATION", "./test_credentials.json")
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/getRegistryCredentials") {
+			fmt.Fprintf(w, `{"systemKey":"registrySystemKey","serviceAccountToken":"registryToken","url":%q}`, server.URL)
+			return
+		}
+		_, _ = w.Write([]byte(`{"devices":[{"id":"a","numId":"1","config":{"version":"3","binaryData":"eA=="},"metadata":{"site":"x"}}],"nextPageToken":"next"}`))
+	}))
+	defer server.Close()
+
+	service, err := NewService(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to initialize service: %s", err.Error())
+	}
+	service.ServiceAccountCredentials.Url = server.URL
+
+	resp, err := service.Projects.Locations.Registries.Devices.List("projects/project/locations/us-central1/registries/registry").
+		Fields("devices(id,config.version)").Do()
+	if err != nil {
+		t.Fatalf("Failed to list devices: %s", err.Error())
+	}
+	if len(resp.Devices) != 1 {
+		t.Fatalf("Expected 1 device but got: %d", len(resp.Devices))
+	}
+	d := resp.Devices[0]
+	if d.Id != "a" || d.Config == nil || d.Config.Version != 3 || d.Config.BinaryData != "" || d.NumId != 0 || d.Metadata != nil {
+		t.Errorf("Expected only the device ID and config version but got: %+v %+v", d, d.Config)
+	}
+	if resp.NextPageToken != "next" {
+		t.Errorf("Expected the next page token to be kept but got: %q", resp.NextPageToken)
+	}
+	if resp.HTTPStatusCode != http.StatusOK {
+		t.Errorf("Expected the server response to be kept but got: %d", resp.HTTPStatusCode)
+	}
+}
@@ -1,11 +1,12 @@
 module github.com/clearblade/go-iot
 
-go 1.20
+go 1.23
 
 require (
 	github.com/google/uuid v1.3.0
 	github.com/googleapis/gax-go/v2 v2.7.0
 	google.golang.org/api v0.107.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
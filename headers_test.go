@@ -0,0 +1,96 @@
+package iot_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	iot "github.com/clearblade/go-iot"
+)
+
+func TestHeadersAreSentByEveryCall(t *testing.T) {
+	var server *httptest.Server
+	var requests []http.Header
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/getRegistryCredentials") {
+			fmt.Fprintf(w, `{"systemKey":"registrySystemKey","serviceAccountToken":"registryToken","url":%q}`, server.URL)
+			return
+		}
+		requests = append(requests, r.Header.Clone())
+		_, _ = w.Write([]byte(`{"nextPageToken":""}`))
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	service, err := iot.NewService(ctx,
+		iot.WithServiceAccountCredentials(fmt.Sprintf(`{"systemKey":"fakeSystemKey","token":"fakeToken","url":%q,"project":"testProject"}`, server.URL)),
+		iot.WithDefaultHeaders(http.Header{"X-Tenant": {"default"}, "X-Default": {"yes"}}),
+		iot.WithRequestMutator(func(req *http.Request) error {
+			req.Header.Set("X-Mutated", req.Method)
+			return nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("Failed to initialize service: %s", err.Error())
+	}
+
+	parent := "projects/testProject/locations/us-central1"
+	registry := parent + "/registries/registry"
+	device := registry + "/devices/device"
+	registries := service.Projects.Locations.Registries
+	devices := registries.Devices
+	check := func(name string, header http.Header, do func() error) {
+		t.Helper()
+		requests = nil
+		header.Set("X-Trace-Id", name)
+		header.Set("X-Tenant", "call")
+		if err := do(); err != nil {
+			t.Errorf("Failed to call %s: %s", name, err.Error())
+			return
+		}
+		if len(requests) != 1 {
+			t.Errorf("Expected %s to send 1 request but got: %d", name, len(requests))
+			return
+		}
+		h := requests[0]
+		if h.Get("X-Trace-Id") != name || h.Get("X-Tenant") != "call" || h.Get("X-Default") != "yes" || h.Get("X-Mutated") == "" {
+			t.Errorf("Expected %s to send call, default and mutated headers but got: %v", name, h)
+		}
+	}
+
+	c1 := registries.BindDeviceToGateway(registry, &iot.BindDeviceToGatewayRequest{})
+	check("registries.bindDeviceToGateway", c1.Header(), func() error { _, err := c1.Do(); return err })
+	c2 := registries.Create(parent, &iot.DeviceRegistry{Id: "registry"})
+	check("registries.create", c2.Header(), func() error { _, err := c2.Do(); return err })
+	c3 := registries.Delete(registry)
+	check("registries.delete", c3.Header(), func() error { _, err := c3.Do(); return err })
+	c4 := registries.Get(registry)
+	check("registries.get", c4.Header(), func() error { _, err := c4.Do(); return err })
+	c5 := registries.List(parent)
+	check("registries.list", c5.Header(), func() error { _, err := c5.Do(); return err })
+	c6 := registries.Patch(registry, &iot.DeviceRegistry{})
+	check("registries.patch", c6.Header(), func() error { _, err := c6.Do(); return err })
+	c7 := registries.UnbindDeviceFromGateway(registry, &iot.UnbindDeviceFromGatewayRequest{})
+	check("registries.unbindDeviceFromGateway", c7.Header(), func() error { _, err := c7.Do(); return err })
+	c8 := devices.Create(registry, &iot.Device{Id: "device"})
+	check("devices.create", c8.Header(), func() error { _, err := c8.Do(); return err })
+	c9 := devices.Delete(device)
+	check("devices.delete", c9.Header(), func() error { _, err := c9.Do(); return err })
+	c10 := devices.Get(device)
+	check("devices.get", c10.Header(), func() error { _, err := c10.Do(); return err })
+	c11 := devices.List(registry)
+	check("devices.list", c11.Header(), func() error { _, err := c11.Do(); return err })
+	c12 := devices.ModifyCloudToDeviceConfig(device, &iot.ModifyCloudToDeviceConfigRequest{})
+	check("devices.modifyCloudToDeviceConfig", c12.Header(), func() error { _, err := c12.Do(); return err })
+	c13 := devices.Patch(device, &iot.Device{})
+	check("devices.patch", c13.Header(), func() error { _, err := c13.Do(); return err })
+	c14 := devices.SendCommandToDevice(device, &iot.SendCommandToDeviceRequest{})
+	check("devices.sendCommandToDevice", c14.Header(), func() error { _, err := c14.Do(); return err })
+	c15 := devices.ConfigVersions.List(device)
+	check("devices.configVersions.list", c15.Header(), func() error { _, err := c15.Do(); return err })
+	c16 := devices.States.List(device)
+	check("devices.states.list", c16.Header(), func() error { _, err := c16.Do(); return err })
+}
@@ -0,0 +1,367 @@
+// Copyright 2023 ClearBlade Inc.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package mqtt encodes and decodes the MQTT 3.1.1 control packets used by the
+// device client and its test broker. It covers QoS 0 and 1 only, which is all
+// ClearBlade IoT Core supports.
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Control packet types.
+const (
+	typeConnect    = 1
+	typeConnack    = 2
+	typePublish    = 3
+	typePuback     = 4
+	typeSubscribe  = 8
+	typeSuback     = 9
+	typePingreq    = 12
+	typePingresp   = 13
+	typeDisconnect = 14
+)
+
+// maxRemainingLength is the largest remaining length MQTT can encode.
+const maxRemainingLength = 268435455
+
+// Connack return codes.
+const (
+	Accepted byte = iota
+	RefusedProtocolVersion
+	RefusedIdentifierRejected
+	RefusedServerUnavailable
+	RefusedBadUsernamePassword
+	RefusedNotAuthorized
+)
+
+// SubackFailure is the Suback return code of a rejected subscription.
+const SubackFailure byte = 0x80
+
+// Packet is an MQTT control packet.
+type Packet interface {
+	encode() (header byte, body []byte)
+}
+
+// Connect is a CONNECT packet.
+type Connect struct {
+	ClientID     string
+	Username     string
+	Password     string
+	KeepAlive    uint16 // in seconds
+	CleanSession bool
+}
+
+// Connack is a CONNACK packet.
+type Connack struct {
+	SessionPresent bool
+	ReturnCode     byte
+}
+
+// Publish is a PUBLISH packet.
+type Publish struct {
+	Topic    string
+	QoS      byte
+	Retain   bool
+	Dup      bool
+	PacketID uint16 // only for QoS 1
+	Payload  []byte
+}
+
+// Puback is a PUBACK packet.
+type Puback struct {
+	PacketID uint16
+}
+
+// Subscription is a topic filter and the maximum QoS requested for it.
+type Subscription struct {
+	Filter string
+	QoS    byte
+}
+
+// Subscribe is a SUBSCRIBE packet.
+type Subscribe struct {
+	PacketID      uint16
+	Subscriptions []Subscription
+}
+
+// Suback is a SUBACK packet. ReturnCodes holds the granted QoS of each
+// subscription, or SubackFailure.
+type Suback struct {
+	PacketID    uint16
+	ReturnCodes []byte
+}
+
+// Pingreq is a PINGREQ packet.
+type Pingreq struct{}
+
+// Pingresp is a PINGRESP packet.
+type Pingresp struct{}
+
+// Disconnect is a DISCONNECT packet.
+type Disconnect struct{}
+
+func (p *Connect) encode() (byte, []byte) {
+	var b []byte
+	b = appendString(b, "MQTT")
+	b = append(b, 4) // protocol level 3.1.1
+	var flags byte
+	if p.Username != "" {
+		flags |= 0x80
+	}
+	if p.Password != "" {
+		flags |= 0x40
+	}
+	if p.CleanSession {
+		flags |= 0x02
+	}
+	b = append(b, flags)
+	b = binary.BigEndian.AppendUint16(b, p.KeepAlive)
+	b = appendString(b, p.ClientID)
+	if p.Username != "" {
+		b = appendString(b, p.Username)
+	}
+	if p.Password != "" {
+		b = appendString(b, p.Password)
+	}
+	return typeConnect << 4, b
+}
+
+func (p *Connack) encode() (byte, []byte) {
+	var flags byte
+	if p.SessionPresent {
+		flags = 1
+	}
+	return typeConnack << 4, []byte{flags, p.ReturnCode}
+}
+
+func (p *Publish) encode() (byte, []byte) {
+	header := byte(typePublish<<4) | p.QoS<<1
+	if p.Dup {
+		header |= 0x08
+	}
+	if p.Retain {
+		header |= 0x01
+	}
+	b := appendString(nil, p.Topic)
+	if p.QoS > 0 {
+		b = binary.BigEndian.AppendUint16(b, p.PacketID)
+	}
+	return header, append(b, p.Payload...)
+}
+
+func (p *Puback) encode() (byte, []byte) {
+	return typePuback << 4, binary.BigEndian.AppendUint16(nil, p.PacketID)
+}
+
+func (p *Subscribe) encode() (byte, []byte) {
+	b := binary.BigEndian.AppendUint16(nil, p.PacketID)
+	for _, s := range p.Subscriptions {
+		b = appendString(b, s.Filter)
+		b = append(b, s.QoS)
+	}
+	return typeSubscribe<<4 | 0x02, b
+}
+
+func (p *Suback) encode() (byte, []byte) {
+	b := binary.BigEndian.AppendUint16(nil, p.PacketID)
+	return typeSuback << 4, append(b, p.ReturnCodes...)
+}
+
+func (p *Pingreq) encode() (byte, []byte)    { return typePingreq << 4, nil }
+func (p *Pingresp) encode() (byte, []byte)   { return typePingresp << 4, nil }
+func (p *Disconnect) encode() (byte, []byte) { return typeDisconnect << 4, nil }
+
+// Write writes p to w as a single write.
+func Write(w io.Writer, p Packet) error {
+	header, body := p.encode()
+	if len(body) > maxRemainingLength {
+		return fmt.Errorf("mqtt: packet of %d bytes is too large", len(body))
+	}
+	b := make([]byte, 0, 5+len(body))
+	b = append(b, header)
+	for n := len(body); ; {
+		digit := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			digit |= 0x80
+		}
+		b = append(b, digit)
+		if n == 0 {
+			break
+		}
+	}
+	_, err := w.Write(append(b, body...))
+	return err
+}
+
+// Read reads the next packet from r.
+func Read(r *bufio.Reader) (Packet, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	length, multiplier := 0, 1
+	for i := 0; ; i++ {
+		if i == 4 {
+			return nil, errors.New("mqtt: malformed remaining length")
+		}
+		digit, err := r.ReadByte()
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		length += int(digit&0x7f) * multiplier
+		multiplier *= 128
+		if digit&0x80 == 0 {
+			break
+		}
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	d := &decoder{b: body}
+	var p Packet
+	switch header >> 4 {
+	case typeConnect:
+		p = d.connect()
+	case typeConnack:
+		p = &Connack{SessionPresent: d.byte()&1 == 1, ReturnCode: d.byte()}
+	case typePublish:
+		pub := &Publish{QoS: header >> 1 & 0x03, Retain: header&0x01 == 1, Dup: header&0x08 != 0}
+		pub.Topic = d.string()
+		if pub.QoS > 0 {
+			pub.PacketID = d.uint16()
+		}
+		pub.Payload = d.rest()
+		p = pub
+	case typePuback:
+		p = &Puback{PacketID: d.uint16()}
+	case typeSubscribe:
+		sub := &Subscribe{PacketID: d.uint16()}
+		for d.err == nil && len(d.b) > 0 {
+			sub.Subscriptions = append(sub.Subscriptions, Subscription{Filter: d.string(), QoS: d.byte()})
+		}
+		p = sub
+	case typeSuback:
+		p = &Suback{PacketID: d.uint16(), ReturnCodes: d.rest()}
+	case typePingreq:
+		p = &Pingreq{}
+	case typePingresp:
+		p = &Pingresp{}
+	case typeDisconnect:
+		p = &Disconnect{}
+	default:
+		return nil, fmt.Errorf("mqtt: unsupported packet type %d", header>>4)
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	return p, nil
+}
+
+// MatchTopic reports whether topic matches filter, which may contain the
+// "+" and "#" wildcards.
+func MatchTopic(filter, topic string) bool {
+	f, t := strings.Split(filter, "/"), strings.Split(topic, "/")
+	for i, level := range f {
+		if level == "#" {
+			return true
+		}
+		if i >= len(t) || (level != "+" && level != t[i]) {
+			return false
+		}
+	}
+	return len(f) == len(t)
+}
+
+func appendString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// decoder reads the fields of a packet body. After the first error all reads
+// return zero values, and err holds the error.
+type decoder struct {
+	b   []byte
+	err error
+}
+
+func (d *decoder) fail() {
+	if d.err == nil {
+		d.err = errors.New("mqtt: malformed packet")
+	}
+	d.b = nil
+}
+
+func (d *decoder) byte() byte {
+	if len(d.b) < 1 {
+		d.fail()
+		return 0
+	}
+	v := d.b[0]
+	d.b = d.b[1:]
+	return v
+}
+
+func (d *decoder) uint16() uint16 {
+	if len(d.b) < 2 {
+		d.fail()
+		return 0
+	}
+	v := binary.BigEndian.Uint16(d.b)
+	d.b = d.b[2:]
+	return v
+}
+
+func (d *decoder) string() string {
+	n := int(d.uint16())
+	if len(d.b) < n {
+		d.fail()
+		return ""
+	}
+	v := string(d.b[:n])
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *decoder) rest() []byte {
+	v := d.b
+	d.b = nil
+	return v
+}
+
+func (d *decoder) connect() *Connect {
+	if d.string() != "MQTT" || d.byte() != 4 {
+		d.fail()
+		return nil
+	}
+	flags := d.byte()
+	p := &Connect{CleanSession: flags&0x02 != 0, KeepAlive: d.uint16(), ClientID: d.string()}
+	if flags&0x04 != 0 {
+		// Will topic and message, which are not used.
+		d.string()
+		d.string()
+	}
+	if flags&0x80 != 0 {
+		p.Username = d.string()
+	}
+	if flags&0x40 != 0 {
+		p.Password = d.string()
+	}
+	return p
+}
@@ -7,41 +7,36 @@
 
 // Code generated file. DO NOT EDIT.
 
-// Package cloudiot provides access to the Cloud IoT API.
-//
-// For product documentation, see: https://cloud.google.com/iot
+// Package iot provides access to the ClearBlade IoT Core API, which is
+// compatible with the Google Cloud IoT API.
 //
 // # Creating a client
 //
 // Usage example:
 //
-//	import "google.golang.org/api/cloudiot/v1"
+//	import "github.com/clearblade/go-iot"
 //	...
 //	ctx := context.Background()
-//	cloudiotService, err := cloudiot.NewService(ctx)
-//
-// In this example, Google Application Default Credentials are used for authentication.
+//	service, err := iot.NewService(ctx)
 //
-// For information on how to create and obtain Application Default Credentials, see https://developers.google.com/identity/protocols/application-default-credentials.
+// In this example, the service account credentials are read from the file
+// named by the CLEARBLADE_CONFIGURATION environment variable.
 //
 // # Other authentication options
 //
-// By default, all available scopes (see "Constants") are used to authenticate. To restrict scopes, use option.WithScopes:
+// To pass the credentials directly, use WithServiceAccountCredentials:
 //
-//	cloudiotService, err := cloudiot.NewService(ctx, option.WithScopes(cloudiot.CloudiotScope))
+//	service, err := iot.NewService(ctx, iot.WithServiceAccountCredentials(credentials))
 //
-// To use an API key for authentication (note: some APIs do not support API keys), use option.WithAPIKey:
+// To pick up rotated credentials without restarting, use WithCredentialsProvider:
 //
-//	cloudiotService, err := cloudiot.NewService(ctx, option.WithAPIKey("AIza..."))
+//	service, err := iot.NewService(ctx, iot.WithCredentialsProvider(iot.NewEnvCredentialsProvider()))
 //
-// To use an OAuth token (e.g., a user token obtained via a three-legged OAuth flow), use option.WithTokenSource:
+// # Migrating from Google Cloud IoT Core
 //
-//	config := &oauth2.Config{...}
-//	// ...
-//	token, err := config.Exchange(ctx, ...)
-//	cloudiotService, err := cloudiot.NewService(ctx, option.WithTokenSource(config.TokenSource(ctx, token)))
-//
-// See https://godoc.org/google.golang.org/api/option/ for details on options.
+// The types and calls of this package follow google.golang.org/api/cloudiot/v1,
+// so existing code needs few changes. Registries and devices exported from
+// Google Cloud IoT Core can be imported with the migrate package.
 package iot
 
 import (
@@ -51,13 +46,18 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"iter"
 	"net/http"
+	"net/url"
 	"os"
+	"path"
 	"strconv"
 	"sync"
+	"time"
 
 	"github.com/clearblade/go-iot/cblib/gensupport"
 	"github.com/clearblade/go-iot/cblib/path_template"
+	"github.com/googleapis/gax-go/v2"
 	"google.golang.org/api/googleapi"
 )
 
@@ -72,50 +72,96 @@ type RegistryUserCredentials struct {
 	SystemKey string `json:"systemKey"`
 	Token     string `json:"serviceAccountToken"`
 	Url       string `json:"url"`
+
+	// expiresAt is when a cached copy must be fetched again. The zero value
+	// never expires.
+	expiresAt time.Time
+}
+
+// expired reports whether cached credentials must be fetched again.
+func (c *RegistryUserCredentials) expired() bool {
+	return !c.expiresAt.IsZero() && time.Now().After(c.expiresAt)
 }
 
 func loadServiceAccountCredentials() (*ServiceAccountCredentials, error) {
 	configFilePath := os.Getenv("CLEARBLADE_CONFIGURATION")
-	var err error
-	configFile, err := os.Open(configFilePath)
-	if err != nil {
+	if _, err := os.Stat(configFilePath); err != nil {
 		return nil, errors.New("Must supply service account credentials via constructor or CLEARBLADE_CONFIGURATION environment variable")
 	}
-	defer configFile.Close()
-
-	byteValue, _ := io.ReadAll(configFile)
-
-	var credentials ServiceAccountCredentials
-	err = json.Unmarshal(byteValue, &credentials)
-
-	if err != nil {
-		return nil, errors.New("File loaded from " + configFilePath + " is invalid. Please make sure it is a json file with the properties systemKey, token, url, and project")
-	}
+	return readServiceAccountCredentials(configFilePath)
+}
 
-	return &credentials, nil
+// registryCredentialsCall is an in-flight getRegistryCredentials request.
+// Callers asking for the same registry while it runs wait for its result
+// instead of issuing their own request.
+type registryCredentialsCall struct {
+	wg          sync.WaitGroup
+	credentials *RegistryUserCredentials
+	err         error
 }
 
+// GetRegistryCredentials returns the credentials used for requests to a
+// registry, fetching them on first use. It is safe for concurrent use;
+// concurrent callers for the same region and registry share a single fetch.
 func GetRegistryCredentials(registry string, region string, s *Service) (*RegistryUserCredentials, error) {
 	cacheKey := fmt.Sprintf("%s-%s", region, registry)
-	if s.RegistryUserCache[cacheKey] != nil {
-		s.RegistryUserCacheLock.RLock()
-		defer s.RegistryUserCacheLock.RUnlock()
-		return s.RegistryUserCache[cacheKey], nil
+
+	s.RegistryUserCacheLock.RLock()
+	credentials := s.RegistryUserCache[cacheKey]
+	s.RegistryUserCacheLock.RUnlock()
+	if credentials != nil && !credentials.expired() {
+		return credentials, nil
 	}
 
 	s.RegistryUserCacheLock.Lock()
-	defer s.RegistryUserCacheLock.Unlock()
+	if credentials := s.RegistryUserCache[cacheKey]; credentials != nil && !credentials.expired() {
+		s.RegistryUserCacheLock.Unlock()
+		return credentials, nil
+	}
+	if call, ok := s.registryCredentialsCalls[cacheKey]; ok {
+		s.RegistryUserCacheLock.Unlock()
+		call.wg.Wait()
+		return call.credentials, call.err
+	}
+	if s.registryCredentialsCalls == nil {
+		s.registryCredentialsCalls = make(map[string]*registryCredentialsCall)
+	}
+	call := &registryCredentialsCall{}
+	call.wg.Add(1)
+	s.registryCredentialsCalls[cacheKey] = call
+	s.RegistryUserCacheLock.Unlock()
+
+	call.credentials, call.err = fetchRegistryCredentials(registry, region, s)
+
+	s.RegistryUserCacheLock.Lock()
+	if call.err == nil {
+		if s.RegistryUserCache == nil {
+			s.RegistryUserCache = make(map[string]*RegistryUserCredentials)
+		}
+		s.RegistryUserCache[cacheKey] = call.credentials
+	}
+	delete(s.registryCredentialsCalls, cacheKey)
+	s.RegistryUserCacheLock.Unlock()
+	call.wg.Done()
 
+	return call.credentials, call.err
+}
+
+func fetchRegistryCredentials(registry string, region string, s *Service) (*RegistryUserCredentials, error) {
+	serviceAccount, err := s.serviceAccountCredentials(context.Background())
+	if err != nil {
+		return nil, err
+	}
 	requestBody, _ := json.Marshal(map[string]string{
-		"region": region, "registry": registry, "project": s.ServiceAccountCredentials.Project,
+		"region": region, "registry": registry, "project": serviceAccount.Project,
 	})
-	url := fmt.Sprintf("%s/api/v/1/code/%s/getRegistryCredentials", s.ServiceAccountCredentials.Url, s.ServiceAccountCredentials.SystemKey)
+	url := fmt.Sprintf("%s/api/v/1/code/%s/getRegistryCredentials", serviceAccount.Url, serviceAccount.SystemKey)
 	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(requestBody))
 	if err != nil {
 		return nil, err
 	}
 	req.Close = true
-	req.Header.Add("ClearBlade-UserToken", s.ServiceAccountCredentials.Token)
+	req.Header.Add("ClearBlade-UserToken", serviceAccount.Token)
 	resp, err := s.client.Do(req)
 	if err != nil {
 		return nil, err
@@ -126,15 +172,31 @@ func GetRegistryCredentials(registry string, region string, s *Service) (*Regist
 		return nil, err
 	}
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("GetRegistryCredentials HTTP Error %d: %s", resp.StatusCode, string(body))
+		herr := &googleapi.Error{Code: resp.StatusCode, Body: string(body), Header: resp.Header}
+		_ = json.Unmarshal(body, &struct {
+			Error *googleapi.Error `json:"error"`
+		}{herr})
+		name := fmt.Sprintf("projects/%s/locations/%s/registries/%s", serviceAccount.Project, region, registry)
+		return nil, newError(herr, name)
 	}
 	var credentials RegistryUserCredentials
 	_ = json.Unmarshal(body, &credentials)
-	s.RegistryUserCache[cacheKey] = &credentials
+	if s.registryCredentialsTTL > 0 {
+		credentials.expiresAt = time.Now().Add(s.registryCredentialsTTL)
+	}
 
 	return &credentials, nil
 }
 
+// InvalidateRegistryCredentials removes the cached credentials of a registry,
+// so that the next request to it fetches them again. Use it after rotating a
+// registry's service account token or recreating the registry.
+func (s *Service) InvalidateRegistryCredentials(region string, registry string) {
+	s.RegistryUserCacheLock.Lock()
+	defer s.RegistryUserCacheLock.Unlock()
+	delete(s.RegistryUserCache, fmt.Sprintf("%s-%s", region, registry))
+}
+
 // ServiceOption is a configuration option for a Service.
 type ServiceOption func(*Service) error
 
@@ -173,6 +235,152 @@ func WithHTTPClient(client *http.Client) ServiceOption {
 	}
 }
 
+// WithDefaultHeaders sets headers sent with every request made by the
+// service, such as tracing IDs or tenant tags. Headers set on a call with
+// Header take precedence over them.
+func WithDefaultHeaders(header http.Header) ServiceOption {
+	return func(s *Service) error {
+		s.defaultHeaders = header.Clone()
+		return nil
+	}
+}
+
+// RequestMutator is called with every request just before it is sent. It can
+// modify the request, for example to sign it, or return an error to abort the
+// call. A request re-sent with refreshed registry credentials is passed to the
+// mutators again, so they should set headers rather than add to them.
+type RequestMutator func(req *http.Request) error
+
+// WithRequestMutator adds a RequestMutator to the service. Mutators run in the
+// order they were added.
+func WithRequestMutator(mutator RequestMutator) ServiceOption {
+	return func(s *Service) error {
+		if mutator == nil {
+			return fmt.Errorf("request mutator cannot be nil")
+		}
+		s.requestMutators = append(s.requestMutators, mutator)
+		return nil
+	}
+}
+
+// requestHeaders returns the headers of a new request: the service defaults
+// overridden by the headers set on the call.
+func (s *Service) requestHeaders(callHeader http.Header) http.Header {
+	reqHeaders := s.defaultHeaders.Clone()
+	if reqHeaders == nil {
+		reqHeaders = make(http.Header)
+	}
+	for k, v := range callHeader {
+		reqHeaders[k] = v
+	}
+	return reqHeaders
+}
+
+// RetryConfig configures the backoff timing, the retryable errors and the
+// maximum number of attempts for automatic retries.
+type RetryConfig = gensupport.RetryConfig
+
+// defaultRetryConfig is used by idempotent calls when the Service was not
+// given a retry configuration.
+var defaultRetryConfig = &RetryConfig{
+	Backoff: &gax.Backoff{
+		Initial:    100 * time.Millisecond,
+		Max:        5 * time.Second,
+		Multiplier: 2,
+	},
+	MaxAttempts: 5,
+}
+
+// WithRetryConfig sets the retry configuration used by idempotent calls (Get,
+// List, Delete, ConfigVersions.List and States.List). Non-idempotent calls are
+// only retried when a configuration is set on the call itself with Retry.
+// Passing nil disables automatic retries.
+func WithRetryConfig(retry *RetryConfig) ServiceOption {
+	return func(s *Service) error {
+		s.retry = retry
+		return nil
+	}
+}
+
+// sendRequest sends req with the retry configuration that applies to the
+// call. The call's own configuration takes precedence; idempotent calls fall
+// back to the Service configuration.
+func (s *Service) sendRequest(ctx context.Context, req *http.Request, retry *RetryConfig, idempotent bool) (*http.Response, error) {
+	if retry == nil && idempotent {
+		retry = s.retry
+	}
+	for _, mutate := range s.requestMutators {
+		if err := mutate(req); err != nil {
+			return nil, err
+		}
+	}
+	if retry == nil {
+		return gensupport.SendRequest(ctx, s.client, req)
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return gensupport.SendRequestWithRetry(ctx, s.client, req, retry)
+}
+
+// defaultRegistryCredentialsTTL is how long registry credentials are cached
+// when the Service was not given a TTL.
+const defaultRegistryCredentialsTTL = time.Hour
+
+// WithRegistryCredentialsTTL sets how long registry credentials are cached
+// before they are fetched again. A TTL of zero or less caches them until they
+// are rejected or invalidated with InvalidateRegistryCredentials.
+func WithRegistryCredentialsTTL(ttl time.Duration) ServiceOption {
+	return func(s *Service) error {
+		s.registryCredentialsTTL = ttl
+		return nil
+	}
+}
+
+// sendRegistryRequest sends a request authenticated with the credentials of
+// a registry. If the webhook rejects them with 401 or 403, they are evicted
+// from the cache and the request is sent once more with fresh credentials.
+func (s *Service) sendRegistryRequest(ctx context.Context, req *http.Request, retry *RetryConfig, idempotent bool, region string, registry string) (*http.Response, error) {
+	res, err := s.sendRequest(ctx, req, retry, idempotent)
+	if err != nil || (res.StatusCode != http.StatusUnauthorized && res.StatusCode != http.StatusForbidden) {
+		return res, err
+	}
+	s.InvalidateRegistryCredentials(region, registry)
+	credentials, err := GetRegistryCredentials(registry, region, s)
+	if err != nil {
+		res.Body.Close()
+		return nil, err
+	}
+	retryReq, err := withRegistryCredentials(req, credentials)
+	if err != nil {
+		res.Body.Close()
+		return nil, err
+	}
+	res.Body.Close()
+	return s.sendRequest(ctx, retryReq, retry, idempotent)
+}
+
+// withRegistryCredentials returns a copy of a webhook request addressed and
+// authenticated with the given registry credentials.
+func withRegistryCredentials(req *http.Request, credentials *RegistryUserCredentials) (*http.Request, error) {
+	endpoint := path.Base(req.URL.Path)
+	u, err := url.Parse(fmt.Sprintf("%s/api/v/4/webhook/execute/%s/%s", credentials.Url, credentials.SystemKey, endpoint))
+	if err != nil {
+		return nil, err
+	}
+	u.RawQuery = req.URL.RawQuery
+	r := req.Clone(req.Context())
+	r.URL = u
+	r.Host = u.Host
+	r.Header.Set("ClearBlade-UserToken", credentials.Token)
+	if req.GetBody != nil {
+		if r.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
 // NewService creates a new Service.
 func NewService(ctx context.Context, opts ...ServiceOption) (*Service, error) {
 	s, err := New()
@@ -181,11 +389,11 @@ func NewService(ctx context.Context, opts ...ServiceOption) (*Service, error) {
 	}
 
 	s.client = http.DefaultClient
+	s.retry = defaultRetryConfig
+	s.registryCredentialsTTL = defaultRegistryCredentialsTTL
 	s.RegistryUserCacheLock = sync.RWMutex{}
 	s.RegistryUserCache = make(map[string]*RegistryUserCredentials)
-	devicePathTemplate, _ := path_template.NewPathTemplate("projects/{project}/locations/{location}/registries/{registry}/devices/{device}")
-	locationPathTemplate, _ := path_template.NewPathTemplate("projects/{project}/locations/{location}")
-	registryPathTemplate, _ := path_template.NewPathTemplate("projects/{project}/locations/{location}/registries/{registry}")
+	s.registryCredentialsCalls = make(map[string]*registryCredentialsCall)
 	s.TemplatePaths.DevicePathTemplate = devicePathTemplate
 	s.TemplatePaths.LocationPathTemplate = locationPathTemplate
 	s.TemplatePaths.RegistryPathTemplate = registryPathTemplate
@@ -198,7 +406,7 @@ func NewService(ctx context.Context, opts ...ServiceOption) (*Service, error) {
 	}
 
 	// Load credentials from file if option not provided.
-	if s.ServiceAccountCredentials == nil {
+	if s.ServiceAccountCredentials == nil && s.credentialsProvider == nil {
 		if err := WithFileCredentials()(s); err != nil {
 			return nil, err
 		}
@@ -219,8 +427,14 @@ func New() (*Service, error) {
 
 type Service struct {
 	client                    *http.Client
+	retry                     *RetryConfig
 	RegistryUserCacheLock     sync.RWMutex
 	RegistryUserCache         map[string]*RegistryUserCredentials
+	registryCredentialsCalls  map[string]*registryCredentialsCall
+	registryCredentialsTTL    time.Duration
+	credentialsProvider       CredentialsProvider
+	defaultHeaders            http.Header
+	requestMutators           []RequestMutator
 	ServiceAccountCredentials *ServiceAccountCredentials
 	TemplatePaths             struct {
 		DevicePathTemplate   *path_template.PathTemplate
@@ -1876,6 +2090,7 @@ type ProjectsLocationsRegistriesBindDeviceToGatewayCall struct {
 	urlParams_                 gensupport.URLParams
 	ctx_                       context.Context
 	header_                    http.Header
+	retry                      *RetryConfig
 }
 
 // BindDeviceToGateway: Associates the device with the gateway.
@@ -1895,6 +2110,7 @@ func (r *ProjectsLocationsRegistriesService) BindDeviceToGateway(parent string,
 // https://developers.google.com/gdata/docs/2.0/basics#PartialResponse
 // for more information.
 func (c *ProjectsLocationsRegistriesBindDeviceToGatewayCall) Fields(s ...googleapi.Field) *ProjectsLocationsRegistriesBindDeviceToGatewayCall {
+	c.urlParams_.Set("fields", googleapi.CombineFields(s))
 	return c
 }
 
@@ -1915,23 +2131,27 @@ func (c *ProjectsLocationsRegistriesBindDeviceToGatewayCall) Header() http.Heade
 	return c.header_
 }
 
+// Retry enables automatic retries for this call. The call is not
+// idempotent, so it is never retried unless a configuration is set here.
+func (c *ProjectsLocationsRegistriesBindDeviceToGatewayCall) Retry(retry *RetryConfig) *ProjectsLocationsRegistriesBindDeviceToGatewayCall {
+	c.retry = retry
+	return c
+}
+
 func (c *ProjectsLocationsRegistriesBindDeviceToGatewayCall) doRequest(alt string) (*http.Response, error) {
-	reqHeaders := make(http.Header)
-	for k, v := range c.header_ {
-		reqHeaders[k] = v
-	}
+	reqHeaders := c.s.requestHeaders(c.header_)
 	var body io.Reader = nil
 	body, err := googleapi.WithoutDataWrapper.JSONReader(c.binddevicetogatewayrequest)
 	if err != nil {
 		return nil, err
 	}
 	reqHeaders.Set("Content-Type", "application/json")
-	matches, err := c.s.TemplatePaths.RegistryPathTemplate.Match(c.parent)
+	registryName, err := ParseRegistryName(c.parent)
 	if err != nil {
 		return nil, err
 	}
-	registry := matches["registry"]
-	location := matches["location"]
+	registry := registryName.Registry
+	location := registryName.Location
 	credentials, err := GetRegistryCredentials(registry, location, c.s)
 	if err != nil {
 		return nil, err
@@ -1949,7 +2169,7 @@ func (c *ProjectsLocationsRegistriesBindDeviceToGatewayCall) doRequest(alt strin
 	googleapi.Expand(req.URL, map[string]string{
 		"parent": c.parent,
 	})
-	return gensupport.SendRequest(c.ctx_, c.s.client, req)
+	return c.s.sendRegistryRequest(c.ctx_, req, c.retry, false, location, registry)
 }
 
 // Do executes the "cloudiot.projects.locations.registries.bindDeviceToGateway" call.
@@ -1959,7 +2179,8 @@ func (c *ProjectsLocationsRegistriesBindDeviceToGatewayCall) doRequest(alt strin
 // was returned at all) in error.(*googleapi.Error).Header. Use
 // googleapi.IsNotModified to check whether the returned error was
 // because http.StatusNotModified was returned.
-func (c *ProjectsLocationsRegistriesBindDeviceToGatewayCall) Do() (*BindDeviceToGatewayResponse, error) {
+func (c *ProjectsLocationsRegistriesBindDeviceToGatewayCall) Do(opts ...googleapi.CallOption) (*BindDeviceToGatewayResponse, error) {
+	gensupport.SetOptions(c.urlParams_, opts...)
 	res, err := c.doRequest("json")
 	if res != nil && res.StatusCode == http.StatusNotModified {
 		if res.Body != nil {
@@ -1975,7 +2196,7 @@ func (c *ProjectsLocationsRegistriesBindDeviceToGatewayCall) Do() (*BindDeviceTo
 	}
 	defer res.Body.Close()
 	if err := googleapi.CheckResponse(res); err != nil {
-		return nil, gensupport.WrapError(err)
+		return nil, newError(gensupport.WrapError(err), c.parent)
 	}
 	ret := &BindDeviceToGatewayResponse{
 		ServerResponse: googleapi.ServerResponse{
@@ -1984,7 +2205,7 @@ func (c *ProjectsLocationsRegistriesBindDeviceToGatewayCall) Do() (*BindDeviceTo
 		},
 	}
 	target := &ret
-	if err := gensupport.DecodeResponse(target, res); err != nil {
+	if err := decodeResponse(target, res, c.urlParams_.Get("fields")); err != nil {
 		return nil, err
 	}
 	return ret, nil
@@ -2029,6 +2250,7 @@ type ProjectsLocationsRegistriesCreateCall struct {
 	urlParams_     gensupport.URLParams
 	ctx_           context.Context
 	header_        http.Header
+	retry          *RetryConfig
 }
 
 // Create: Creates a device registry that contains devices.
@@ -2048,6 +2270,7 @@ func (r *ProjectsLocationsRegistriesService) Create(parent string, deviceregistr
 // https://developers.google.com/gdata/docs/2.0/basics#PartialResponse
 // for more information.
 func (c *ProjectsLocationsRegistriesCreateCall) Fields(s ...googleapi.Field) *ProjectsLocationsRegistriesCreateCall {
+	c.urlParams_.Set("fields", googleapi.CombineFields(s))
 	return c
 }
 
@@ -2068,20 +2291,36 @@ func (c *ProjectsLocationsRegistriesCreateCall) Header() http.Header {
 	return c.header_
 }
 
+// Retry enables automatic retries for this call. The call is not
+// idempotent, so it is never retried unless a configuration is set here.
+func (c *ProjectsLocationsRegistriesCreateCall) Retry(retry *RetryConfig) *ProjectsLocationsRegistriesCreateCall {
+	c.retry = retry
+	return c
+}
+
 func (c *ProjectsLocationsRegistriesCreateCall) doRequest(alt string) (*http.Response, error) {
-	reqHeaders := make(http.Header)
-	for k, v := range c.header_ {
-		reqHeaders[k] = v
+	if _, err := ParseLocationName(c.parent); err != nil {
+		return nil, err
 	}
+	if c.deviceregistry != nil {
+		if err := validateResourceID("registry", c.deviceregistry.Id); err != nil {
+			return nil, err
+		}
+	}
+	reqHeaders := c.s.requestHeaders(c.header_)
 	var body io.Reader = nil
 	body, err := googleapi.WithoutDataWrapper.JSONReader(c.deviceregistry)
 	if err != nil {
 		return nil, err
 	}
 	reqHeaders.Set("Content-Type", "application/json")
-	reqHeaders.Set("ClearBlade-UserToken", c.s.ServiceAccountCredentials.Token)
+	serviceAccount, err := c.s.serviceAccountCredentials(c.ctx_)
+	if err != nil {
+		return nil, err
+	}
+	reqHeaders.Set("ClearBlade-UserToken", serviceAccount.Token)
 
-	urls := fmt.Sprintf("%s/api/v/4/webhook/execute/%s/cloudiot", c.s.ServiceAccountCredentials.Url, c.s.ServiceAccountCredentials.SystemKey)
+	urls := fmt.Sprintf("%s/api/v/4/webhook/execute/%s/cloudiot", serviceAccount.Url, serviceAccount.SystemKey)
 	urls += "?" + c.urlParams_.Encode()
 	req, err := http.NewRequest("POST", urls, body)
 	if err != nil {
@@ -2092,7 +2331,7 @@ func (c *ProjectsLocationsRegistriesCreateCall) doRequest(alt string) (*http.Res
 	googleapi.Expand(req.URL, map[string]string{
 		"parent": c.parent,
 	})
-	return gensupport.SendRequest(c.ctx_, c.s.client, req)
+	return c.s.sendRequest(c.ctx_, req, c.retry, false)
 }
 
 // Do executes the "cloudiot.projects.locations.registries.create" call.
@@ -2102,7 +2341,8 @@ func (c *ProjectsLocationsRegistriesCreateCall) doRequest(alt string) (*http.Res
 // at all) in error.(*googleapi.Error).Header. Use
 // googleapi.IsNotModified to check whether the returned error was
 // because http.StatusNotModified was returned.
-func (c *ProjectsLocationsRegistriesCreateCall) Do() (*DeviceRegistry, error) {
+func (c *ProjectsLocationsRegistriesCreateCall) Do(opts ...googleapi.CallOption) (*DeviceRegistry, error) {
+	gensupport.SetOptions(c.urlParams_, opts...)
 	res, err := c.doRequest("json")
 	if res != nil && res.StatusCode == http.StatusNotModified {
 		if res.Body != nil {
@@ -2118,7 +2358,7 @@ func (c *ProjectsLocationsRegistriesCreateCall) Do() (*DeviceRegistry, error) {
 	}
 	defer res.Body.Close()
 	if err := googleapi.CheckResponse(res); err != nil {
-		return nil, gensupport.WrapError(err)
+		return nil, newError(gensupport.WrapError(err), c.parent)
 	}
 	ret := &DeviceRegistry{
 		ServerResponse: googleapi.ServerResponse{
@@ -2127,7 +2367,7 @@ func (c *ProjectsLocationsRegistriesCreateCall) Do() (*DeviceRegistry, error) {
 		},
 	}
 	target := &ret
-	if err := gensupport.DecodeResponse(target, res); err != nil {
+	if err := decodeResponse(target, res, c.urlParams_.Get("fields")); err != nil {
 		return nil, err
 	}
 	return ret, nil
@@ -2171,6 +2411,7 @@ type ProjectsLocationsRegistriesDeleteCall struct {
 	urlParams_ gensupport.URLParams
 	ctx_       context.Context
 	header_    http.Header
+	retry      *RetryConfig
 }
 
 // Delete: Deletes a device registry configuration.
@@ -2188,6 +2429,7 @@ func (r *ProjectsLocationsRegistriesService) Delete(name string) *ProjectsLocati
 // https://developers.google.com/gdata/docs/2.0/basics#PartialResponse
 // for more information.
 func (c *ProjectsLocationsRegistriesDeleteCall) Fields(s ...googleapi.Field) *ProjectsLocationsRegistriesDeleteCall {
+	c.urlParams_.Set("fields", googleapi.CombineFields(s))
 	return c
 }
 
@@ -2208,16 +2450,27 @@ func (c *ProjectsLocationsRegistriesDeleteCall) Header() http.Header {
 	return c.header_
 }
 
+// Retry overrides the Service retry configuration for this call.
+// Passing a RetryConfig with MaxAttempts set to 1 disables retries.
+func (c *ProjectsLocationsRegistriesDeleteCall) Retry(retry *RetryConfig) *ProjectsLocationsRegistriesDeleteCall {
+	c.retry = retry
+	return c
+}
+
 func (c *ProjectsLocationsRegistriesDeleteCall) doRequest(alt string) (*http.Response, error) {
-	reqHeaders := make(http.Header)
-	for k, v := range c.header_ {
-		reqHeaders[k] = v
+	if _, err := ParseRegistryName(c.name); err != nil {
+		return nil, err
 	}
+	reqHeaders := c.s.requestHeaders(c.header_)
 	var body io.Reader = nil
-	reqHeaders.Set("ClearBlade-UserToken", c.s.ServiceAccountCredentials.Token)
+	serviceAccount, err := c.s.serviceAccountCredentials(c.ctx_)
+	if err != nil {
+		return nil, err
+	}
+	reqHeaders.Set("ClearBlade-UserToken", serviceAccount.Token)
 	c.urlParams_.Set("name", c.name)
 
-	urls := fmt.Sprintf("%s/api/v/4/webhook/execute/%s/cloudiot", c.s.ServiceAccountCredentials.Url, c.s.ServiceAccountCredentials.SystemKey)
+	urls := fmt.Sprintf("%s/api/v/4/webhook/execute/%s/cloudiot", serviceAccount.Url, serviceAccount.SystemKey)
 	urls += "?" + c.urlParams_.Encode()
 	req, err := http.NewRequest("DELETE", urls, body)
 	if err != nil {
@@ -2228,7 +2481,7 @@ func (c *ProjectsLocationsRegistriesDeleteCall) doRequest(alt string) (*http.Res
 	googleapi.Expand(req.URL, map[string]string{
 		"name": c.name,
 	})
-	return gensupport.SendRequest(c.ctx_, c.s.client, req)
+	return c.s.sendRequest(c.ctx_, req, c.retry, true)
 }
 
 // Do executes the "cloudiot.projects.locations.registries.delete" call.
@@ -2238,7 +2491,8 @@ func (c *ProjectsLocationsRegistriesDeleteCall) doRequest(alt string) (*http.Res
 // in error.(*googleapi.Error).Header. Use googleapi.IsNotModified to
 // check whether the returned error was because http.StatusNotModified
 // was returned.
-func (c *ProjectsLocationsRegistriesDeleteCall) Do() (*Empty, error) {
+func (c *ProjectsLocationsRegistriesDeleteCall) Do(opts ...googleapi.CallOption) (*Empty, error) {
+	gensupport.SetOptions(c.urlParams_, opts...)
 	res, err := c.doRequest("json")
 	if res != nil && res.StatusCode == http.StatusNotModified {
 		if res.Body != nil {
@@ -2254,7 +2508,7 @@ func (c *ProjectsLocationsRegistriesDeleteCall) Do() (*Empty, error) {
 	}
 	defer res.Body.Close()
 	if err := googleapi.CheckResponse(res); err != nil {
-		return nil, gensupport.WrapError(err)
+		return nil, newError(gensupport.WrapError(err), c.name)
 	}
 	ret := &Empty{
 		ServerResponse: googleapi.ServerResponse{
@@ -2301,6 +2555,7 @@ type ProjectsLocationsRegistriesGetCall struct {
 	ifNoneMatch_ string
 	ctx_         context.Context
 	header_      http.Header
+	retry        *RetryConfig
 }
 
 // Get: Gets a device registry configuration.
@@ -2318,6 +2573,7 @@ func (r *ProjectsLocationsRegistriesService) Get(name string) *ProjectsLocations
 // https://developers.google.com/gdata/docs/2.0/basics#PartialResponse
 // for more information.
 func (c *ProjectsLocationsRegistriesGetCall) Fields(s ...googleapi.Field) *ProjectsLocationsRegistriesGetCall {
+	c.urlParams_.Set("fields", googleapi.CombineFields(s))
 	return c
 }
 
@@ -2348,22 +2604,26 @@ func (c *ProjectsLocationsRegistriesGetCall) Header() http.Header {
 	return c.header_
 }
 
+// Retry overrides the Service retry configuration for this call.
+// Passing a RetryConfig with MaxAttempts set to 1 disables retries.
+func (c *ProjectsLocationsRegistriesGetCall) Retry(retry *RetryConfig) *ProjectsLocationsRegistriesGetCall {
+	c.retry = retry
+	return c
+}
+
 func (c *ProjectsLocationsRegistriesGetCall) doRequest(alt string) (*http.Response, error) {
-	reqHeaders := make(http.Header)
-	for k, v := range c.header_ {
-		reqHeaders[k] = v
-	}
+	reqHeaders := c.s.requestHeaders(c.header_)
 	if c.ifNoneMatch_ != "" {
 		reqHeaders.Set("If-None-Match", c.ifNoneMatch_)
 	}
 	var body io.Reader = nil
 
-	matches, err := c.s.TemplatePaths.RegistryPathTemplate.Match(c.name)
+	registryName, err := ParseRegistryName(c.name)
 	if err != nil {
 		return nil, err
 	}
-	registry := matches["registry"]
-	location := matches["location"]
+	registry := registryName.Registry
+	location := registryName.Location
 	credentials, err := GetRegistryCredentials(registry, location, c.s)
 	if err != nil {
 		return nil, err
@@ -2381,7 +2641,7 @@ func (c *ProjectsLocationsRegistriesGetCall) doRequest(alt string) (*http.Respon
 	googleapi.Expand(req.URL, map[string]string{
 		"name": c.name,
 	})
-	return gensupport.SendRequest(c.ctx_, c.s.client, req)
+	return c.s.sendRegistryRequest(c.ctx_, req, c.retry, true, location, registry)
 }
 
 // Do executes the "cloudiot.projects.locations.registries.get" call.
@@ -2391,7 +2651,8 @@ func (c *ProjectsLocationsRegistriesGetCall) doRequest(alt string) (*http.Respon
 // at all) in error.(*googleapi.Error).Header. Use
 // googleapi.IsNotModified to check whether the returned error was
 // because http.StatusNotModified was returned.
-func (c *ProjectsLocationsRegistriesGetCall) Do() (*DeviceRegistry, error) {
+func (c *ProjectsLocationsRegistriesGetCall) Do(opts ...googleapi.CallOption) (*DeviceRegistry, error) {
+	gensupport.SetOptions(c.urlParams_, opts...)
 	res, err := c.doRequest("json")
 	if res != nil && res.StatusCode == http.StatusNotModified {
 		if res.Body != nil {
@@ -2407,7 +2668,7 @@ func (c *ProjectsLocationsRegistriesGetCall) Do() (*DeviceRegistry, error) {
 	}
 	defer res.Body.Close()
 	if err := googleapi.CheckResponse(res); err != nil {
-		return nil, gensupport.WrapError(err)
+		return nil, newError(gensupport.WrapError(err), c.name)
 	}
 	ret := &DeviceRegistry{
 		ServerResponse: googleapi.ServerResponse{
@@ -2416,7 +2677,7 @@ func (c *ProjectsLocationsRegistriesGetCall) Do() (*DeviceRegistry, error) {
 		},
 	}
 	target := &ret
-	if err := gensupport.DecodeResponse(target, res); err != nil {
+	if err := decodeResponse(target, res, c.urlParams_.Get("fields")); err != nil {
 		return nil, err
 	}
 	return ret, nil
@@ -2458,6 +2719,7 @@ type ProjectsLocationsRegistriesGetIamPolicyCall struct {
 	urlParams_          gensupport.URLParams
 	ctx_                context.Context
 	header_             http.Header
+	retry               *RetryConfig
 }
 
 // GetIamPolicy: Gets the access control policy for a resource. Returns
@@ -2479,6 +2741,7 @@ func (r *ProjectsLocationsRegistriesService) GetIamPolicy(resource string, getia
 // https://developers.google.com/gdata/docs/2.0/basics#PartialResponse
 // for more information.
 func (c *ProjectsLocationsRegistriesGetIamPolicyCall) Fields(s ...googleapi.Field) *ProjectsLocationsRegistriesGetIamPolicyCall {
+	c.urlParams_.Set("fields", googleapi.CombineFields(s))
 	return c
 }
 
@@ -2499,8 +2762,15 @@ func (c *ProjectsLocationsRegistriesGetIamPolicyCall) Header() http.Header {
 	return c.header_
 }
 
+// Retry overrides the Service retry configuration for this call.
+// Passing a RetryConfig with MaxAttempts set to 1 disables retries.
+func (c *ProjectsLocationsRegistriesGetIamPolicyCall) Retry(retry *RetryConfig) *ProjectsLocationsRegistriesGetIamPolicyCall {
+	c.retry = retry
+	return c
+}
+
 func (c *ProjectsLocationsRegistriesGetIamPolicyCall) doRequest(alt string) (*http.Response, error) {
-	return nil, errors.New("Not implemented")
+	return nil, ErrNotImplemented
 	// reqHeaders := make(http.Header)
 	// for k, v := range c.header_ {
 	// 	reqHeaders[k] = v
@@ -2531,7 +2801,8 @@ func (c *ProjectsLocationsRegistriesGetIamPolicyCall) doRequest(alt string) (*ht
 // in error.(*googleapi.Error).Header. Use googleapi.IsNotModified to
 // check whether the returned error was because http.StatusNotModified
 // was returned.
-func (c *ProjectsLocationsRegistriesGetIamPolicyCall) Do() (*Policy, error) {
+func (c *ProjectsLocationsRegistriesGetIamPolicyCall) Do(opts ...googleapi.CallOption) (*Policy, error) {
+	gensupport.SetOptions(c.urlParams_, opts...)
 	res, err := c.doRequest("json")
 	if res != nil && res.StatusCode == http.StatusNotModified {
 		if res.Body != nil {
@@ -2547,7 +2818,7 @@ func (c *ProjectsLocationsRegistriesGetIamPolicyCall) Do() (*Policy, error) {
 	}
 	defer res.Body.Close()
 	if err := googleapi.CheckResponse(res); err != nil {
-		return nil, gensupport.WrapError(err)
+		return nil, newError(gensupport.WrapError(err), c.resource)
 	}
 	ret := &Policy{
 		ServerResponse: googleapi.ServerResponse{
@@ -2556,7 +2827,7 @@ func (c *ProjectsLocationsRegistriesGetIamPolicyCall) Do() (*Policy, error) {
 		},
 	}
 	target := &ret
-	if err := gensupport.DecodeResponse(target, res); err != nil {
+	if err := decodeResponse(target, res, c.urlParams_.Get("fields")); err != nil {
 		return nil, err
 	}
 	return ret, nil
@@ -2601,6 +2872,7 @@ type ProjectsLocationsRegistriesListCall struct {
 	ifNoneMatch_ string
 	ctx_         context.Context
 	header_      http.Header
+	retry        *RetryConfig
 }
 
 // List: Lists device registries.
@@ -2668,18 +2940,29 @@ func (c *ProjectsLocationsRegistriesListCall) Header() http.Header {
 	return c.header_
 }
 
+// Retry overrides the Service retry configuration for this call.
+// Passing a RetryConfig with MaxAttempts set to 1 disables retries.
+func (c *ProjectsLocationsRegistriesListCall) Retry(retry *RetryConfig) *ProjectsLocationsRegistriesListCall {
+	c.retry = retry
+	return c
+}
+
 func (c *ProjectsLocationsRegistriesListCall) doRequest(alt string) (*http.Response, error) {
-	reqHeaders := make(http.Header)
-	for k, v := range c.header_ {
-		reqHeaders[k] = v
+	if _, err := ParseLocationName(c.parent); err != nil {
+		return nil, err
 	}
+	reqHeaders := c.s.requestHeaders(c.header_)
 	if c.ifNoneMatch_ != "" {
 		reqHeaders.Set("If-None-Match", c.ifNoneMatch_)
 	}
 	var body io.Reader = nil
-	reqHeaders.Set("ClearBlade-UserToken", c.s.ServiceAccountCredentials.Token)
+	serviceAccount, err := c.s.serviceAccountCredentials(c.ctx_)
+	if err != nil {
+		return nil, err
+	}
+	reqHeaders.Set("ClearBlade-UserToken", serviceAccount.Token)
 
-	urls := fmt.Sprintf("%s/api/v/4/webhook/execute/%s/cloudiot", c.s.ServiceAccountCredentials.Url, c.s.ServiceAccountCredentials.SystemKey)
+	urls := fmt.Sprintf("%s/api/v/4/webhook/execute/%s/cloudiot", serviceAccount.Url, serviceAccount.SystemKey)
 	urls += "?" + c.urlParams_.Encode()
 	req, err := http.NewRequest("GET", urls, body)
 	if err != nil {
@@ -2690,7 +2973,7 @@ func (c *ProjectsLocationsRegistriesListCall) doRequest(alt string) (*http.Respo
 	googleapi.Expand(req.URL, map[string]string{
 		"parent": c.parent,
 	})
-	return gensupport.SendRequest(c.ctx_, c.s.client, req)
+	return c.s.sendRequest(c.ctx_, req, c.retry, true)
 }
 
 // Do executes the "cloudiot.projects.locations.registries.list" call.
@@ -2700,7 +2983,8 @@ func (c *ProjectsLocationsRegistriesListCall) doRequest(alt string) (*http.Respo
 // response was returned at all) in error.(*googleapi.Error).Header. Use
 // googleapi.IsNotModified to check whether the returned error was
 // because http.StatusNotModified was returned.
-func (c *ProjectsLocationsRegistriesListCall) Do() (*ListDeviceRegistriesResponse, error) {
+func (c *ProjectsLocationsRegistriesListCall) Do(opts ...googleapi.CallOption) (*ListDeviceRegistriesResponse, error) {
+	gensupport.SetOptions(c.urlParams_, opts...)
 	res, err := c.doRequest("json")
 	if err != nil {
 		return nil, err
@@ -2717,7 +3001,7 @@ func (c *ProjectsLocationsRegistriesListCall) Do() (*ListDeviceRegistriesRespons
 	}
 	defer res.Body.Close()
 	if err := googleapi.CheckResponse(res); err != nil {
-		return nil, gensupport.WrapError(err)
+		return nil, newError(gensupport.WrapError(err), c.parent)
 	}
 
 	ret := &ListDeviceRegistriesResponse{
@@ -2728,7 +3012,7 @@ func (c *ProjectsLocationsRegistriesListCall) Do() (*ListDeviceRegistriesRespons
 	}
 
 	target := &ret
-	if err := gensupport.DecodeResponse(target, res); err != nil {
+	if err := decodeResponse(target, res, c.urlParams_.Get("fields")); err != nil {
 		return nil, err
 	}
 	return ret, nil
@@ -2794,6 +3078,36 @@ func (c *ProjectsLocationsRegistriesListCall) Pages(ctx context.Context, f func(
 	}
 }
 
+// Iterator returns an iterator over the device registries of all pages, starting
+// from the page set with PageToken. The call itself is not modified.
+func (c *ProjectsLocationsRegistriesListCall) Iterator(ctx context.Context) *Iterator[*DeviceRegistry] {
+	call := *c
+	call.urlParams_ = cloneURLParams(c.urlParams_)
+	call.ctx_ = ctx
+	it := newIterator(func(pageSize int, pageToken string) ([]*DeviceRegistry, string, error) {
+		if pageSize > 0 {
+			call.PageSize(int64(pageSize))
+		}
+		call.PageToken(pageToken)
+		x, err := call.Do()
+		if err != nil {
+			return nil, "", err
+		}
+		return x.DeviceRegistries, x.NextPageToken, nil
+	})
+	it.PageInfo().Token = c.urlParams_.Get("pageToken")
+	return it
+}
+
+// All returns a sequence of the device registries of all pages, for use with a
+// range loop. Each iteration of the sequence lists them again from the page
+// set with PageToken. Iteration stops after the first error.
+func (c *ProjectsLocationsRegistriesListCall) All(ctx context.Context) iter.Seq2[*DeviceRegistry, error] {
+	return func(yield func(*DeviceRegistry, error) bool) {
+		c.Iterator(ctx).All()(yield)
+	}
+}
+
 // method id "cloudiot.projects.locations.registries.patch":
 
 type ProjectsLocationsRegistriesPatchCall struct {
@@ -2803,6 +3117,7 @@ type ProjectsLocationsRegistriesPatchCall struct {
 	urlParams_     gensupport.URLParams
 	ctx_           context.Context
 	header_        http.Header
+	retry          *RetryConfig
 }
 
 // Patch: Updates a device registry configuration.
@@ -2833,6 +3148,7 @@ func (c *ProjectsLocationsRegistriesPatchCall) UpdateMask(updateMask string) *Pr
 // https://developers.google.com/gdata/docs/2.0/basics#PartialResponse
 // for more information.
 func (c *ProjectsLocationsRegistriesPatchCall) Fields(s ...googleapi.Field) *ProjectsLocationsRegistriesPatchCall {
+	c.urlParams_.Set("fields", googleapi.CombineFields(s))
 	return c
 }
 
@@ -2853,11 +3169,15 @@ func (c *ProjectsLocationsRegistriesPatchCall) Header() http.Header {
 	return c.header_
 }
 
+// Retry enables automatic retries for this call. The call is not
+// idempotent, so it is never retried unless a configuration is set here.
+func (c *ProjectsLocationsRegistriesPatchCall) Retry(retry *RetryConfig) *ProjectsLocationsRegistriesPatchCall {
+	c.retry = retry
+	return c
+}
+
 func (c *ProjectsLocationsRegistriesPatchCall) doRequest(alt string) (*http.Response, error) {
-	reqHeaders := make(http.Header)
-	for k, v := range c.header_ {
-		reqHeaders[k] = v
-	}
+	reqHeaders := c.s.requestHeaders(c.header_)
 	var body io.Reader = nil
 	body, err := googleapi.WithoutDataWrapper.JSONReader(c.deviceregistry)
 	if err != nil {
@@ -2865,13 +3185,12 @@ func (c *ProjectsLocationsRegistriesPatchCall) doRequest(alt string) (*http.Resp
 	}
 	reqHeaders.Set("Content-Type", "application/json")
 
-	matches, err := c.s.TemplatePaths.RegistryPathTemplate.Match(c.name)
+	registryName, err := ParseRegistryName(c.name)
 	if err != nil {
 		return nil, err
 	}
-
-	registry := matches["registry"]
-	location := matches["location"]
+	registry := registryName.Registry
+	location := registryName.Location
 	credentials, err := GetRegistryCredentials(registry, location, c.s)
 	if err != nil {
 		return nil, err
@@ -2889,7 +3208,7 @@ func (c *ProjectsLocationsRegistriesPatchCall) doRequest(alt string) (*http.Resp
 	googleapi.Expand(req.URL, map[string]string{
 		"name": c.name,
 	})
-	return gensupport.SendRequest(c.ctx_, c.s.client, req)
+	return c.s.sendRegistryRequest(c.ctx_, req, c.retry, false, location, registry)
 }
 
 // Do executes the "cloudiot.projects.locations.registries.patch" call.
@@ -2899,7 +3218,8 @@ func (c *ProjectsLocationsRegistriesPatchCall) doRequest(alt string) (*http.Resp
 // at all) in error.(*googleapi.Error).Header. Use
 // googleapi.IsNotModified to check whether the returned error was
 // because http.StatusNotModified was returned.
-func (c *ProjectsLocationsRegistriesPatchCall) Do() (*DeviceRegistry, error) {
+func (c *ProjectsLocationsRegistriesPatchCall) Do(opts ...googleapi.CallOption) (*DeviceRegistry, error) {
+	gensupport.SetOptions(c.urlParams_, opts...)
 	res, err := c.doRequest("json")
 	if res != nil && res.StatusCode == http.StatusNotModified {
 		if res.Body != nil {
@@ -2915,7 +3235,7 @@ func (c *ProjectsLocationsRegistriesPatchCall) Do() (*DeviceRegistry, error) {
 	}
 	defer res.Body.Close()
 	if err := googleapi.CheckResponse(res); err != nil {
-		return nil, gensupport.WrapError(err)
+		return nil, newError(gensupport.WrapError(err), c.name)
 	}
 	ret := &DeviceRegistry{
 		ServerResponse: googleapi.ServerResponse{
@@ -2924,7 +3244,7 @@ func (c *ProjectsLocationsRegistriesPatchCall) Do() (*DeviceRegistry, error) {
 		},
 	}
 	target := &ret
-	if err := gensupport.DecodeResponse(target, res); err != nil {
+	if err := decodeResponse(target, res, c.urlParams_.Get("fields")); err != nil {
 		return nil, err
 	}
 	return ret, nil
@@ -2975,6 +3295,7 @@ type ProjectsLocationsRegistriesSetIamPolicyCall struct {
 	urlParams_          gensupport.URLParams
 	ctx_                context.Context
 	header_             http.Header
+	retry               *RetryConfig
 }
 
 // SetIamPolicy: Sets the access control policy on the specified
@@ -3016,8 +3337,15 @@ func (c *ProjectsLocationsRegistriesSetIamPolicyCall) Header() http.Header {
 	return c.header_
 }
 
+// Retry enables automatic retries for this call. The call is not
+// idempotent, so it is never retried unless a configuration is set here.
+func (c *ProjectsLocationsRegistriesSetIamPolicyCall) Retry(retry *RetryConfig) *ProjectsLocationsRegistriesSetIamPolicyCall {
+	c.retry = retry
+	return c
+}
+
 func (c *ProjectsLocationsRegistriesSetIamPolicyCall) doRequest(alt string) (*http.Response, error) {
-	return nil, errors.New("Not implemented")
+	return nil, ErrNotImplemented
 	// reqHeaders := make(http.Header)
 	// for k, v := range c.header_ {
 	// 	reqHeaders[k] = v
@@ -3048,7 +3376,8 @@ func (c *ProjectsLocationsRegistriesSetIamPolicyCall) doRequest(alt string) (*ht
 // in error.(*googleapi.Error).Header. Use googleapi.IsNotModified to
 // check whether the returned error was because http.StatusNotModified
 // was returned.
-func (c *ProjectsLocationsRegistriesSetIamPolicyCall) Do() (*Policy, error) {
+func (c *ProjectsLocationsRegistriesSetIamPolicyCall) Do(opts ...googleapi.CallOption) (*Policy, error) {
+	gensupport.SetOptions(c.urlParams_, opts...)
 	res, err := c.doRequest("json")
 	if res != nil && res.StatusCode == http.StatusNotModified {
 		if res.Body != nil {
@@ -3064,7 +3393,7 @@ func (c *ProjectsLocationsRegistriesSetIamPolicyCall) Do() (*Policy, error) {
 	}
 	defer res.Body.Close()
 	if err := googleapi.CheckResponse(res); err != nil {
-		return nil, gensupport.WrapError(err)
+		return nil, newError(gensupport.WrapError(err), c.resource)
 	}
 	ret := &Policy{
 		ServerResponse: googleapi.ServerResponse{
@@ -3073,7 +3402,7 @@ func (c *ProjectsLocationsRegistriesSetIamPolicyCall) Do() (*Policy, error) {
 		},
 	}
 	target := &ret
-	if err := gensupport.DecodeResponse(target, res); err != nil {
+	if err := decodeResponse(target, res, c.urlParams_.Get("fields")); err != nil {
 		return nil, err
 	}
 	return ret, nil
@@ -3118,6 +3447,7 @@ type ProjectsLocationsRegistriesTestIamPermissionsCall struct {
 	urlParams_                gensupport.URLParams
 	ctx_                      context.Context
 	header_                   http.Header
+	retry                     *RetryConfig
 }
 
 // TestIamPermissions: Returns permissions that a caller has on the
@@ -3160,8 +3490,15 @@ func (c *ProjectsLocationsRegistriesTestIamPermissionsCall) Header() http.Header
 	return c.header_
 }
 
+// Retry overrides the Service retry configuration for this call.
+// Passing a RetryConfig with MaxAttempts set to 1 disables retries.
+func (c *ProjectsLocationsRegistriesTestIamPermissionsCall) Retry(retry *RetryConfig) *ProjectsLocationsRegistriesTestIamPermissionsCall {
+	c.retry = retry
+	return c
+}
+
 func (c *ProjectsLocationsRegistriesTestIamPermissionsCall) doRequest(alt string) (*http.Response, error) {
-	return nil, errors.New("Not implemented")
+	return nil, ErrNotImplemented
 	// reqHeaders := make(http.Header)
 	// for k, v := range c.header_ {
 	// 	reqHeaders[k] = v
@@ -3192,7 +3529,8 @@ func (c *ProjectsLocationsRegistriesTestIamPermissionsCall) doRequest(alt string
 // was returned at all) in error.(*googleapi.Error).Header. Use
 // googleapi.IsNotModified to check whether the returned error was
 // because http.StatusNotModified was returned.
-func (c *ProjectsLocationsRegistriesTestIamPermissionsCall) Do() (*TestIamPermissionsResponse, error) {
+func (c *ProjectsLocationsRegistriesTestIamPermissionsCall) Do(opts ...googleapi.CallOption) (*TestIamPermissionsResponse, error) {
+	gensupport.SetOptions(c.urlParams_, opts...)
 	res, err := c.doRequest("json")
 	if res != nil && res.StatusCode == http.StatusNotModified {
 		if res.Body != nil {
@@ -3208,7 +3546,7 @@ func (c *ProjectsLocationsRegistriesTestIamPermissionsCall) Do() (*TestIamPermis
 	}
 	defer res.Body.Close()
 	if err := googleapi.CheckResponse(res); err != nil {
-		return nil, gensupport.WrapError(err)
+		return nil, newError(gensupport.WrapError(err), c.resource)
 	}
 	ret := &TestIamPermissionsResponse{
 		ServerResponse: googleapi.ServerResponse{
@@ -3217,7 +3555,7 @@ func (c *ProjectsLocationsRegistriesTestIamPermissionsCall) Do() (*TestIamPermis
 		},
 	}
 	target := &ret
-	if err := gensupport.DecodeResponse(target, res); err != nil {
+	if err := decodeResponse(target, res, c.urlParams_.Get("fields")); err != nil {
 		return nil, err
 	}
 	return ret, nil
@@ -3262,6 +3600,7 @@ type ProjectsLocationsRegistriesUnbindDeviceFromGatewayCall struct {
 	urlParams_                     gensupport.URLParams
 	ctx_                           context.Context
 	header_                        http.Header
+	retry                          *RetryConfig
 }
 
 // UnbindDeviceFromGateway: Deletes the association between the device
@@ -3282,6 +3621,7 @@ func (r *ProjectsLocationsRegistriesService) UnbindDeviceFromGateway(parent stri
 // https://developers.google.com/gdata/docs/2.0/basics#PartialResponse
 // for more information.
 func (c *ProjectsLocationsRegistriesUnbindDeviceFromGatewayCall) Fields(s ...googleapi.Field) *ProjectsLocationsRegistriesUnbindDeviceFromGatewayCall {
+	c.urlParams_.Set("fields", googleapi.CombineFields(s))
 	return c
 }
 
@@ -3302,11 +3642,15 @@ func (c *ProjectsLocationsRegistriesUnbindDeviceFromGatewayCall) Header() http.H
 	return c.header_
 }
 
+// Retry enables automatic retries for this call. The call is not
+// idempotent, so it is never retried unless a configuration is set here.
+func (c *ProjectsLocationsRegistriesUnbindDeviceFromGatewayCall) Retry(retry *RetryConfig) *ProjectsLocationsRegistriesUnbindDeviceFromGatewayCall {
+	c.retry = retry
+	return c
+}
+
 func (c *ProjectsLocationsRegistriesUnbindDeviceFromGatewayCall) doRequest(alt string) (*http.Response, error) {
-	reqHeaders := make(http.Header)
-	for k, v := range c.header_ {
-		reqHeaders[k] = v
-	}
+	reqHeaders := c.s.requestHeaders(c.header_)
 	var body io.Reader = nil
 	body, err := googleapi.WithoutDataWrapper.JSONReader(c.unbinddevicefromgatewayrequest)
 	if err != nil {
@@ -3314,12 +3658,12 @@ func (c *ProjectsLocationsRegistriesUnbindDeviceFromGatewayCall) doRequest(alt s
 	}
 	reqHeaders.Set("Content-Type", "application/json")
 
-	matches, err := c.s.TemplatePaths.RegistryPathTemplate.Match(c.parent)
+	registryName, err := ParseRegistryName(c.parent)
 	if err != nil {
 		return nil, err
 	}
-	registry := matches["registry"]
-	location := matches["location"]
+	registry := registryName.Registry
+	location := registryName.Location
 	credentials, err := GetRegistryCredentials(registry, location, c.s)
 	if err != nil {
 		return nil, err
@@ -3337,7 +3681,7 @@ func (c *ProjectsLocationsRegistriesUnbindDeviceFromGatewayCall) doRequest(alt s
 	googleapi.Expand(req.URL, map[string]string{
 		"parent": c.parent,
 	})
-	return gensupport.SendRequest(c.ctx_, c.s.client, req)
+	return c.s.sendRegistryRequest(c.ctx_, req, c.retry, false, location, registry)
 }
 
 // Do executes the "cloudiot.projects.locations.registries.unbindDeviceFromGateway" call.
@@ -3347,7 +3691,8 @@ func (c *ProjectsLocationsRegistriesUnbindDeviceFromGatewayCall) doRequest(alt s
 // a response was returned at all) in error.(*googleapi.Error).Header.
 // Use googleapi.IsNotModified to check whether the returned error was
 // because http.StatusNotModified was returned.
-func (c *ProjectsLocationsRegistriesUnbindDeviceFromGatewayCall) Do() (*UnbindDeviceFromGatewayResponse, error) {
+func (c *ProjectsLocationsRegistriesUnbindDeviceFromGatewayCall) Do(opts ...googleapi.CallOption) (*UnbindDeviceFromGatewayResponse, error) {
+	gensupport.SetOptions(c.urlParams_, opts...)
 	res, err := c.doRequest("json")
 	if res != nil && res.StatusCode == http.StatusNotModified {
 		if res.Body != nil {
@@ -3363,7 +3708,7 @@ func (c *ProjectsLocationsRegistriesUnbindDeviceFromGatewayCall) Do() (*UnbindDe
 	}
 	defer res.Body.Close()
 	if err := googleapi.CheckResponse(res); err != nil {
-		return nil, gensupport.WrapError(err)
+		return nil, newError(gensupport.WrapError(err), c.parent)
 	}
 	ret := &UnbindDeviceFromGatewayResponse{
 		ServerResponse: googleapi.ServerResponse{
@@ -3372,7 +3717,7 @@ func (c *ProjectsLocationsRegistriesUnbindDeviceFromGatewayCall) Do() (*UnbindDe
 		},
 	}
 	target := &ret
-	if err := gensupport.DecodeResponse(target, res); err != nil {
+	if err := decodeResponse(target, res, c.urlParams_.Get("fields")); err != nil {
 		return nil, err
 	}
 	return ret, nil
@@ -3417,6 +3762,7 @@ type ProjectsLocationsRegistriesDevicesCreateCall struct {
 	urlParams_ gensupport.URLParams
 	ctx_       context.Context
 	header_    http.Header
+	retry      *RetryConfig
 }
 
 // Create: Creates a device in a device registry.
@@ -3435,6 +3781,7 @@ func (r *ProjectsLocationsRegistriesDevicesService) Create(parent string, device
 // https://developers.google.com/gdata/docs/2.0/basics#PartialResponse
 // for more information.
 func (c *ProjectsLocationsRegistriesDevicesCreateCall) Fields(s ...googleapi.Field) *ProjectsLocationsRegistriesDevicesCreateCall {
+	c.urlParams_.Set("fields", googleapi.CombineFields(s))
 	return c
 }
 
@@ -3449,26 +3796,38 @@ func (c *ProjectsLocationsRegistriesDevicesCreateCall) Context(ctx context.Conte
 // Header returns an http.Header that can be modified by the caller to
 // add HTTP headers to the request.
 func (c *ProjectsLocationsRegistriesDevicesCreateCall) Header() http.Header {
-	return http.Header{}
+	if c.header_ == nil {
+		c.header_ = make(http.Header)
+	}
+	return c.header_
+}
+
+// Retry enables automatic retries for this call. The call is not
+// idempotent, so it is never retried unless a configuration is set here.
+func (c *ProjectsLocationsRegistriesDevicesCreateCall) Retry(retry *RetryConfig) *ProjectsLocationsRegistriesDevicesCreateCall {
+	c.retry = retry
+	return c
 }
 
 func (c *ProjectsLocationsRegistriesDevicesCreateCall) doRequest(alt string) (*http.Response, error) {
-	reqHeaders := make(http.Header)
-	for k, v := range c.header_ {
-		reqHeaders[k] = v
-	}
+	reqHeaders := c.s.requestHeaders(c.header_)
 	var body io.Reader = nil
 	body, err := googleapi.WithoutDataWrapper.JSONReader(c.device)
 	if err != nil {
 		return nil, err
 	}
 	reqHeaders.Set("Content-Type", "application/json")
-	matches, err := c.s.TemplatePaths.RegistryPathTemplate.Match(c.parent)
+	registryName, err := ParseRegistryName(c.parent)
 	if err != nil {
 		return nil, err
 	}
-	registry := matches["registry"]
-	location := matches["location"]
+	if c.device != nil {
+		if err := validateResourceID("device", c.device.Id); err != nil {
+			return nil, err
+		}
+	}
+	registry := registryName.Registry
+	location := registryName.Location
 	credentials, err := GetRegistryCredentials(registry, location, c.s)
 	if err != nil {
 		return nil, err
@@ -3486,7 +3845,7 @@ func (c *ProjectsLocationsRegistriesDevicesCreateCall) doRequest(alt string) (*h
 	googleapi.Expand(req.URL, map[string]string{
 		"parent": c.parent,
 	})
-	return gensupport.SendRequest(c.ctx_, c.s.client, req)
+	return c.s.sendRegistryRequest(c.ctx_, req, c.retry, false, location, registry)
 }
 
 // Do executes the "cloudiot.projects.locations.registries.devices.create" call.
@@ -3496,7 +3855,8 @@ func (c *ProjectsLocationsRegistriesDevicesCreateCall) doRequest(alt string) (*h
 // in error.(*googleapi.Error).Header. Use googleapi.IsNotModified to
 // check whether the returned error was because http.StatusNotModified
 // was returned.
-func (c *ProjectsLocationsRegistriesDevicesCreateCall) Do() (*Device, error) {
+func (c *ProjectsLocationsRegistriesDevicesCreateCall) Do(opts ...googleapi.CallOption) (*Device, error) {
+	gensupport.SetOptions(c.urlParams_, opts...)
 	res, err := c.doRequest("json")
 	if res != nil && res.StatusCode == http.StatusNotModified {
 		if res.Body != nil {
@@ -3512,7 +3872,7 @@ func (c *ProjectsLocationsRegistriesDevicesCreateCall) Do() (*Device, error) {
 	}
 	defer res.Body.Close()
 	if err := googleapi.CheckResponse(res); err != nil {
-		return nil, gensupport.WrapError(err)
+		return nil, newError(gensupport.WrapError(err), c.parent)
 	}
 	ret := &Device{
 		ServerResponse: googleapi.ServerResponse{
@@ -3521,7 +3881,7 @@ func (c *ProjectsLocationsRegistriesDevicesCreateCall) Do() (*Device, error) {
 		},
 	}
 	target := &ret
-	if err := gensupport.DecodeResponse(target, res); err != nil {
+	if err := decodeResponse(target, res, c.urlParams_.Get("fields")); err != nil {
 		return nil, err
 	}
 	return ret, nil
@@ -3565,6 +3925,7 @@ type ProjectsLocationsRegistriesDevicesDeleteCall struct {
 	urlParams_ gensupport.URLParams
 	ctx_       context.Context
 	header_    http.Header
+	retry      *RetryConfig
 }
 
 // Delete: Deletes a device.
@@ -3584,6 +3945,7 @@ func (r *ProjectsLocationsRegistriesDevicesService) Delete(name string) *Project
 // https://developers.google.com/gdata/docs/2.0/basics#PartialResponse
 // for more information.
 func (c *ProjectsLocationsRegistriesDevicesDeleteCall) Fields(s ...googleapi.Field) *ProjectsLocationsRegistriesDevicesDeleteCall {
+	c.urlParams_.Set("fields", googleapi.CombineFields(s))
 	return c
 }
 
@@ -3604,19 +3966,23 @@ func (c *ProjectsLocationsRegistriesDevicesDeleteCall) Header() http.Header {
 	return c.header_
 }
 
+// Retry overrides the Service retry configuration for this call.
+// Passing a RetryConfig with MaxAttempts set to 1 disables retries.
+func (c *ProjectsLocationsRegistriesDevicesDeleteCall) Retry(retry *RetryConfig) *ProjectsLocationsRegistriesDevicesDeleteCall {
+	c.retry = retry
+	return c
+}
+
 func (c *ProjectsLocationsRegistriesDevicesDeleteCall) doRequest(alt string) (*http.Response, error) {
-	reqHeaders := make(http.Header)
-	for k, v := range c.header_ {
-		reqHeaders[k] = v
-	}
+	reqHeaders := c.s.requestHeaders(c.header_)
 	var body io.Reader = nil
 	reqHeaders.Set("Content-Type", "application/json")
-	matches, err := c.s.TemplatePaths.DevicePathTemplate.Match(c.name)
+	deviceName, err := ParseDeviceName(c.name)
 	if err != nil {
 		return nil, err
 	}
-	registry := matches["registry"]
-	location := matches["location"]
+	registry := deviceName.Registry
+	location := deviceName.Location
 	credentials, err := GetRegistryCredentials(registry, location, c.s)
 	if err != nil {
 		return nil, err
@@ -3635,7 +4001,7 @@ func (c *ProjectsLocationsRegistriesDevicesDeleteCall) doRequest(alt string) (*h
 	// googleapi.Expand(req.URL, map[string]string{
 	// 	"name": c.name,
 	// })
-	return gensupport.SendRequest(c.ctx_, c.s.client, req)
+	return c.s.sendRegistryRequest(c.ctx_, req, c.retry, true, location, registry)
 }
 
 // Do executes the "cloudiot.projects.locations.registries.devices.delete" call.
@@ -3645,7 +4011,8 @@ func (c *ProjectsLocationsRegistriesDevicesDeleteCall) doRequest(alt string) (*h
 // in error.(*googleapi.Error).Header. Use googleapi.IsNotModified to
 // check whether the returned error was because http.StatusNotModified
 // was returned.
-func (c *ProjectsLocationsRegistriesDevicesDeleteCall) Do() (*Empty, error) {
+func (c *ProjectsLocationsRegistriesDevicesDeleteCall) Do(opts ...googleapi.CallOption) (*Empty, error) {
+	gensupport.SetOptions(c.urlParams_, opts...)
 	res, err := c.doRequest("json")
 	if res != nil && res.StatusCode == http.StatusNotModified {
 		if res.Body != nil {
@@ -3661,7 +4028,7 @@ func (c *ProjectsLocationsRegistriesDevicesDeleteCall) Do() (*Empty, error) {
 	}
 	defer res.Body.Close()
 	if err := googleapi.CheckResponse(res); err != nil {
-		return nil, gensupport.WrapError(err)
+		return nil, newError(gensupport.WrapError(err), c.name)
 	}
 	ret := &Empty{
 		ServerResponse: googleapi.ServerResponse{
@@ -3708,6 +4075,7 @@ type ProjectsLocationsRegistriesDevicesGetCall struct {
 	ifNoneMatch_ string
 	ctx_         context.Context
 	header_      http.Header
+	retry        *RetryConfig
 }
 
 // Get: Gets details about a device.
@@ -3743,6 +4111,7 @@ func (c *ProjectsLocationsRegistriesDevicesGetCall) Base64Encode(base64Encode bo
 // https://developers.google.com/gdata/docs/2.0/basics#PartialResponse
 // for more information.
 func (c *ProjectsLocationsRegistriesDevicesGetCall) Fields(s ...googleapi.Field) *ProjectsLocationsRegistriesDevicesGetCall {
+	c.urlParams_.Set("fields", googleapi.CombineFields(s))
 	return c
 }
 
@@ -3772,22 +4141,26 @@ func (c *ProjectsLocationsRegistriesDevicesGetCall) Header() http.Header {
 	return c.header_
 }
 
+// Retry overrides the Service retry configuration for this call.
+// Passing a RetryConfig with MaxAttempts set to 1 disables retries.
+func (c *ProjectsLocationsRegistriesDevicesGetCall) Retry(retry *RetryConfig) *ProjectsLocationsRegistriesDevicesGetCall {
+	c.retry = retry
+	return c
+}
+
 func (c *ProjectsLocationsRegistriesDevicesGetCall) doRequest(alt string) (*http.Response, error) {
-	reqHeaders := make(http.Header)
-	for k, v := range c.header_ {
-		reqHeaders[k] = v
-	}
+	reqHeaders := c.s.requestHeaders(c.header_)
 	if c.ifNoneMatch_ != "" {
 		reqHeaders.Set("If-None-Match", c.ifNoneMatch_)
 	}
 	var body io.Reader = nil
 	reqHeaders.Set("Content-Type", "application/json")
-	matches, err := c.s.TemplatePaths.DevicePathTemplate.Match(c.name)
+	deviceName, err := ParseDeviceName(c.name)
 	if err != nil {
 		return nil, err
 	}
-	registry := matches["registry"]
-	location := matches["location"]
+	registry := deviceName.Registry
+	location := deviceName.Location
 	credentials, err := GetRegistryCredentials(registry, location, c.s)
 	if err != nil {
 		return nil, err
@@ -3806,7 +4179,7 @@ func (c *ProjectsLocationsRegistriesDevicesGetCall) doRequest(alt string) (*http
 	googleapi.Expand(req.URL, map[string]string{
 		"name": c.name,
 	})
-	return gensupport.SendRequest(c.ctx_, c.s.client, req)
+	return c.s.sendRegistryRequest(c.ctx_, req, c.retry, true, location, registry)
 }
 
 // Do executes the "cloudiot.projects.locations.registries.devices.get" call.
@@ -3816,7 +4189,8 @@ func (c *ProjectsLocationsRegistriesDevicesGetCall) doRequest(alt string) (*http
 // in error.(*googleapi.Error).Header. Use googleapi.IsNotModified to
 // check whether the returned error was because http.StatusNotModified
 // was returned.
-func (c *ProjectsLocationsRegistriesDevicesGetCall) Do() (*Device, error) {
+func (c *ProjectsLocationsRegistriesDevicesGetCall) Do(opts ...googleapi.CallOption) (*Device, error) {
+	gensupport.SetOptions(c.urlParams_, opts...)
 	res, err := c.doRequest("json")
 	if res != nil && res.StatusCode == http.StatusNotModified {
 		if res.Body != nil {
@@ -3832,7 +4206,7 @@ func (c *ProjectsLocationsRegistriesDevicesGetCall) Do() (*Device, error) {
 	}
 	defer res.Body.Close()
 	if err := googleapi.CheckResponse(res); err != nil {
-		return nil, gensupport.WrapError(err)
+		return nil, newError(gensupport.WrapError(err), c.name)
 	}
 	ret := &Device{
 		ServerResponse: googleapi.ServerResponse{
@@ -3841,7 +4215,7 @@ func (c *ProjectsLocationsRegistriesDevicesGetCall) Do() (*Device, error) {
 		},
 	}
 	target := &ret
-	if err := gensupport.DecodeResponse(target, res); err != nil {
+	if err := decodeResponse(target, res, c.urlParams_.Get("fields")); err != nil {
 		return nil, err
 	}
 	return ret, nil
@@ -3889,6 +4263,7 @@ type ProjectsLocationsRegistriesDevicesListCall struct {
 	ifNoneMatch_ string
 	ctx_         context.Context
 	header_      http.Header
+	retry        *RetryConfig
 }
 
 // List: List devices in a device registry.
@@ -4025,21 +4400,25 @@ func (c *ProjectsLocationsRegistriesDevicesListCall) Header() http.Header {
 	return c.header_
 }
 
+// Retry overrides the Service retry configuration for this call.
+// Passing a RetryConfig with MaxAttempts set to 1 disables retries.
+func (c *ProjectsLocationsRegistriesDevicesListCall) Retry(retry *RetryConfig) *ProjectsLocationsRegistriesDevicesListCall {
+	c.retry = retry
+	return c
+}
+
 func (c *ProjectsLocationsRegistriesDevicesListCall) doRequest(alt string) (*http.Response, error) {
-	reqHeaders := make(http.Header)
-	for k, v := range c.header_ {
-		reqHeaders[k] = v
-	}
+	reqHeaders := c.s.requestHeaders(c.header_)
 	if c.ifNoneMatch_ != "" {
 		reqHeaders.Set("If-None-Match", c.ifNoneMatch_)
 	}
 	var body io.Reader = nil
-	matches, err := c.s.TemplatePaths.RegistryPathTemplate.Match(c.parent)
+	registryName, err := ParseRegistryName(c.parent)
 	if err != nil {
 		return nil, err
 	}
-	registry := matches["registry"]
-	location := matches["location"]
+	registry := registryName.Registry
+	location := registryName.Location
 	credentials, err := GetRegistryCredentials(registry, location, c.s)
 	if err != nil {
 		return nil, err
@@ -4056,7 +4435,7 @@ func (c *ProjectsLocationsRegistriesDevicesListCall) doRequest(alt string) (*htt
 	googleapi.Expand(req.URL, map[string]string{
 		"parent": c.parent,
 	})
-	return gensupport.SendRequest(c.ctx_, c.s.client, req)
+	return c.s.sendRegistryRequest(c.ctx_, req, c.retry, true, location, registry)
 }
 
 // Do executes the "cloudiot.projects.locations.registries.devices.list" call.
@@ -4066,7 +4445,8 @@ func (c *ProjectsLocationsRegistriesDevicesListCall) doRequest(alt string) (*htt
 // returned at all) in error.(*googleapi.Error).Header. Use
 // googleapi.IsNotModified to check whether the returned error was
 // because http.StatusNotModified was returned.
-func (c *ProjectsLocationsRegistriesDevicesListCall) Do() (*ListDevicesResponse, error) {
+func (c *ProjectsLocationsRegistriesDevicesListCall) Do(opts ...googleapi.CallOption) (*ListDevicesResponse, error) {
+	gensupport.SetOptions(c.urlParams_, opts...)
 	res, err := c.doRequest("json")
 	if res != nil && res.StatusCode == http.StatusNotModified {
 		if res.Body != nil {
@@ -4082,7 +4462,7 @@ func (c *ProjectsLocationsRegistriesDevicesListCall) Do() (*ListDevicesResponse,
 	}
 	defer res.Body.Close()
 	if err := googleapi.CheckResponse(res); err != nil {
-		return nil, gensupport.WrapError(err)
+		return nil, newError(gensupport.WrapError(err), c.parent)
 	}
 	ret := &ListDevicesResponse{
 		ServerResponse: googleapi.ServerResponse{
@@ -4091,7 +4471,7 @@ func (c *ProjectsLocationsRegistriesDevicesListCall) Do() (*ListDevicesResponse,
 		},
 	}
 	target := &ret
-	if err := gensupport.DecodeResponse(target, res); err != nil {
+	if err := decodeResponse(target, res, c.urlParams_.Get("fields")); err != nil {
 		return nil, err
 	}
 	return ret, nil
@@ -4200,6 +4580,36 @@ func (c *ProjectsLocationsRegistriesDevicesListCall) Pages(ctx context.Context,
 	}
 }
 
+// Iterator returns an iterator over the devices of all pages, starting
+// from the page set with PageToken. The call itself is not modified.
+func (c *ProjectsLocationsRegistriesDevicesListCall) Iterator(ctx context.Context) *Iterator[*Device] {
+	call := *c
+	call.urlParams_ = cloneURLParams(c.urlParams_)
+	call.ctx_ = ctx
+	it := newIterator(func(pageSize int, pageToken string) ([]*Device, string, error) {
+		if pageSize > 0 {
+			call.PageSize(int64(pageSize))
+		}
+		call.PageToken(pageToken)
+		x, err := call.Do()
+		if err != nil {
+			return nil, "", err
+		}
+		return x.Devices, x.NextPageToken, nil
+	})
+	it.PageInfo().Token = c.urlParams_.Get("pageToken")
+	return it
+}
+
+// All returns a sequence of the devices of all pages, for use with a
+// range loop. Each iteration of the sequence lists them again from the page
+// set with PageToken. Iteration stops after the first error.
+func (c *ProjectsLocationsRegistriesDevicesListCall) All(ctx context.Context) iter.Seq2[*Device, error] {
+	return func(yield func(*Device, error) bool) {
+		c.Iterator(ctx).All()(yield)
+	}
+}
+
 // method id "cloudiot.projects.locations.registries.devices.modifyCloudToDeviceConfig":
 
 type ProjectsLocationsRegistriesDevicesModifyCloudToDeviceConfigCall struct {
@@ -4209,6 +4619,7 @@ type ProjectsLocationsRegistriesDevicesModifyCloudToDeviceConfigCall struct {
 	urlParams_                       gensupport.URLParams
 	ctx_                             context.Context
 	header_                          http.Header
+	retry                            *RetryConfig
 }
 
 // ModifyCloudToDeviceConfig: Modifies the configuration for the device,
@@ -4254,23 +4665,27 @@ func (c *ProjectsLocationsRegistriesDevicesModifyCloudToDeviceConfigCall) Header
 	return c.header_
 }
 
+// Retry enables automatic retries for this call. The call is not
+// idempotent, so it is never retried unless a configuration is set here.
+func (c *ProjectsLocationsRegistriesDevicesModifyCloudToDeviceConfigCall) Retry(retry *RetryConfig) *ProjectsLocationsRegistriesDevicesModifyCloudToDeviceConfigCall {
+	c.retry = retry
+	return c
+}
+
 func (c *ProjectsLocationsRegistriesDevicesModifyCloudToDeviceConfigCall) doRequest(alt string) (*http.Response, error) {
-	reqHeaders := make(http.Header)
-	for k, v := range c.header_ {
-		reqHeaders[k] = v
-	}
+	reqHeaders := c.s.requestHeaders(c.header_)
 	var body io.Reader = nil
 	body, err := googleapi.WithoutDataWrapper.JSONReader(c.modifycloudtodeviceconfigrequest)
 	if err != nil {
 		return nil, err
 	}
 	reqHeaders.Set("Content-Type", "application/json")
-	matches, err := c.s.TemplatePaths.DevicePathTemplate.Match(c.name)
+	deviceName, err := ParseDeviceName(c.name)
 	if err != nil {
 		return nil, err
 	}
-	registry := matches["registry"]
-	location := matches["location"]
+	registry := deviceName.Registry
+	location := deviceName.Location
 	credentials, err := GetRegistryCredentials(registry, location, c.s)
 	if err != nil {
 		return nil, err
@@ -4288,7 +4703,7 @@ func (c *ProjectsLocationsRegistriesDevicesModifyCloudToDeviceConfigCall) doRequ
 	googleapi.Expand(req.URL, map[string]string{
 		"name": c.name,
 	})
-	return gensupport.SendRequest(c.ctx_, c.s.client, req)
+	return c.s.sendRegistryRequest(c.ctx_, req, c.retry, false, location, registry)
 }
 
 // Do executes the "cloudiot.projects.locations.registries.devices.modifyCloudToDeviceConfig" call.
@@ -4298,7 +4713,8 @@ func (c *ProjectsLocationsRegistriesDevicesModifyCloudToDeviceConfigCall) doRequ
 // all) in error.(*googleapi.Error).Header. Use googleapi.IsNotModified
 // to check whether the returned error was because
 // http.StatusNotModified was returned.
-func (c *ProjectsLocationsRegistriesDevicesModifyCloudToDeviceConfigCall) Do() (*DeviceConfig, error) {
+func (c *ProjectsLocationsRegistriesDevicesModifyCloudToDeviceConfigCall) Do(opts ...googleapi.CallOption) (*DeviceConfig, error) {
+	gensupport.SetOptions(c.urlParams_, opts...)
 	res, err := c.doRequest("json")
 	if res != nil && res.StatusCode == http.StatusNotModified {
 		if res.Body != nil {
@@ -4314,7 +4730,7 @@ func (c *ProjectsLocationsRegistriesDevicesModifyCloudToDeviceConfigCall) Do() (
 	}
 	defer res.Body.Close()
 	if err := googleapi.CheckResponse(res); err != nil {
-		return nil, gensupport.WrapError(err)
+		return nil, newError(gensupport.WrapError(err), c.name)
 	}
 	ret := &DeviceConfig{
 		ServerResponse: googleapi.ServerResponse{
@@ -4323,7 +4739,7 @@ func (c *ProjectsLocationsRegistriesDevicesModifyCloudToDeviceConfigCall) Do() (
 		},
 	}
 	target := &ret
-	if err := gensupport.DecodeResponse(target, res); err != nil {
+	if err := decodeResponse(target, res, c.urlParams_.Get("fields")); err != nil {
 		return nil, err
 	}
 	return ret, nil
@@ -4368,6 +4784,7 @@ type ProjectsLocationsRegistriesDevicesPatchCall struct {
 	urlParams_ gensupport.URLParams
 	ctx_       context.Context
 	header_    http.Header
+	retry      *RetryConfig
 }
 
 // Patch: Updates a device.
@@ -4400,6 +4817,7 @@ func (c *ProjectsLocationsRegistriesDevicesPatchCall) UpdateMask(updateMask stri
 // https://developers.google.com/gdata/docs/2.0/basics#PartialResponse
 // for more information.
 func (c *ProjectsLocationsRegistriesDevicesPatchCall) Fields(s ...googleapi.Field) *ProjectsLocationsRegistriesDevicesPatchCall {
+	c.urlParams_.Set("fields", googleapi.CombineFields(s))
 	return c
 }
 
@@ -4420,23 +4838,27 @@ func (c *ProjectsLocationsRegistriesDevicesPatchCall) Header() http.Header {
 	return c.header_
 }
 
+// Retry enables automatic retries for this call. The call is not
+// idempotent, so it is never retried unless a configuration is set here.
+func (c *ProjectsLocationsRegistriesDevicesPatchCall) Retry(retry *RetryConfig) *ProjectsLocationsRegistriesDevicesPatchCall {
+	c.retry = retry
+	return c
+}
+
 func (c *ProjectsLocationsRegistriesDevicesPatchCall) doRequest(alt string) (*http.Response, error) {
-	reqHeaders := make(http.Header)
-	for k, v := range c.header_ {
-		reqHeaders[k] = v
-	}
+	reqHeaders := c.s.requestHeaders(c.header_)
 	var body io.Reader = nil
 	body, err := googleapi.WithoutDataWrapper.JSONReader(c.device)
 	if err != nil {
 		return nil, err
 	}
 	reqHeaders.Set("Content-Type", "application/json")
-	matches, err := c.s.TemplatePaths.DevicePathTemplate.Match(c.name)
+	deviceName, err := ParseDeviceName(c.name)
 	if err != nil {
 		return nil, err
 	}
-	registry := matches["registry"]
-	location := matches["location"]
+	registry := deviceName.Registry
+	location := deviceName.Location
 	credentials, err := GetRegistryCredentials(registry, location, c.s)
 	if err != nil {
 		return nil, err
@@ -4455,7 +4877,7 @@ func (c *ProjectsLocationsRegistriesDevicesPatchCall) doRequest(alt string) (*ht
 	googleapi.Expand(req.URL, map[string]string{
 		"name": c.name,
 	})
-	return gensupport.SendRequest(c.ctx_, c.s.client, req)
+	return c.s.sendRegistryRequest(c.ctx_, req, c.retry, false, location, registry)
 }
 
 // Do executes the "cloudiot.projects.locations.registries.devices.patch" call.
@@ -4465,7 +4887,8 @@ func (c *ProjectsLocationsRegistriesDevicesPatchCall) doRequest(alt string) (*ht
 // in error.(*googleapi.Error).Header. Use googleapi.IsNotModified to
 // check whether the returned error was because http.StatusNotModified
 // was returned.
-func (c *ProjectsLocationsRegistriesDevicesPatchCall) Do() (*Device, error) {
+func (c *ProjectsLocationsRegistriesDevicesPatchCall) Do(opts ...googleapi.CallOption) (*Device, error) {
+	gensupport.SetOptions(c.urlParams_, opts...)
 	res, err := c.doRequest("json")
 	if res != nil && res.StatusCode == http.StatusNotModified {
 		if res.Body != nil {
@@ -4481,7 +4904,7 @@ func (c *ProjectsLocationsRegistriesDevicesPatchCall) Do() (*Device, error) {
 	}
 	defer res.Body.Close()
 	if err := googleapi.CheckResponse(res); err != nil {
-		return nil, gensupport.WrapError(err)
+		return nil, newError(gensupport.WrapError(err), c.name)
 	}
 	ret := &Device{
 		ServerResponse: googleapi.ServerResponse{
@@ -4490,7 +4913,7 @@ func (c *ProjectsLocationsRegistriesDevicesPatchCall) Do() (*Device, error) {
 		},
 	}
 	target := &ret
-	if err := gensupport.DecodeResponse(target, res); err != nil {
+	if err := decodeResponse(target, res, c.urlParams_.Get("fields")); err != nil {
 		return nil, err
 	}
 	return ret, nil
@@ -4541,6 +4964,7 @@ type ProjectsLocationsRegistriesDevicesSendCommandToDeviceCall struct {
 	urlParams_                 gensupport.URLParams
 	ctx_                       context.Context
 	header_                    http.Header
+	retry                      *RetryConfig
 }
 
 // SendCommandToDevice: Sends a command to the specified device. In
@@ -4575,6 +4999,7 @@ func (r *ProjectsLocationsRegistriesDevicesService) SendCommandToDevice(name str
 // https://developers.google.com/gdata/docs/2.0/basics#PartialResponse
 // for more information.
 func (c *ProjectsLocationsRegistriesDevicesSendCommandToDeviceCall) Fields(s ...googleapi.Field) *ProjectsLocationsRegistriesDevicesSendCommandToDeviceCall {
+	c.urlParams_.Set("fields", googleapi.CombineFields(s))
 	return c
 }
 
@@ -4595,23 +5020,27 @@ func (c *ProjectsLocationsRegistriesDevicesSendCommandToDeviceCall) Header() htt
 	return c.header_
 }
 
+// Retry enables automatic retries for this call. The call is not
+// idempotent, so it is never retried unless a configuration is set here.
+func (c *ProjectsLocationsRegistriesDevicesSendCommandToDeviceCall) Retry(retry *RetryConfig) *ProjectsLocationsRegistriesDevicesSendCommandToDeviceCall {
+	c.retry = retry
+	return c
+}
+
 func (c *ProjectsLocationsRegistriesDevicesSendCommandToDeviceCall) doRequest(alt string) (*http.Response, error) {
-	reqHeaders := make(http.Header)
-	for k, v := range c.header_ {
-		reqHeaders[k] = v
-	}
+	reqHeaders := c.s.requestHeaders(c.header_)
 	var body io.Reader = nil
 	body, err := googleapi.WithoutDataWrapper.JSONReader(c.sendcommandtodevicerequest)
 	if err != nil {
 		return nil, err
 	}
 	reqHeaders.Set("Content-Type", "application/json")
-	matches, err := c.s.TemplatePaths.DevicePathTemplate.Match(c.name)
+	deviceName, err := ParseDeviceName(c.name)
 	if err != nil {
 		return nil, err
 	}
-	registry := matches["registry"]
-	location := matches["location"]
+	registry := deviceName.Registry
+	location := deviceName.Location
 	credentials, err := GetRegistryCredentials(registry, location, c.s)
 	if err != nil {
 		return nil, err
@@ -4631,7 +5060,7 @@ func (c *ProjectsLocationsRegistriesDevicesSendCommandToDeviceCall) doRequest(al
 	googleapi.Expand(req.URL, map[string]string{
 		"name": c.name,
 	})
-	return gensupport.SendRequest(c.ctx_, c.s.client, req)
+	return c.s.sendRegistryRequest(c.ctx_, req, c.retry, false, location, registry)
 }
 
 // Do executes the "cloudiot.projects.locations.registries.devices.sendCommandToDevice" call.
@@ -4641,7 +5070,8 @@ func (c *ProjectsLocationsRegistriesDevicesSendCommandToDeviceCall) doRequest(al
 // was returned at all) in error.(*googleapi.Error).Header. Use
 // googleapi.IsNotModified to check whether the returned error was
 // because http.StatusNotModified was returned.
-func (c *ProjectsLocationsRegistriesDevicesSendCommandToDeviceCall) Do() (*SendCommandToDeviceResponse, error) {
+func (c *ProjectsLocationsRegistriesDevicesSendCommandToDeviceCall) Do(opts ...googleapi.CallOption) (*SendCommandToDeviceResponse, error) {
+	gensupport.SetOptions(c.urlParams_, opts...)
 	res, err := c.doRequest("json")
 	if res != nil && res.StatusCode == http.StatusNotModified {
 		if res.Body != nil {
@@ -4657,7 +5087,7 @@ func (c *ProjectsLocationsRegistriesDevicesSendCommandToDeviceCall) Do() (*SendC
 	}
 	defer res.Body.Close()
 	if err := googleapi.CheckResponse(res); err != nil {
-		return nil, gensupport.WrapError(err)
+		return nil, newError(gensupport.WrapError(err), c.name)
 	}
 	ret := &SendCommandToDeviceResponse{
 		ServerResponse: googleapi.ServerResponse{
@@ -4666,7 +5096,7 @@ func (c *ProjectsLocationsRegistriesDevicesSendCommandToDeviceCall) Do() (*SendC
 		},
 	}
 	target := &ret
-	if err := gensupport.DecodeResponse(target, res); err != nil {
+	if err := decodeResponse(target, res, c.urlParams_.Get("fields")); err != nil {
 		return nil, err
 	}
 	return ret, nil
@@ -4711,6 +5141,7 @@ type ProjectsLocationsRegistriesDevicesConfigVersionsListCall struct {
 	ifNoneMatch_ string
 	ctx_         context.Context
 	header_      http.Header
+	retry        *RetryConfig
 }
 
 // List: Lists the last few versions of the device configuration in
@@ -4741,6 +5172,7 @@ func (c *ProjectsLocationsRegistriesDevicesConfigVersionsListCall) NumVersions(n
 // https://developers.google.com/gdata/docs/2.0/basics#PartialResponse
 // for more information.
 func (c *ProjectsLocationsRegistriesDevicesConfigVersionsListCall) Fields(s ...googleapi.Field) *ProjectsLocationsRegistriesDevicesConfigVersionsListCall {
+	c.urlParams_.Set("fields", googleapi.CombineFields(s))
 	return c
 }
 
@@ -4771,21 +5203,25 @@ func (c *ProjectsLocationsRegistriesDevicesConfigVersionsListCall) Header() http
 	return c.header_
 }
 
+// Retry overrides the Service retry configuration for this call.
+// Passing a RetryConfig with MaxAttempts set to 1 disables retries.
+func (c *ProjectsLocationsRegistriesDevicesConfigVersionsListCall) Retry(retry *RetryConfig) *ProjectsLocationsRegistriesDevicesConfigVersionsListCall {
+	c.retry = retry
+	return c
+}
+
 func (c *ProjectsLocationsRegistriesDevicesConfigVersionsListCall) doRequest(alt string) (*http.Response, error) {
-	reqHeaders := make(http.Header)
-	for k, v := range c.header_ {
-		reqHeaders[k] = v
-	}
+	reqHeaders := c.s.requestHeaders(c.header_)
 	if c.ifNoneMatch_ != "" {
 		reqHeaders.Set("If-None-Match", c.ifNoneMatch_)
 	}
 	var body io.Reader = nil
-	matches, err := c.s.TemplatePaths.DevicePathTemplate.Match(c.name)
+	deviceName, err := ParseDeviceName(c.name)
 	if err != nil {
 		return nil, err
 	}
-	registry := matches["registry"]
-	location := matches["location"]
+	registry := deviceName.Registry
+	location := deviceName.Location
 	credentials, err := GetRegistryCredentials(registry, location, c.s)
 	if err != nil {
 		return nil, err
@@ -4803,7 +5239,7 @@ func (c *ProjectsLocationsRegistriesDevicesConfigVersionsListCall) doRequest(alt
 	googleapi.Expand(req.URL, map[string]string{
 		"name": c.name,
 	})
-	return gensupport.SendRequest(c.ctx_, c.s.client, req)
+	return c.s.sendRegistryRequest(c.ctx_, req, c.retry, true, location, registry)
 }
 
 // Do executes the "cloudiot.projects.locations.registries.devices.configVersions.list" call.
@@ -4813,7 +5249,8 @@ func (c *ProjectsLocationsRegistriesDevicesConfigVersionsListCall) doRequest(alt
 // a response was returned at all) in error.(*googleapi.Error).Header.
 // Use googleapi.IsNotModified to check whether the returned error was
 // because http.StatusNotModified was returned.
-func (c *ProjectsLocationsRegistriesDevicesConfigVersionsListCall) Do() (*ListDeviceConfigVersionsResponse, error) {
+func (c *ProjectsLocationsRegistriesDevicesConfigVersionsListCall) Do(opts ...googleapi.CallOption) (*ListDeviceConfigVersionsResponse, error) {
+	gensupport.SetOptions(c.urlParams_, opts...)
 	res, err := c.doRequest("json")
 	if res != nil && res.StatusCode == http.StatusNotModified {
 		if res.Body != nil {
@@ -4829,7 +5266,7 @@ func (c *ProjectsLocationsRegistriesDevicesConfigVersionsListCall) Do() (*ListDe
 	}
 	defer res.Body.Close()
 	if err := googleapi.CheckResponse(res); err != nil {
-		return nil, gensupport.WrapError(err)
+		return nil, newError(gensupport.WrapError(err), c.name)
 	}
 	ret := &ListDeviceConfigVersionsResponse{
 		ServerResponse: googleapi.ServerResponse{
@@ -4838,7 +5275,7 @@ func (c *ProjectsLocationsRegistriesDevicesConfigVersionsListCall) Do() (*ListDe
 		},
 	}
 	target := &ret
-	if err := gensupport.DecodeResponse(target, res); err != nil {
+	if err := decodeResponse(target, res, c.urlParams_.Get("fields")); err != nil {
 		return nil, err
 	}
 	return ret, nil
@@ -4886,6 +5323,7 @@ type ProjectsLocationsRegistriesDevicesStatesListCall struct {
 	ifNoneMatch_ string
 	ctx_         context.Context
 	header_      http.Header
+	retry        *RetryConfig
 }
 
 // List: Lists the last few versions of the device state in descending
@@ -4924,6 +5362,7 @@ func (c *ProjectsLocationsRegistriesDevicesStatesListCall) Base64Encode(base64En
 // https://developers.google.com/gdata/docs/2.0/basics#PartialResponse
 // for more information.
 func (c *ProjectsLocationsRegistriesDevicesStatesListCall) Fields(s ...googleapi.Field) *ProjectsLocationsRegistriesDevicesStatesListCall {
+	c.urlParams_.Set("fields", googleapi.CombineFields(s))
 	return c
 }
 
@@ -4954,21 +5393,25 @@ func (c *ProjectsLocationsRegistriesDevicesStatesListCall) Header() http.Header
 	return c.header_
 }
 
+// Retry overrides the Service retry configuration for this call.
+// Passing a RetryConfig with MaxAttempts set to 1 disables retries.
+func (c *ProjectsLocationsRegistriesDevicesStatesListCall) Retry(retry *RetryConfig) *ProjectsLocationsRegistriesDevicesStatesListCall {
+	c.retry = retry
+	return c
+}
+
 func (c *ProjectsLocationsRegistriesDevicesStatesListCall) doRequest(alt string) (*http.Response, error) {
-	reqHeaders := make(http.Header)
-	for k, v := range c.header_ {
-		reqHeaders[k] = v
-	}
+	reqHeaders := c.s.requestHeaders(c.header_)
 	if c.ifNoneMatch_ != "" {
 		reqHeaders.Set("If-None-Match", c.ifNoneMatch_)
 	}
 	var body io.Reader = nil
-	matches, err := c.s.TemplatePaths.DevicePathTemplate.Match(c.name)
+	deviceName, err := ParseDeviceName(c.name)
 	if err != nil {
 		return nil, err
 	}
-	registry := matches["registry"]
-	location := matches["location"]
+	registry := deviceName.Registry
+	location := deviceName.Location
 	credentials, err := GetRegistryCredentials(registry, location, c.s)
 	if err != nil {
 		return nil, err
@@ -4986,7 +5429,7 @@ func (c *ProjectsLocationsRegistriesDevicesStatesListCall) doRequest(alt string)
 	googleapi.Expand(req.URL, map[string]string{
 		"name": c.name,
 	})
-	return gensupport.SendRequest(c.ctx_, c.s.client, req)
+	return c.s.sendRegistryRequest(c.ctx_, req, c.retry, true, location, registry)
 }
 
 // Do executes the "cloudiot.projects.locations.registries.devices.states.list" call.
@@ -4996,7 +5439,8 @@ func (c *ProjectsLocationsRegistriesDevicesStatesListCall) doRequest(alt string)
 // returned at all) in error.(*googleapi.Error).Header. Use
 // googleapi.IsNotModified to check whether the returned error was
 // because http.StatusNotModified was returned.
-func (c *ProjectsLocationsRegistriesDevicesStatesListCall) Do() (*ListDeviceStatesResponse, error) {
+func (c *ProjectsLocationsRegistriesDevicesStatesListCall) Do(opts ...googleapi.CallOption) (*ListDeviceStatesResponse, error) {
+	gensupport.SetOptions(c.urlParams_, opts...)
 	res, err := c.doRequest("json")
 	if res != nil && res.StatusCode == http.StatusNotModified {
 		if res.Body != nil {
@@ -5012,7 +5456,7 @@ func (c *ProjectsLocationsRegistriesDevicesStatesListCall) Do() (*ListDeviceStat
 	}
 	defer res.Body.Close()
 	if err := googleapi.CheckResponse(res); err != nil {
-		return nil, gensupport.WrapError(err)
+		return nil, newError(gensupport.WrapError(err), c.name)
 	}
 	ret := &ListDeviceStatesResponse{
 		ServerResponse: googleapi.ServerResponse{
@@ -5021,7 +5465,7 @@ func (c *ProjectsLocationsRegistriesDevicesStatesListCall) Do() (*ListDeviceStat
 		},
 	}
 	target := &ret
-	if err := gensupport.DecodeResponse(target, res); err != nil {
+	if err := decodeResponse(target, res, c.urlParams_.Get("fields")); err != nil {
 		return nil, err
 	}
 	return ret, nil
@@ -5069,6 +5513,7 @@ type ProjectsLocationsRegistriesGroupsGetIamPolicyCall struct {
 	urlParams_          gensupport.URLParams
 	ctx_                context.Context
 	header_             http.Header
+	retry               *RetryConfig
 }
 
 // GetIamPolicy: Gets the access control policy for a resource. Returns
@@ -5111,8 +5556,15 @@ func (c *ProjectsLocationsRegistriesGroupsGetIamPolicyCall) Header() http.Header
 	return c.header_
 }
 
+// Retry overrides the Service retry configuration for this call.
+// Passing a RetryConfig with MaxAttempts set to 1 disables retries.
+func (c *ProjectsLocationsRegistriesGroupsGetIamPolicyCall) Retry(retry *RetryConfig) *ProjectsLocationsRegistriesGroupsGetIamPolicyCall {
+	c.retry = retry
+	return c
+}
+
 func (c *ProjectsLocationsRegistriesGroupsGetIamPolicyCall) doRequest(alt string) (*http.Response, error) {
-	return nil, errors.New("Not implemented")
+	return nil, ErrNotImplemented
 	// reqHeaders := make(http.Header)
 	// for k, v := range c.header_ {
 	// 	reqHeaders[k] = v
@@ -5143,7 +5595,8 @@ func (c *ProjectsLocationsRegistriesGroupsGetIamPolicyCall) doRequest(alt string
 // in error.(*googleapi.Error).Header. Use googleapi.IsNotModified to
 // check whether the returned error was because http.StatusNotModified
 // was returned.
-func (c *ProjectsLocationsRegistriesGroupsGetIamPolicyCall) Do() (*Policy, error) {
+func (c *ProjectsLocationsRegistriesGroupsGetIamPolicyCall) Do(opts ...googleapi.CallOption) (*Policy, error) {
+	gensupport.SetOptions(c.urlParams_, opts...)
 	res, err := c.doRequest("json")
 	if res != nil && res.StatusCode == http.StatusNotModified {
 		if res.Body != nil {
@@ -5159,7 +5612,7 @@ func (c *ProjectsLocationsRegistriesGroupsGetIamPolicyCall) Do() (*Policy, error
 	}
 	defer res.Body.Close()
 	if err := googleapi.CheckResponse(res); err != nil {
-		return nil, gensupport.WrapError(err)
+		return nil, newError(gensupport.WrapError(err), c.resource)
 	}
 	ret := &Policy{
 		ServerResponse: googleapi.ServerResponse{
@@ -5168,7 +5621,7 @@ func (c *ProjectsLocationsRegistriesGroupsGetIamPolicyCall) Do() (*Policy, error
 		},
 	}
 	target := &ret
-	if err := gensupport.DecodeResponse(target, res); err != nil {
+	if err := decodeResponse(target, res, c.urlParams_.Get("fields")); err != nil {
 		return nil, err
 	}
 	return ret, nil
@@ -5213,6 +5666,7 @@ type ProjectsLocationsRegistriesGroupsSetIamPolicyCall struct {
 	urlParams_          gensupport.URLParams
 	ctx_                context.Context
 	header_             http.Header
+	retry               *RetryConfig
 }
 
 // SetIamPolicy: Sets the access control policy on the specified
@@ -5254,8 +5708,15 @@ func (c *ProjectsLocationsRegistriesGroupsSetIamPolicyCall) Header() http.Header
 	return c.header_
 }
 
+// Retry enables automatic retries for this call. The call is not
+// idempotent, so it is never retried unless a configuration is set here.
+func (c *ProjectsLocationsRegistriesGroupsSetIamPolicyCall) Retry(retry *RetryConfig) *ProjectsLocationsRegistriesGroupsSetIamPolicyCall {
+	c.retry = retry
+	return c
+}
+
 func (c *ProjectsLocationsRegistriesGroupsSetIamPolicyCall) doRequest(alt string) (*http.Response, error) {
-	return nil, errors.New("Not implemented")
+	return nil, ErrNotImplemented
 	// reqHeaders := make(http.Header)
 	// for k, v := range c.header_ {
 	// 	reqHeaders[k] = v
@@ -5286,7 +5747,8 @@ func (c *ProjectsLocationsRegistriesGroupsSetIamPolicyCall) doRequest(alt string
 // in error.(*googleapi.Error).Header. Use googleapi.IsNotModified to
 // check whether the returned error was because http.StatusNotModified
 // was returned.
-func (c *ProjectsLocationsRegistriesGroupsSetIamPolicyCall) Do() (*Policy, error) {
+func (c *ProjectsLocationsRegistriesGroupsSetIamPolicyCall) Do(opts ...googleapi.CallOption) (*Policy, error) {
+	gensupport.SetOptions(c.urlParams_, opts...)
 	res, err := c.doRequest("json")
 	if res != nil && res.StatusCode == http.StatusNotModified {
 		if res.Body != nil {
@@ -5302,7 +5764,7 @@ func (c *ProjectsLocationsRegistriesGroupsSetIamPolicyCall) Do() (*Policy, error
 	}
 	defer res.Body.Close()
 	if err := googleapi.CheckResponse(res); err != nil {
-		return nil, gensupport.WrapError(err)
+		return nil, newError(gensupport.WrapError(err), c.resource)
 	}
 	ret := &Policy{
 		ServerResponse: googleapi.ServerResponse{
@@ -5311,7 +5773,7 @@ func (c *ProjectsLocationsRegistriesGroupsSetIamPolicyCall) Do() (*Policy, error
 		},
 	}
 	target := &ret
-	if err := gensupport.DecodeResponse(target, res); err != nil {
+	if err := decodeResponse(target, res, c.urlParams_.Get("fields")); err != nil {
 		return nil, err
 	}
 	return ret, nil
@@ -5356,6 +5818,7 @@ type ProjectsLocationsRegistriesGroupsTestIamPermissionsCall struct {
 	urlParams_                gensupport.URLParams
 	ctx_                      context.Context
 	header_                   http.Header
+	retry                     *RetryConfig
 }
 
 // TestIamPermissions: Returns permissions that a caller has on the
@@ -5398,8 +5861,15 @@ func (c *ProjectsLocationsRegistriesGroupsTestIamPermissionsCall) Header() http.
 	return c.header_
 }
 
+// Retry overrides the Service retry configuration for this call.
+// Passing a RetryConfig with MaxAttempts set to 1 disables retries.
+func (c *ProjectsLocationsRegistriesGroupsTestIamPermissionsCall) Retry(retry *RetryConfig) *ProjectsLocationsRegistriesGroupsTestIamPermissionsCall {
+	c.retry = retry
+	return c
+}
+
 func (c *ProjectsLocationsRegistriesGroupsTestIamPermissionsCall) doRequest(alt string) (*http.Response, error) {
-	return nil, errors.New("Not implemented")
+	return nil, ErrNotImplemented
 	// reqHeaders := make(http.Header)
 	// for k, v := range c.header_ {
 	// 	reqHeaders[k] = v
@@ -5430,7 +5900,8 @@ func (c *ProjectsLocationsRegistriesGroupsTestIamPermissionsCall) doRequest(alt
 // was returned at all) in error.(*googleapi.Error).Header. Use
 // googleapi.IsNotModified to check whether the returned error was
 // because http.StatusNotModified was returned.
-func (c *ProjectsLocationsRegistriesGroupsTestIamPermissionsCall) Do() (*TestIamPermissionsResponse, error) {
+func (c *ProjectsLocationsRegistriesGroupsTestIamPermissionsCall) Do(opts ...googleapi.CallOption) (*TestIamPermissionsResponse, error) {
+	gensupport.SetOptions(c.urlParams_, opts...)
 	res, err := c.doRequest("json")
 	if res != nil && res.StatusCode == http.StatusNotModified {
 		if res.Body != nil {
@@ -5446,7 +5917,7 @@ func (c *ProjectsLocationsRegistriesGroupsTestIamPermissionsCall) Do() (*TestIam
 	}
 	defer res.Body.Close()
 	if err := googleapi.CheckResponse(res); err != nil {
-		return nil, gensupport.WrapError(err)
+		return nil, newError(gensupport.WrapError(err), c.resource)
 	}
 	ret := &TestIamPermissionsResponse{
 		ServerResponse: googleapi.ServerResponse{
@@ -5455,7 +5926,7 @@ func (c *ProjectsLocationsRegistriesGroupsTestIamPermissionsCall) Do() (*TestIam
 		},
 	}
 	target := &ret
-	if err := gensupport.DecodeResponse(target, res); err != nil {
+	if err := decodeResponse(target, res, c.urlParams_.Get("fields")); err != nil {
 		return nil, err
 	}
 	return ret, nil
@@ -5500,6 +5971,7 @@ type ProjectsLocationsRegistriesGroupsDevicesListCall struct {
 	ifNoneMatch_ string
 	ctx_         context.Context
 	header_      http.Header
+	retry        *RetryConfig
 }
 
 // List: List devices in a device registry.
@@ -5635,8 +6107,15 @@ func (c *ProjectsLocationsRegistriesGroupsDevicesListCall) Header() http.Header
 	return c.header_
 }
 
+// Retry overrides the Service retry configuration for this call.
+// Passing a RetryConfig with MaxAttempts set to 1 disables retries.
+func (c *ProjectsLocationsRegistriesGroupsDevicesListCall) Retry(retry *RetryConfig) *ProjectsLocationsRegistriesGroupsDevicesListCall {
+	c.retry = retry
+	return c
+}
+
 func (c *ProjectsLocationsRegistriesGroupsDevicesListCall) doRequest(alt string) (*http.Response, error) {
-	return nil, errors.New("Not implemented")
+	return nil, ErrNotImplemented
 	// reqHeaders := make(http.Header)
 	// for k, v := range c.header_ {
 	// 	reqHeaders[k] = v
@@ -5665,7 +6144,8 @@ func (c *ProjectsLocationsRegistriesGroupsDevicesListCall) doRequest(alt string)
 // returned at all) in error.(*googleapi.Error).Header. Use
 // googleapi.IsNotModified to check whether the returned error was
 // because http.StatusNotModified was returned.
-func (c *ProjectsLocationsRegistriesGroupsDevicesListCall) Do() (*ListDevicesResponse, error) {
+func (c *ProjectsLocationsRegistriesGroupsDevicesListCall) Do(opts ...googleapi.CallOption) (*ListDevicesResponse, error) {
+	gensupport.SetOptions(c.urlParams_, opts...)
 	res, err := c.doRequest("json")
 	if res != nil && res.StatusCode == http.StatusNotModified {
 		if res.Body != nil {
@@ -5681,7 +6161,7 @@ func (c *ProjectsLocationsRegistriesGroupsDevicesListCall) Do() (*ListDevicesRes
 	}
 	defer res.Body.Close()
 	if err := googleapi.CheckResponse(res); err != nil {
-		return nil, gensupport.WrapError(err)
+		return nil, newError(gensupport.WrapError(err), c.parent)
 	}
 	ret := &ListDevicesResponse{
 		ServerResponse: googleapi.ServerResponse{
@@ -5690,7 +6170,7 @@ func (c *ProjectsLocationsRegistriesGroupsDevicesListCall) Do() (*ListDevicesRes
 		},
 	}
 	target := &ret
-	if err := gensupport.DecodeResponse(target, res); err != nil {
+	if err := decodeResponse(target, res, c.urlParams_.Get("fields")); err != nil {
 		return nil, err
 	}
 	return ret, nil
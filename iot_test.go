@@ -6,6 +6,9 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
+
+	"github.com/googleapis/gax-go/v2"
 )
 
 func TestHandleNextPageTokenAsNumber(t *testing.T) {
@@ -80,3 +83,86 @@ func TestHandleNextPageTokenAsString(t *testing.T) {
 	}
 
 }
+
+func TestIdempotentCallsRetryTransientErrors(t *testing.T) {
+	t.Setenv("CLEARBLADE_CONFIGURATION", "./test_credentials.json")
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{"deviceRegistries":[], "nextPageToken": ""}`))
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	service, err := NewService(ctx, WithRetryConfig(&RetryConfig{
+		Backoff:     &gax.Backoff{Initial: time.Millisecond},
+		MaxAttempts: 5,
+	}))
+	if err != nil {
+		t.Fatalf("Failed to initialize service: %s", err.Error())
+	}
+	service.ServiceAccountCredentials = &ServiceAccountCredentials{
+		SystemKey: "fakeSystemKey",
+		Token:     "fakeToken",
+		Url:       server.URL,
+		Project:   "testProject",
+	}
+
+	parent := fmt.Sprintf("projects/%s/locations/%s", "testProject", "us-central1")
+	if _, err := service.Projects.Locations.Registries.List(parent).Do(); err != nil {
+		t.Fatalf("Failed to list registries: %s", err.Error())
+	}
+	if attempts != 3 {
+		t.Errorf("Expected 3 attempts but got: %d", attempts)
+	}
+}
+
+func TestNonIdempotentCallsOnlyRetryWhenOptedIn(t *testing.T) {
+	t.Setenv("CLEARBLADE_CONFIGURATION", "./test_credentials.json")
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{"id":"my-registry"}`))
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	service, err := NewService(ctx)
+	if err != nil {
+		t.Fatalf("Failed to initialize service: %s", err.Error())
+	}
+	service.ServiceAccountCredentials = &ServiceAccountCredentials{
+		SystemKey: "fakeSystemKey",
+		Token:     "fakeToken",
+		Url:       server.URL,
+		Project:   "testProject",
+	}
+
+	parent := fmt.Sprintf("projects/%s/locations/%s", "testProject", "us-central1")
+	if _, err := service.Projects.Locations.Registries.Create(parent, &DeviceRegistry{Id: "my-registry"}).Do(); err == nil {
+		t.Fatalf("Expected create to fail without retries")
+	}
+	if attempts != 1 {
+		t.Errorf("Expected 1 attempt but got: %d", attempts)
+	}
+
+	attempts = 0
+	retry := &RetryConfig{Backoff: &gax.Backoff{Initial: time.Millisecond}, MaxAttempts: 3}
+	registry, err := service.Projects.Locations.Registries.Create(parent, &DeviceRegistry{Id: "my-registry"}).Retry(retry).Do()
+	if err != nil {
+		t.Fatalf("Failed to create registry: %s", err.Error())
+	}
+	if registry.Id != "my-registry" || attempts != 2 {
+		t.Errorf("Expected registry to be created after 2 attempts but got %q after %d", registry.Id, attempts)
+	}
+}
@@ -0,0 +1,426 @@
+// Copyright 2023 ClearBlade Inc.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iottest
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	iot "github.com/clearblade/go-iot"
+)
+
+const (
+	// maxConfigVersions is the number of config versions kept per device.
+	maxConfigVersions = 10
+	// maxStates is the number of device states kept per device.
+	maxStates = 10
+	// maxCredentials is the number of credentials a device may have.
+	maxCredentials = 3
+)
+
+// devicePatchFields are the update mask paths accepted by device patches.
+var devicePatchFields = map[string]bool{
+	"blocked":                         true,
+	"credentials":                     true,
+	"gatewayConfig.gatewayAuthMethod": true,
+	"logLevel":                        true,
+	"metadata":                        true,
+}
+
+type device struct {
+	d        *iot.Device
+	configs  []*iot.DeviceConfig // oldest first
+	states   []*iot.DeviceState  // oldest first
+	commands []*iot.SendCommandToDeviceRequest
+}
+
+func isGateway(d *iot.Device) bool {
+	return d.GatewayConfig != nil && d.GatewayConfig.GatewayType == "GATEWAY"
+}
+
+// lookup finds a device by its user-defined ID or its numeric ID.
+func (reg *registry) lookup(id string) *device {
+	if d := reg.devices[id]; d != nil {
+		return d
+	}
+	if numID, err := strconv.ParseUint(id, 10, 64); err == nil {
+		for _, d := range reg.devices {
+			if d.d.NumId == numID {
+				return d
+			}
+		}
+	}
+	return nil
+}
+
+// lookupName finds a device by its resource name, whose last segment may be
+// either form of device ID.
+func (reg *registry) lookupName(name string) (*device, *apiError) {
+	i := strings.LastIndex(name, "/devices/")
+	if i < 0 || name[:i] != reg.reg.Name {
+		return nil, errInvalidArgument("invalid device name %q", name)
+	}
+	d := reg.lookup(name[i+len("/devices/"):])
+	if d == nil {
+		return nil, errNotFound("device %s not found", name)
+	}
+	return d, nil
+}
+
+func (s *Server) serveDevices(reg *registry, r *http.Request) (interface{}, *apiError) {
+	q := r.URL.Query()
+	switch r.Method {
+	case http.MethodPost:
+		switch q.Get("method") {
+		case "":
+			return s.createDevice(reg, r)
+		case "modifyCloudToDeviceConfig":
+			return s.modifyConfig(reg, q.Get("name"), r)
+		case "sendCommandToDevice":
+			d, err := reg.lookupName(q.Get("name"))
+			if err != nil {
+				return nil, err
+			}
+			var req iot.SendCommandToDeviceRequest
+			if err := decodeBody(r, &req); err != nil {
+				return nil, err
+			}
+			d.commands = append(d.commands, &req)
+			return &iot.SendCommandToDeviceResponse{}, nil
+		}
+		return nil, errInvalidArgument("unknown method %q", q.Get("method"))
+	case http.MethodGet:
+		if q.Get("name") != "" {
+			d, err := reg.lookupName(q.Get("name"))
+			if err != nil {
+				return nil, err
+			}
+			return d.d, nil
+		}
+		return s.listDevices(reg, r)
+	case http.MethodPatch:
+		d, err := reg.lookupName(q.Get("name"))
+		if err != nil {
+			return nil, err
+		}
+		var patch iot.Device
+		if err := decodeBody(r, &patch); err != nil {
+			return nil, err
+		}
+		if len(patch.Credentials) > maxCredentials {
+			return nil, errInvalidArgument("a device can have at most %d credentials", maxCredentials)
+		}
+		if err := applyUpdateMask(d.d, &patch, q.Get("updateMask"), devicePatchFields); err != nil {
+			return nil, err
+		}
+		return d.d, nil
+	case http.MethodDelete:
+		d, err := reg.lookupName(q.Get("name"))
+		if err != nil {
+			return nil, err
+		}
+		if len(reg.bindings[d.d.Id]) > 0 {
+			return nil, errFailedPrecondition("gateway %s has bound devices", d.d.Id)
+		}
+		delete(reg.bindings, d.d.Id)
+		for _, bound := range reg.bindings {
+			delete(bound, d.d.Id)
+		}
+		delete(reg.devices, d.d.Id)
+		return &iot.Empty{}, nil
+	}
+	return nil, errInvalidArgument("unsupported method %s", r.Method)
+}
+
+func (s *Server) createDevice(reg *registry, r *http.Request) (interface{}, *apiError) {
+	var d iot.Device
+	if err := decodeBody(r, &d); err != nil {
+		return nil, err
+	}
+	if d.Id == "" {
+		return nil, errInvalidArgument("device id is required")
+	}
+	if reg.devices[d.Id] != nil {
+		return nil, errAlreadyExists("device %s already exists", d.Id)
+	}
+	if len(d.Credentials) > maxCredentials {
+		return nil, errInvalidArgument("a device can have at most %d credentials", maxCredentials)
+	}
+	s.nextNumID++
+	d.NumId = s.nextNumID
+	d.Name = reg.reg.Name + "/devices/" + d.Id
+	config := &iot.DeviceConfig{Version: 1, CloudUpdateTime: s.timestamp()}
+	if d.Config != nil {
+		config.BinaryData = d.Config.BinaryData
+	}
+	d.Config = config
+	d.State = nil
+	stored := &device{d: &d}
+	stored.configs = append(stored.configs, copyConfig(config))
+	reg.devices[d.Id] = stored
+	return &d, nil
+}
+
+func (s *Server) listDevices(reg *registry, r *http.Request) (interface{}, *apiError) {
+	q := r.URL.Query()
+	if parent := q.Get("parent"); parent != reg.reg.Name {
+		return nil, errInvalidArgument("invalid parent %q", parent)
+	}
+	ids := make(map[string]bool)
+	for _, id := range q["deviceIds"] {
+		ids[id] = true
+	}
+	numIDs := make(map[string]bool)
+	for _, id := range q["deviceNumIds"] {
+		numIDs[id] = true
+	}
+	var gatewayDevices, deviceGateways map[string]bool
+	if gatewayID := q.Get("gatewayListOptions.associationsGatewayId"); gatewayID != "" {
+		gatewayDevices = make(map[string]bool)
+		if gateway := reg.lookup(gatewayID); gateway != nil {
+			gatewayDevices = reg.bindings[gateway.d.Id]
+		}
+	}
+	if deviceID := q.Get("gatewayListOptions.associationsDeviceId"); deviceID != "" {
+		deviceGateways = make(map[string]bool)
+		if d := reg.lookup(deviceID); d != nil {
+			for gateway, bound := range reg.bindings {
+				if bound[d.d.Id] {
+					deviceGateways[gateway] = true
+				}
+			}
+		}
+	}
+	gatewayType := q.Get("gatewayListOptions.gatewayType")
+
+	var matched []*iot.Device
+	for _, d := range reg.devices {
+		switch {
+		case len(ids) > 0 && !ids[d.d.Id]:
+		case len(numIDs) > 0 && !numIDs[strconv.FormatUint(d.d.NumId, 10)]:
+		case gatewayDevices != nil && !gatewayDevices[d.d.Id]:
+		case deviceGateways != nil && !deviceGateways[d.d.Id]:
+		case gatewayType == "GATEWAY" && !isGateway(d.d):
+		case gatewayType == "NON_GATEWAY" && isGateway(d.d):
+		default:
+			matched = append(matched, d.d)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].Id < matched[j].Id })
+	start, end, next, err := page(len(matched), q.Get("pageSize"), q.Get("pageToken"))
+	if err != nil {
+		return nil, err
+	}
+	devices := make([]interface{}, 0, end-start)
+	for _, d := range matched[start:end] {
+		if mask := q.Get("fieldMask"); mask != "" {
+			devices = append(devices, selectFields(d, mask, "id", "name", "numId"))
+		} else {
+			devices = append(devices, d)
+		}
+	}
+	return map[string]interface{}{"devices": devices, "nextPageToken": next}, nil
+}
+
+func (s *Server) modifyConfig(reg *registry, name string, r *http.Request) (interface{}, *apiError) {
+	d, err := reg.lookupName(name)
+	if err != nil {
+		return nil, err
+	}
+	var req iot.ModifyCloudToDeviceConfigRequest
+	if err := decodeBody(r, &req); err != nil {
+		return nil, err
+	}
+	if req.VersionToUpdate != 0 && req.VersionToUpdate != d.d.Config.Version {
+		return nil, errFailedPrecondition("version %d is not the current config version %d", req.VersionToUpdate, d.d.Config.Version)
+	}
+	now := s.timestamp()
+	config := &iot.DeviceConfig{
+		BinaryData:      req.BinaryData,
+		CloudUpdateTime: now,
+		Version:         d.d.Config.Version + 1,
+	}
+	d.d.Config = config
+	d.d.LastConfigSendTime = now
+	d.configs = append(d.configs, copyConfig(config))
+	if len(d.configs) > maxConfigVersions {
+		d.configs = d.configs[len(d.configs)-maxConfigVersions:]
+	}
+	return config, nil
+}
+
+func (s *Server) listConfigVersions(reg *registry, r *http.Request) (interface{}, *apiError) {
+	d, err := reg.lookupName(r.URL.Query().Get("name"))
+	if err != nil {
+		return nil, err
+	}
+	n, err := limit(r.URL.Query().Get("numVersions"), maxConfigVersions)
+	if err != nil {
+		return nil, err
+	}
+	configs := []*iot.DeviceConfig{}
+	for i := len(d.configs) - 1; i >= 0 && len(configs) < n; i-- {
+		configs = append(configs, d.configs[i])
+	}
+	return &iot.ListDeviceConfigVersionsResponse{DeviceConfigs: configs}, nil
+}
+
+func (s *Server) listStates(reg *registry, r *http.Request) (interface{}, *apiError) {
+	d, err := reg.lookupName(r.URL.Query().Get("name"))
+	if err != nil {
+		return nil, err
+	}
+	n, err := limit(r.URL.Query().Get("numStates"), maxStates)
+	if err != nil {
+		return nil, err
+	}
+	states := []*iot.DeviceState{}
+	for i := len(d.states) - 1; i >= 0 && len(states) < n; i-- {
+		states = append(states, d.states[i])
+	}
+	return &iot.ListDeviceStatesResponse{DeviceStates: states}, nil
+}
+
+// limit parses a numVersions or numStates parameter, where zero or missing
+// means max.
+func limit(value string, max int) (int, *apiError) {
+	if value == "" {
+		return max, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, errInvalidArgument("invalid limit %q", value)
+	}
+	if n == 0 || n > max {
+		return max, nil
+	}
+	return n, nil
+}
+
+func copyConfig(c *iot.DeviceConfig) *iot.DeviceConfig {
+	return &iot.DeviceConfig{
+		BinaryData:      c.BinaryData,
+		CloudUpdateTime: c.CloudUpdateTime,
+		DeviceAckTime:   c.DeviceAckTime,
+		Version:         c.Version,
+	}
+}
+
+// device finds a device by resource name for the test helpers.
+func (s *Server) device(name string) (*device, error) {
+	i := strings.LastIndex(name, "/devices/")
+	if i < 0 {
+		return nil, fmt.Errorf("iottest: invalid device name %q", name)
+	}
+	reg := s.registries[name[:i]]
+	if reg == nil {
+		return nil, fmt.Errorf("iottest: registry %s not found", name[:i])
+	}
+	d, err := reg.lookupName(name)
+	if err != nil {
+		return nil, fmt.Errorf("iottest: %s", err.Message)
+	}
+	return d, nil
+}
+
+// AckConfig records that the device acknowledged the given config version,
+// as a device does over MQTT.
+func (s *Server) AckConfig(deviceName string, version int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, err := s.device(deviceName)
+	if err != nil {
+		return err
+	}
+	now := s.timestamp()
+	found := false
+	for _, c := range d.configs {
+		if c.Version == version {
+			c.DeviceAckTime = now
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("iottest: device %s has no config version %d", deviceName, version)
+	}
+	if d.d.Config.Version == version {
+		d.d.Config.DeviceAckTime = now
+	}
+	d.d.LastConfigAckTime = now
+	return nil
+}
+
+// ReportState records a state reported by the device. data is the base64
+// encoded state payload.
+func (s *Server) ReportState(deviceName string, data string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, err := s.device(deviceName)
+	if err != nil {
+		return err
+	}
+	state := &iot.DeviceState{BinaryData: data, UpdateTime: s.timestamp()}
+	d.d.State = state
+	d.d.LastStateTime = state.UpdateTime
+	d.states = append(d.states, state)
+	if len(d.states) > maxStates {
+		d.states = d.states[len(d.states)-maxStates:]
+	}
+	return nil
+}
+
+// Commands returns the commands sent to the device, oldest first.
+func (s *Server) Commands(deviceName string) ([]*iot.SendCommandToDeviceRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, err := s.device(deviceName)
+	if err != nil {
+		return nil, err
+	}
+	return append([]*iot.SendCommandToDeviceRequest(nil), d.commands...), nil
+}
+
+// Heartbeat records an MQTT heartbeat from the device, setting its
+// LastHeartbeatTime.
+func (s *Server) Heartbeat(deviceName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, err := s.device(deviceName)
+	if err != nil {
+		return err
+	}
+	d.d.LastHeartbeatTime = s.timestamp()
+	return nil
+}
+
+// PublishEvent records a telemetry event from the device, setting its
+// LastEventTime.
+func (s *Server) PublishEvent(deviceName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, err := s.device(deviceName)
+	if err != nil {
+		return err
+	}
+	d.d.LastEventTime = s.timestamp()
+	return nil
+}
+
+// ReportError records an error of the device, such as a malformed config,
+// setting its LastErrorStatus and LastErrorTime.
+func (s *Server) ReportError(deviceName string, status *iot.Status) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, err := s.device(deviceName)
+	if err != nil {
+		return err
+	}
+	d.d.LastErrorStatus = status
+	d.d.LastErrorTime = s.timestamp()
+	return nil
+}
@@ -0,0 +1,116 @@
+// Copyright 2023 ClearBlade Inc.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iottest
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// camelCase converts a snake_case mask path such as "gateway_config.gateway_auth_method"
+// to the JSON field names used on the wire. camelCase paths are unchanged.
+func camelCase(path string) string {
+	var b strings.Builder
+	upper := false
+	for _, r := range path {
+		switch {
+		case r == '_':
+			upper = true
+		case upper:
+			b.WriteString(strings.ToUpper(string(r)))
+			upper = false
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func toMap(v interface{}) map[string]interface{} {
+	b, _ := json.Marshal(v)
+	m := make(map[string]interface{})
+	_ = json.Unmarshal(b, &m)
+	return m
+}
+
+// fromMap replaces the value v points to with the resource described by m.
+func fromMap(m map[string]interface{}, v interface{}) error {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	rv := reflect.ValueOf(v).Elem()
+	rv.Set(reflect.Zero(rv.Type()))
+	return json.Unmarshal(b, v)
+}
+
+// applyUpdateMask copies the fields named in mask from src to dst, which
+// must be pointers to the same resource type.
+func applyUpdateMask(dst, src interface{}, mask string, allowed map[string]bool) *apiError {
+	if strings.TrimSpace(mask) == "" {
+		return errInvalidArgument("updateMask is required")
+	}
+	dm, sm := toMap(dst), toMap(src)
+	for _, path := range strings.Split(mask, ",") {
+		path = camelCase(strings.TrimSpace(path))
+		if !allowed[path] {
+			return errInvalidArgument("field %q cannot be updated", path)
+		}
+		setPath(dm, strings.Split(path, "."), getPath(sm, strings.Split(path, ".")))
+	}
+	if err := fromMap(dm, dst); err != nil {
+		return errInvalidArgument("invalid update: %v", err)
+	}
+	return nil
+}
+
+// selectFields returns the JSON form of v reduced to the top-level fields in
+// the comma-separated mask plus the always-present fields.
+func selectFields(v interface{}, mask string, always ...string) map[string]interface{} {
+	m := toMap(v)
+	keep := make(map[string]bool)
+	for _, f := range always {
+		keep[f] = true
+	}
+	for _, path := range strings.Split(mask, ",") {
+		keep[strings.SplitN(camelCase(strings.TrimSpace(path)), ".", 2)[0]] = true
+	}
+	for k := range m {
+		if !keep[k] {
+			delete(m, k)
+		}
+	}
+	return m
+}
+
+func getPath(m map[string]interface{}, path []string) interface{} {
+	v, ok := m[path[0]]
+	if !ok || len(path) == 1 {
+		return v
+	}
+	child, ok := v.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	return getPath(child, path[1:])
+}
+
+func setPath(m map[string]interface{}, path []string, v interface{}) {
+	if len(path) == 1 {
+		if v == nil {
+			delete(m, path[0])
+		} else {
+			m[path[0]] = v
+		}
+		return
+	}
+	child, ok := m[path[0]].(map[string]interface{})
+	if !ok {
+		child = make(map[string]interface{})
+		m[path[0]] = child
+	}
+	setPath(child, path[1:], v)
+}
@@ -0,0 +1,275 @@
+// Copyright 2023 ClearBlade Inc.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iottest
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	iot "github.com/clearblade/go-iot"
+)
+
+// registryPatchFields are the update mask paths accepted by registry patches.
+var registryPatchFields = map[string]bool{
+	"credentials":                             true,
+	"eventNotificationConfigs":                true,
+	"httpConfig":                              true,
+	"httpConfig.httpEnabledState":             true,
+	"logLevel":                                true,
+	"mqttConfig":                              true,
+	"mqttConfig.mqttEnabledState":             true,
+	"stateNotificationConfig":                 true,
+	"stateNotificationConfig.pubsubTopicName": true,
+}
+
+type registry struct {
+	systemKey string
+	token     string
+	reg       *iot.DeviceRegistry
+	devices   map[string]*device         // keyed by device ID
+	bindings  map[string]map[string]bool // gateway ID to bound device IDs
+	policies  map[string]*iot.Policy     // keyed by resource name
+}
+
+func registryName(project, location, id string) string {
+	return fmt.Sprintf("projects/%s/locations/%s/registries/%s", project, location, id)
+}
+
+// parseParent splits a "projects/{p}/locations/{l}" name.
+func parseParent(parent string) (project, location string, ok bool) {
+	parts := strings.Split(parent, "/")
+	if len(parts) != 4 || parts[0] != "projects" || parts[2] != "locations" || parts[1] == "" || parts[3] == "" {
+		return "", "", false
+	}
+	return parts[1], parts[3], true
+}
+
+func (s *Server) serveAdmin(r *http.Request) (interface{}, *apiError) {
+	q := r.URL.Query()
+	switch r.Method {
+	case http.MethodPost:
+		return s.createRegistry(q.Get("parent"), r)
+	case http.MethodGet:
+		return s.listRegistries(q.Get("parent"), q.Get("pageSize"), q.Get("pageToken"))
+	case http.MethodDelete:
+		return s.deleteRegistry(q.Get("name"))
+	}
+	return nil, errInvalidArgument("unsupported method %s", r.Method)
+}
+
+func (s *Server) createRegistry(parent string, r *http.Request) (interface{}, *apiError) {
+	project, location, ok := parseParent(parent)
+	if !ok {
+		return nil, errInvalidArgument("invalid parent %q", parent)
+	}
+	var reg iot.DeviceRegistry
+	if err := decodeBody(r, &reg); err != nil {
+		return nil, err
+	}
+	if reg.Id == "" {
+		return nil, errInvalidArgument("registry id is required")
+	}
+	name := registryName(project, location, reg.Id)
+	if s.registries[name] != nil {
+		return nil, errAlreadyExists("registry %s already exists", name)
+	}
+	reg.Name = name
+	if reg.MqttConfig == nil {
+		reg.MqttConfig = &iot.MqttConfig{MqttEnabledState: "MQTT_ENABLED"}
+	}
+	if reg.HttpConfig == nil {
+		reg.HttpConfig = &iot.HttpConfig{HttpEnabledState: "HTTP_ENABLED"}
+	}
+	s.nextRegistryKey++
+	stored := &registry{
+		systemKey: fmt.Sprintf("iottest-registry-%d", s.nextRegistryKey),
+		token:     fmt.Sprintf("iottest-registry-token-%d", s.nextRegistryKey),
+		reg:       &reg,
+		devices:   make(map[string]*device),
+		bindings:  make(map[string]map[string]bool),
+		policies:  make(map[string]*iot.Policy),
+	}
+	s.registries[name] = stored
+	s.registriesByKey[stored.systemKey] = stored
+	return &reg, nil
+}
+
+func (s *Server) listRegistries(parent, pageSize, pageToken string) (interface{}, *apiError) {
+	project, location, ok := parseParent(parent)
+	if !ok {
+		return nil, errInvalidArgument("invalid parent %q", parent)
+	}
+	var names []string
+	prefix := fmt.Sprintf("projects/%s/locations/%s/registries/", project, location)
+	for name := range s.registries {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	start, end, next, err := page(len(names), pageSize, pageToken)
+	if err != nil {
+		return nil, err
+	}
+	resp := &struct {
+		DeviceRegistries []*iot.DeviceRegistry `json:"deviceRegistries"`
+		// NextPageToken is a number, or "" on the last page, like the
+		// ClearBlade webhook.
+		NextPageToken interface{} `json:"nextPageToken"`
+	}{DeviceRegistries: []*iot.DeviceRegistry{}, NextPageToken: ""}
+	for _, name := range names[start:end] {
+		resp.DeviceRegistries = append(resp.DeviceRegistries, s.registries[name].reg)
+	}
+	if next != "" {
+		resp.NextPageToken, _ = strconv.Atoi(next)
+	}
+	return resp, nil
+}
+
+func (s *Server) deleteRegistry(name string) (interface{}, *apiError) {
+	reg := s.registries[name]
+	if reg == nil {
+		return nil, errNotFound("registry %s not found", name)
+	}
+	if len(reg.devices) > 0 {
+		return nil, errFailedPrecondition("registry %s is not empty", name)
+	}
+	delete(s.registries, name)
+	delete(s.registriesByKey, reg.systemKey)
+	return &iot.Empty{}, nil
+}
+
+// RotateRegistryToken replaces the service account token of a registry, so
+// that requests made with previously issued credentials are rejected.
+func (s *Server) RotateRegistryToken(registryName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	reg := s.registries[registryName]
+	if reg == nil {
+		return fmt.Errorf("iottest: registry %s not found", registryName)
+	}
+	s.nextRegistryKey++
+	reg.token = fmt.Sprintf("iottest-registry-token-%d", s.nextRegistryKey)
+	return nil
+}
+
+func (s *Server) serveRegistry(reg *registry, r *http.Request) (interface{}, *apiError) {
+	q := r.URL.Query()
+	switch r.Method {
+	case http.MethodGet:
+		return reg.reg, nil
+	case http.MethodPatch:
+		var patch iot.DeviceRegistry
+		if err := decodeBody(r, &patch); err != nil {
+			return nil, err
+		}
+		if err := applyUpdateMask(reg.reg, &patch, q.Get("updateMask"), registryPatchFields); err != nil {
+			return nil, err
+		}
+		return reg.reg, nil
+	case http.MethodPost:
+		switch q.Get("method") {
+		case "bindDeviceToGateway":
+			var req iot.BindDeviceToGatewayRequest
+			if err := decodeBody(r, &req); err != nil {
+				return nil, err
+			}
+			return &iot.BindDeviceToGatewayResponse{}, reg.bind(req.GatewayId, req.DeviceId)
+		case "unbindDeviceFromGateway":
+			var req iot.UnbindDeviceFromGatewayRequest
+			if err := decodeBody(r, &req); err != nil {
+				return nil, err
+			}
+			return &iot.UnbindDeviceFromGatewayResponse{}, reg.unbind(req.GatewayId, req.DeviceId)
+		case "getIamPolicy":
+			if policy := reg.policies[q.Get("resource")]; policy != nil {
+				return policy, nil
+			}
+			return &iot.Policy{}, nil
+		case "setIamPolicy":
+			var req iot.SetIamPolicyRequest
+			if err := decodeBody(r, &req); err != nil {
+				return nil, err
+			}
+			if req.Policy == nil {
+				return nil, errInvalidArgument("policy is required")
+			}
+			policy := *req.Policy
+			policy.Etag = fmt.Sprintf("BwX%d", len(reg.policies)+1)
+			reg.policies[q.Get("resource")] = &policy
+			return &policy, nil
+		case "testIamPermissions":
+			var req iot.TestIamPermissionsRequest
+			if err := decodeBody(r, &req); err != nil {
+				return nil, err
+			}
+			return &iot.TestIamPermissionsResponse{Permissions: req.Permissions}, nil
+		}
+		return nil, errInvalidArgument("unknown method %q", q.Get("method"))
+	}
+	return nil, errInvalidArgument("unsupported method %s", r.Method)
+}
+
+func (reg *registry) bind(gatewayID, deviceID string) *apiError {
+	gateway, d := reg.lookup(gatewayID), reg.lookup(deviceID)
+	if gateway == nil {
+		return errNotFound("gateway %s not found", gatewayID)
+	}
+	if d == nil {
+		return errNotFound("device %s not found", deviceID)
+	}
+	if !isGateway(gateway.d) {
+		return errInvalidArgument("device %s is not a gateway", gateway.d.Id)
+	}
+	if isGateway(d.d) {
+		return errInvalidArgument("gateway %s cannot be bound to another gateway", d.d.Id)
+	}
+	if reg.bindings[gateway.d.Id] == nil {
+		reg.bindings[gateway.d.Id] = make(map[string]bool)
+	}
+	reg.bindings[gateway.d.Id][d.d.Id] = true
+	return nil
+}
+
+func (reg *registry) unbind(gatewayID, deviceID string) *apiError {
+	gateway, d := reg.lookup(gatewayID), reg.lookup(deviceID)
+	if gateway == nil {
+		return errNotFound("gateway %s not found", gatewayID)
+	}
+	if d == nil {
+		return errNotFound("device %s not found", deviceID)
+	}
+	if !reg.bindings[gateway.d.Id][d.d.Id] {
+		return errNotFound("device %s is not bound to gateway %s", d.d.Id, gateway.d.Id)
+	}
+	delete(reg.bindings[gateway.d.Id], d.d.Id)
+	return nil
+}
+
+// page returns the bounds of the requested page of n items and the token of
+// the next page, or "" if it is the last one.
+func page(n int, pageSize, pageToken string) (start, end int, next string, err *apiError) {
+	if pageToken != "" {
+		var convErr error
+		if start, convErr = strconv.Atoi(pageToken); convErr != nil || start < 0 || start > n {
+			return 0, 0, "", errInvalidArgument("invalid page token %q", pageToken)
+		}
+	}
+	end = n
+	if pageSize != "" {
+		size, convErr := strconv.Atoi(pageSize)
+		if convErr != nil || size < 0 {
+			return 0, 0, "", errInvalidArgument("invalid page size %q", pageSize)
+		}
+		if size > 0 && start+size < n {
+			end = start + size
+			next = strconv.Itoa(end)
+		}
+	}
+	return start, end, next, nil
+}
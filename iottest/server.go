@@ -0,0 +1,253 @@
+// Copyright 2023 ClearBlade Inc.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package iottest provides an in-process fake of the ClearBlade IoT Core
+// webhook API, for use in tests of code built on the iot package.
+//
+// The fake keeps registries, devices, config version history, device states,
+// gateway bindings and IAM policies in memory:
+//
+//	fake := iottest.NewServer("my-project")
+//	defer fake.Close()
+//
+//	service, err := iot.NewService(ctx, iot.WithServiceAccountCredentials(fake.Credentials()))
+//
+// In a test, NewService does both and closes the fake when the test ends.
+package iottest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	iot "github.com/clearblade/go-iot"
+)
+
+const (
+	adminSystemKey = "iottest-admin-system-key"
+	adminToken     = "iottest-admin-token"
+)
+
+// Server is a fake ClearBlade IoT Core server. The zero value is not usable;
+// create one with NewServer.
+type Server struct {
+	// URL is the base URL of the fake, of the form http://ipaddr:port.
+	URL string
+
+	// Project is the project that the service account credentials belong to.
+	Project string
+
+	srv *httptest.Server
+
+	mu                   sync.Mutex
+	now                  func() time.Time
+	registries           map[string]*registry // keyed by resource name
+	registriesByKey      map[string]*registry // keyed by system key
+	nextNumID            uint64
+	nextRegistryKey      int
+	credentialsRequested int
+	failures             []int
+}
+
+// NewServer starts a fake server for the given project. The caller should
+// call Close when finished, to shut it down.
+func NewServer(project string) *Server {
+	s := &Server{
+		Project:         project,
+		now:             time.Now,
+		registries:      make(map[string]*registry),
+		registriesByKey: make(map[string]*registry),
+		nextNumID:       2820000000000000,
+	}
+	s.srv = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	s.URL = s.srv.URL
+	return s
+}
+
+// Close shuts down the server and blocks until all outstanding requests on
+// it have completed.
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// Credentials returns service account credentials for the fake in the JSON
+// form accepted by iot.WithServiceAccountCredentials.
+func (s *Server) Credentials() string {
+	b, _ := json.Marshal(&iot.ServiceAccountCredentials{
+		SystemKey: adminSystemKey,
+		Token:     adminToken,
+		Url:       s.URL,
+		Project:   s.Project,
+	})
+	return string(b)
+}
+
+// SetClock replaces the function used to timestamp configs, states and
+// device activity. It defaults to time.Now.
+func (s *Server) SetClock(now func() time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = now
+}
+
+// FailNext makes the next n webhook requests fail with the given HTTP status
+// code, without touching any state.
+func (s *Server) FailNext(status int, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i < n; i++ {
+		s.failures = append(s.failures, status)
+	}
+}
+
+// RegistryCredentialsRequests reports how many getRegistryCredentials calls
+// the server has received.
+func (s *Server) RegistryCredentialsRequests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.credentialsRequested
+}
+
+func (s *Server) timestamp() string {
+	return s.now().UTC().Format(time.RFC3339Nano)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 6 && parts[0] == "api" && parts[1] == "v" && parts[2] == "1" && parts[3] == "code" && parts[5] == "getRegistryCredentials":
+		s.credentialsRequested++
+		if parts[4] != adminSystemKey || r.Header.Get("ClearBlade-UserToken") != adminToken {
+			writeError(w, errUnauthenticated())
+			return
+		}
+		s.getRegistryCredentials(w, r)
+	case len(parts) == 7 && parts[0] == "api" && parts[1] == "v" && parts[2] == "4" && parts[3] == "webhook" && parts[4] == "execute":
+		if len(s.failures) > 0 {
+			status := s.failures[0]
+			s.failures = s.failures[1:]
+			writeError(w, &apiError{Code: status, Message: "injected failure", Status: http.StatusText(status)})
+			return
+		}
+		s.serveWebhook(w, r, parts[5], parts[6])
+	default:
+		writeError(w, errNotFound("unknown path %s", r.URL.Path))
+	}
+}
+
+func (s *Server) serveWebhook(w http.ResponseWriter, r *http.Request, systemKey, endpoint string) {
+	token := r.Header.Get("ClearBlade-UserToken")
+	var (
+		resp interface{}
+		err  *apiError
+	)
+	if systemKey == adminSystemKey {
+		if token != adminToken {
+			writeError(w, errUnauthenticated())
+			return
+		}
+		if endpoint != "cloudiot" {
+			writeError(w, errNotFound("unknown webhook %s", endpoint))
+			return
+		}
+		resp, err = s.serveAdmin(r)
+	} else {
+		reg := s.registriesByKey[systemKey]
+		if reg == nil || token != reg.token {
+			writeError(w, errUnauthenticated())
+			return
+		}
+		switch endpoint {
+		case "cloudiot":
+			resp, err = s.serveRegistry(reg, r)
+		case "cloudiot_devices":
+			resp, err = s.serveDevices(reg, r)
+		case "cloudiot_devices_configVersions":
+			resp, err = s.listConfigVersions(reg, r)
+		case "cloudiot_devices_states":
+			resp, err = s.listStates(reg, r)
+		default:
+			err = errNotFound("unknown webhook %s", endpoint)
+		}
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) getRegistryCredentials(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Region   string `json:"region"`
+		Registry string `json:"registry"`
+		Project  string `json:"project"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, errInvalidArgument("invalid request body: %v", err))
+		return
+	}
+	reg := s.registries[registryName(body.Project, body.Region, body.Registry)]
+	if reg == nil {
+		writeError(w, errNotFound("registry %s not found in %s", body.Registry, body.Region))
+		return
+	}
+	writeJSON(w, http.StatusOK, &iot.RegistryUserCredentials{
+		SystemKey: reg.systemKey,
+		Token:     reg.token,
+		Url:       s.URL,
+	})
+}
+
+// apiError is the error body returned by the webhooks, in the format parsed
+// by googleapi.CheckResponse.
+type apiError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Status  string `json:"status"`
+}
+
+func errNotFound(format string, args ...interface{}) *apiError {
+	return &apiError{Code: http.StatusNotFound, Message: fmt.Sprintf(format, args...), Status: "NOT_FOUND"}
+}
+
+func errAlreadyExists(format string, args ...interface{}) *apiError {
+	return &apiError{Code: http.StatusConflict, Message: fmt.Sprintf(format, args...), Status: "ALREADY_EXISTS"}
+}
+
+func errInvalidArgument(format string, args ...interface{}) *apiError {
+	return &apiError{Code: http.StatusBadRequest, Message: fmt.Sprintf(format, args...), Status: "INVALID_ARGUMENT"}
+}
+
+func errFailedPrecondition(format string, args ...interface{}) *apiError {
+	return &apiError{Code: http.StatusBadRequest, Message: fmt.Sprintf(format, args...), Status: "FAILED_PRECONDITION"}
+}
+
+func errUnauthenticated() *apiError {
+	return &apiError{Code: http.StatusUnauthorized, Message: "invalid token", Status: "UNAUTHENTICATED"}
+}
+
+func writeError(w http.ResponseWriter, err *apiError) {
+	writeJSON(w, err.Code, map[string]*apiError{"error": err})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func decodeBody(r *http.Request, v interface{}) *apiError {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return errInvalidArgument("invalid request body: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package iottest
+
+import (
+	"context"
+	"testing"
+
+	iot "github.com/clearblade/go-iot"
+)
+
+func TestRegistriesAndDevices(t *testing.T) {
+	fake, service := NewService(t, "test-project")
+	parent := "projects/test-project/locations/us-central1"
+
+	for _, id := range []string{"registry-a", "registry-b", "registry-c"} {
+		if _, err := service.Projects.Locations.Registries.Create(parent, &iot.DeviceRegistry{Id: id}).Do(); err != nil {
+			t.Fatalf("Failed to create registry %s: %s", id, err.Error())
+		}
+	}
+	if _, err := service.Projects.Locations.Registries.Create(parent, &iot.DeviceRegistry{Id: "registry-a"}).Do(); err == nil {
+		t.Errorf("Expected duplicate registry create to fail")
+	}
+
+	var ids []string
+	err := service.Projects.Locations.Registries.List(parent).PageSize(2).Pages(context.Background(), func(resp *iot.ListDeviceRegistriesResponse) error {
+		for _, r := range resp.DeviceRegistries {
+			ids = append(ids, r.Id)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to list registries: %s", err.Error())
+	}
+	if len(ids) != 3 || ids[0] != "registry-a" || ids[2] != "registry-c" {
+		t.Errorf("Expected registries a, b and c but got: %v", ids)
+	}
+
+	registry := parent + "/registries/registry-a"
+	got, err := service.Projects.Locations.Registries.Get(registry).Do()
+	if err != nil {
+		t.Fatalf("Failed to get registry: %s", err.Error())
+	}
+	if got.Name != registry {
+		t.Errorf("Expected registry name %s but got: %s", registry, got.Name)
+	}
+
+	devices := service.Projects.Locations.Registries.Devices
+	if _, err := devices.Create(registry, &iot.Device{Id: "gateway", GatewayConfig: &iot.GatewayConfig{GatewayType: "GATEWAY"}}).Do(); err != nil {
+		t.Fatalf("Failed to create gateway: %s", err.Error())
+	}
+	device, err := devices.Create(registry, &iot.Device{Id: "sensor", Metadata: map[string]string{"site": "a"}}).Do()
+	if err != nil {
+		t.Fatalf("Failed to create device: %s", err.Error())
+	}
+	if device.NumId == 0 || device.Config == nil || device.Config.Version != 1 {
+		t.Errorf("Expected a numeric ID and config version 1 but got: %+v", device)
+	}
+
+	name := registry + "/devices/sensor"
+	patched, err := devices.Patch(name, &iot.Device{Blocked: true, Metadata: map[string]string{"site": "b"}}).UpdateMask("blocked,metadata").Do()
+	if err != nil {
+		t.Fatalf("Failed to patch device: %s", err.Error())
+	}
+	if !patched.Blocked || patched.Metadata["site"] != "b" {
+		t.Errorf("Expected patched device to be blocked at site b but got: %+v", patched)
+	}
+
+	if _, err := service.Projects.Locations.Registries.BindDeviceToGateway(registry, &iot.BindDeviceToGatewayRequest{GatewayId: "gateway", DeviceId: "sensor"}).Do(); err != nil {
+		t.Fatalf("Failed to bind device: %s", err.Error())
+	}
+	bound, err := devices.List(registry).GatewayListOptionsAssociationsGatewayId("gateway").Do()
+	if err != nil {
+		t.Fatalf("Failed to list bound devices: %s", err.Error())
+	}
+	if len(bound.Devices) != 1 || bound.Devices[0].Id != "sensor" {
+		t.Errorf("Expected sensor to be bound to gateway but got: %v", bound.Devices)
+	}
+
+	for _, data := range []string{"djI=", "djM="} {
+		if _, err := devices.ModifyCloudToDeviceConfig(name, &iot.ModifyCloudToDeviceConfigRequest{BinaryData: data}).Do(); err != nil {
+			t.Fatalf("Failed to modify config: %s", err.Error())
+		}
+	}
+	if err := fake.AckConfig(name, 3); err != nil {
+		t.Fatalf("Failed to ack config: %s", err.Error())
+	}
+	versions, err := devices.ConfigVersions.List(name).NumVersions(2).Do()
+	if err != nil {
+		t.Fatalf("Failed to list config versions: %s", err.Error())
+	}
+	if len(versions.DeviceConfigs) != 2 || versions.DeviceConfigs[0].Version != 3 || versions.DeviceConfigs[0].DeviceAckTime == "" {
+		t.Errorf("Expected acknowledged version 3 first but got: %+v", versions.DeviceConfigs)
+	}
+
+	if err := fake.ReportState(name, "b2s="); err != nil {
+		t.Fatalf("Failed to report state: %s", err.Error())
+	}
+	states, err := devices.States.List(name).Do()
+	if err != nil {
+		t.Fatalf("Failed to list states: %s", err.Error())
+	}
+	if len(states.DeviceStates) != 1 || states.DeviceStates[0].BinaryData != "b2s=" {
+		t.Errorf("Expected one reported state but got: %+v", states.DeviceStates)
+	}
+
+	if _, err := devices.Delete(registry + "/devices/gateway").Do(); err == nil {
+		t.Errorf("Expected deleting a gateway with bound devices to fail")
+	}
+	if _, err := devices.Delete(name).Do(); err != nil {
+		t.Fatalf("Failed to delete device: %s", err.Error())
+	}
+	if _, err := devices.Get(name).Do(); err == nil {
+		t.Errorf("Expected deleted device to be missing")
+	}
+}
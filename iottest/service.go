@@ -0,0 +1,37 @@
+// Copyright 2023 ClearBlade Inc.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iottest
+
+import (
+	"context"
+	"testing"
+
+	iot "github.com/clearblade/go-iot"
+)
+
+// NewService starts a Server for project, which is closed when the test
+// ends, and returns it with a Service using its credentials. opts are passed
+// to iot.NewService after the credentials. The test fails if the Service
+// cannot be created.
+func NewService(tb testing.TB, project string, opts ...iot.ServiceOption) (*Server, *iot.Service) {
+	tb.Helper()
+	s := NewServer(project)
+	tb.Cleanup(s.Close)
+	opts = append([]iot.ServiceOption{iot.WithServiceAccountCredentials(s.Credentials())}, opts...)
+	service, err := iot.NewService(context.Background(), opts...)
+	if err != nil {
+		tb.Fatalf("Failed to initialize service: %s", err.Error())
+	}
+	return s, service
+}
+
+// CreateRegistry creates an empty registry for a test. The test fails if it
+// cannot be created.
+func CreateRegistry(tb testing.TB, service *iot.Service, name iot.RegistryName) {
+	tb.Helper()
+	if _, err := service.Projects.Locations.Registries.CreateIn(name.Parent(), &iot.DeviceRegistry{Id: name.Registry}).Do(); err != nil {
+		tb.Fatalf("Failed to create registry: %s", err.Error())
+	}
+}
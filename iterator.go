@@ -0,0 +1,86 @@
+// Copyright 2023 ClearBlade Inc.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iot
+
+import (
+	"iter"
+
+	"github.com/clearblade/go-iot/cblib/gensupport"
+	"google.golang.org/api/iterator"
+)
+
+// Iterator is a pull-style iterator over the results of a paginated list
+// call. Pages are fetched as they are needed.
+//
+// To resume a listing, set PageInfo().Token to a page token saved from an
+// earlier iteration before the first call to Next. An Iterator can also be
+// used with iterator.NewPager to read one page at a time.
+type Iterator[T any] struct {
+	items    []T
+	pageInfo *iterator.PageInfo
+	nextFunc func() error
+}
+
+// newIterator returns an Iterator whose pages are read with fetch.
+func newIterator[T any](fetch func(pageSize int, pageToken string) (items []T, nextPageToken string, err error)) *Iterator[T] {
+	it := &Iterator[T]{}
+	it.pageInfo, it.nextFunc = iterator.NewPageInfo(
+		func(pageSize int, pageToken string) (string, error) {
+			items, next, err := fetch(pageSize, pageToken)
+			if err != nil {
+				return "", err
+			}
+			it.items = append(it.items, items...)
+			return next, nil
+		},
+		func() int { return len(it.items) },
+		func() interface{} { b := it.items; it.items = nil; return b })
+	return it
+}
+
+// PageInfo supports pagination. See the google.golang.org/api/iterator
+// package for details.
+func (it *Iterator[T]) PageInfo() *iterator.PageInfo {
+	return it.pageInfo
+}
+
+// Next returns the next result. Its second return value is iterator.Done if
+// there are no more results. Once Next returns iterator.Done, all subsequent
+// calls will return iterator.Done.
+func (it *Iterator[T]) Next() (T, error) {
+	var item T
+	if err := it.nextFunc(); err != nil {
+		return item, err
+	}
+	item = it.items[0]
+	it.items = it.items[1:]
+	return item, nil
+}
+
+// All returns a sequence of the remaining results. Iteration stops after the
+// first error, which is yielded with the zero value of T.
+func (it *Iterator[T]) All() iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for {
+			item, err := it.Next()
+			if err == iterator.Done {
+				return
+			}
+			if !yield(item, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+// cloneURLParams returns a copy of params, so that an iterator can page
+// through results without changing the call it was created from.
+func cloneURLParams(params gensupport.URLParams) gensupport.URLParams {
+	clone := make(gensupport.URLParams, len(params))
+	for k, v := range params {
+		clone[k] = append([]string(nil), v...)
+	}
+	return clone
+}
@@ -0,0 +1,63 @@
+package iot_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	iot "github.com/clearblade/go-iot"
+	"github.com/clearblade/go-iot/iottest"
+	"google.golang.org/api/iterator"
+)
+
+func TestListIterators(t *testing.T) {
+	_, service := iottest.NewService(t, "test-project")
+	ctx := context.Background()
+	parent := "projects/test-project/locations/us-central1"
+	for i := 0; i < 5; i++ {
+		if _, err := service.Projects.Locations.Registries.Create(parent, &iot.DeviceRegistry{Id: fmt.Sprintf("registry-%d", i)}).Do(); err != nil {
+			t.Fatalf("Failed to create registry: %s", err.Error())
+		}
+	}
+
+	list := service.Projects.Locations.Registries.List(parent).PageSize(2)
+	var ids []string
+	for registry, err := range list.All(ctx) {
+		if err != nil {
+			t.Fatalf("Failed to list registries: %s", err.Error())
+		}
+		ids = append(ids, registry.Id)
+		if len(ids) == 3 {
+			break
+		}
+	}
+	if fmt.Sprint(ids) != "[registry-0 registry-1 registry-2]" {
+		t.Errorf("Expected the first three registries but got: %v", ids)
+	}
+
+	// Read one page, then resume from its token with a new iterator.
+	var page []*iot.DeviceRegistry
+	token, err := iterator.NewPager(list.Iterator(ctx), 2, "").NextPage(&page)
+	if err != nil {
+		t.Fatalf("Failed to read page: %s", err.Error())
+	}
+	if len(page) != 2 || token == "" {
+		t.Fatalf("Expected a page of 2 registries and a next page token but got: %d, %q", len(page), token)
+	}
+	it := list.Iterator(ctx)
+	it.PageInfo().Token = token
+	ids = nil
+	for {
+		registry, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Failed to list registries: %s", err.Error())
+		}
+		ids = append(ids, registry.Id)
+	}
+	if fmt.Sprint(ids) != "[registry-2 registry-3 registry-4]" {
+		t.Errorf("Expected the remaining registries but got: %v", ids)
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright 2023 ClearBlade Inc.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package jwtauth signs and verifies the JWTs devices authenticate to
+// ClearBlade IoT Core with. Tokens are signed with RS256 or ES256 using the
+// private key matching one of the device's credentials, in any of the
+// RSA_PEM, RSA_X509_PEM, ES256_PEM and ES256_X509_PEM formats.
+//
+//	token, err := jwtauth.NewToken(key, "my-project", time.Hour)
+//	...
+//	match, err := jwtauth.Verify(token, "my-project", device.Credentials)
+package jwtauth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// MaxLifetime is the longest time a token may be valid for.
+const MaxLifetime = 24 * time.Hour
+
+// Claims are the claims of a device token.
+type Claims struct {
+	// Audience is the project ID of the device.
+	Audience string
+	// IssuedAt is when the token was created.
+	IssuedAt time.Time
+	// ExpiresAt is when the token stops being valid.
+	ExpiresAt time.Time
+}
+
+type claims struct {
+	Aud string `json:"aud"`
+	Iat int64  `json:"iat"`
+	Exp int64  `json:"exp"`
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+}
+
+// ParsePrivateKey parses a PEM encoded RSA or P-256 private key in PKCS #1,
+// SEC 1 or PKCS #8 form.
+func ParsePrivateKey(pemBytes []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("jwtauth: no PEM data found in private key")
+	}
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("jwtauth: invalid private key: %w", err)
+	}
+	if _, err := Algorithm(key); err != nil {
+		return nil, err
+	}
+	return key.(crypto.Signer), nil
+}
+
+// Algorithm returns the JWT algorithm used with an RSA or ECDSA private or
+// public key: RS256 for RSA keys and ES256 for P-256 keys. Other keys are
+// not supported.
+func Algorithm(key interface{}) (string, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey, *rsa.PublicKey:
+		return "RS256", nil
+	case *ecdsa.PrivateKey:
+		return ecdsaAlgorithm(k.Curve)
+	case *ecdsa.PublicKey:
+		return ecdsaAlgorithm(k.Curve)
+	}
+	return "", fmt.Errorf("jwtauth: unsupported key type %T", key)
+}
+
+func ecdsaAlgorithm(curve elliptic.Curve) (string, error) {
+	if curve != elliptic.P256() {
+		return "", fmt.Errorf("jwtauth: unsupported curve %s, only P-256 is supported", curve.Params().Name)
+	}
+	return "ES256", nil
+}
+
+// NewToken returns a token for the given audience, the project ID, valid
+// from now for lifetime.
+func NewToken(key crypto.Signer, audience string, lifetime time.Duration) (string, error) {
+	now := time.Now()
+	return Sign(key, Claims{Audience: audience, IssuedAt: now, ExpiresAt: now.Add(lifetime)})
+}
+
+// Sign returns a token with the given claims, signed by key.
+func Sign(key crypto.Signer, c Claims) (string, error) {
+	alg, err := Algorithm(key)
+	if err != nil {
+		return "", err
+	}
+	if c.Audience == "" {
+		return "", errors.New("jwtauth: an audience is required")
+	}
+	if lifetime := c.ExpiresAt.Sub(c.IssuedAt); lifetime <= 0 || lifetime > MaxLifetime {
+		return "", fmt.Errorf("jwtauth: token lifetime %s is not between 0 and %s", lifetime, MaxLifetime)
+	}
+	h, _ := json.Marshal(header{Alg: alg, Typ: "JWT"})
+	b, _ := json.Marshal(claims{Aud: c.Audience, Iat: c.IssuedAt.Unix(), Exp: c.ExpiresAt.Unix()})
+	signed := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(b)
+	digest := sha256.Sum256([]byte(signed))
+
+	var sig []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		// ES256 signatures are the fixed-size r and s, not ASN.1.
+		var r, s *big.Int
+		if r, s, err = ecdsa.Sign(rand.Reader, k, digest[:]); err == nil {
+			sig = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+		}
+	}
+	if err != nil {
+		return "", fmt.Errorf("jwtauth: failed to sign: %w", err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
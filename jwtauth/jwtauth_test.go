@@ -0,0 +1,135 @@
+package jwtauth_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	iot "github.com/clearblade/go-iot"
+	"github.com/clearblade/go-iot/jwtauth"
+)
+
+// credential returns a credential holding the public key of key.
+func credential(t *testing.T, key crypto.Signer, format string) *iot.DeviceCredential {
+	t.Helper()
+	var block *pem.Block
+	switch format {
+	case "RSA_PEM", "ES256_PEM":
+		der, err := x509.MarshalPKIXPublicKey(key.Public())
+		if err != nil {
+			t.Fatalf("Failed to marshal public key: %s", err.Error())
+		}
+		block = &pem.Block{Type: "PUBLIC KEY", Bytes: der}
+	default:
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject:      pkix.Name{CommonName: "device"},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+		if err != nil {
+			t.Fatalf("Failed to create certificate: %s", err.Error())
+		}
+		block = &pem.Block{Type: "CERTIFICATE", Bytes: der}
+	}
+	return &iot.DeviceCredential{PublicKey: &iot.PublicKeyCredential{Format: format, Key: string(pem.EncodeToMemory(block))}}
+}
+
+func TestSignAndVerify(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	for _, test := range []struct {
+		format string
+		key    crypto.Signer
+	}{
+		{"RSA_PEM", rsaKey},
+		{"RSA_X509_PEM", rsaKey},
+		{"ES256_PEM", ecKey},
+		{"ES256_X509_PEM", ecKey},
+	} {
+		token, err := jwtauth.NewToken(test.key, "project", time.Hour)
+		if err != nil {
+			t.Fatalf("%s: failed to sign token: %s", test.format, err.Error())
+		}
+		otherKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		credentials := []*iot.DeviceCredential{
+			credential(t, otherKey, "ES256_PEM"),
+			credential(t, test.key, test.format),
+		}
+		match, err := jwtauth.Verify(token, "project", credentials)
+		if err != nil {
+			t.Errorf("%s: failed to verify token: %s", test.format, err.Error())
+			continue
+		}
+		if match.Index != 1 || match.Credential != credentials[1] || match.Claims.Audience != "project" {
+			t.Errorf("%s: expected the second credential to match but got: %+v", test.format, match)
+		}
+
+		if _, err := jwtauth.Verify(token, "project", credentials[:1]); !errors.Is(err, jwtauth.ErrInvalidSignature) {
+			t.Errorf("%s: expected ErrInvalidSignature but got: %v", test.format, err)
+		}
+	}
+}
+
+func TestVerifyExpiry(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	now := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	token, err := jwtauth.Sign(key, jwtauth.Claims{Audience: "project", IssuedAt: now, ExpiresAt: now.Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("Failed to sign token: %s", err.Error())
+	}
+	cred := credential(t, key, "ES256_PEM")
+
+	for _, test := range []struct {
+		name       string
+		audience   string
+		at         time.Time
+		expiration string
+		want       error
+	}{
+		{"valid", "project", now.Add(time.Minute), "", nil},
+		{"valid credential", "project", now, "2023-06-02T00:00:00Z", nil},
+		{"expired credential", "project", now, "2023-06-01T00:00:00Z", jwtauth.ErrCredentialExpired},
+		{"credential without expiry", "project", now, "1970-01-01T00:00:00Z", nil},
+		{"expired token", "project", now.Add(2 * time.Hour), "", jwtauth.ErrTokenExpired},
+		{"future token", "project", now.Add(-time.Hour), "", jwtauth.ErrTokenExpired},
+		{"other project", "other", now, "", jwtauth.ErrInvalidAudience},
+	} {
+		cred.ExpirationTime = test.expiration
+		v := jwtauth.Verifier{Audience: test.audience, Now: func() time.Time { return test.at }}
+		_, err := v.Verify(token, []*iot.DeviceCredential{cred})
+		if test.want == nil && err != nil {
+			t.Errorf("%s: failed to verify token: %s", test.name, err.Error())
+		}
+		if test.want != nil && !errors.Is(err, test.want) {
+			t.Errorf("%s: expected %v but got: %v", test.name, test.want, err)
+		}
+	}
+
+	if _, err := jwtauth.Sign(key, jwtauth.Claims{Audience: "project", IssuedAt: now, ExpiresAt: now.Add(25 * time.Hour)}); err == nil {
+		t.Errorf("Expected an error for a token valid longer than MaxLifetime")
+	}
+	if _, err := jwtauth.Verify("not.a.token", "project", []*iot.DeviceCredential{cred}); !errors.Is(err, jwtauth.ErrMalformedToken) {
+		t.Errorf("Expected ErrMalformedToken but got: %v", err)
+	}
+}
+
+func TestParsePublicKeyFormatMismatch(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	cred := credential(t, key, "ES256_PEM")
+	cred.PublicKey.Format = "RSA_PEM"
+	if _, err := jwtauth.ParsePublicKey(cred.PublicKey); err == nil {
+		t.Errorf("Expected an error for an ES256 key in an RSA_PEM credential")
+	}
+}
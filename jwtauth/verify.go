@@ -0,0 +1,224 @@
+// Copyright 2023 ClearBlade Inc.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jwtauth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	iot "github.com/clearblade/go-iot"
+)
+
+var (
+	// ErrMalformedToken is returned for tokens that are not JWTs signed
+	// with RS256 or ES256.
+	ErrMalformedToken = errors.New("jwtauth: malformed token")
+	// ErrInvalidSignature is returned when no credential of the device
+	// verifies the signature of the token.
+	ErrInvalidSignature = errors.New("jwtauth: no credential matches the token signature")
+	// ErrCredentialExpired is returned when the only credentials that verify
+	// the signature of the token have expired.
+	ErrCredentialExpired = errors.New("jwtauth: the matching credential has expired")
+	// ErrInvalidAudience is returned for tokens issued for another project.
+	ErrInvalidAudience = errors.New("jwtauth: invalid audience")
+	// ErrTokenExpired is returned for tokens that have expired, are not
+	// valid yet, or are valid for longer than MaxLifetime.
+	ErrTokenExpired = errors.New("jwtauth: token expired or not yet valid")
+)
+
+// Match is a successfully verified token.
+type Match struct {
+	// Claims are the claims of the token.
+	Claims Claims
+	// Index is the index of the credential that verified the token.
+	Index int
+	// Credential is the credential that verified the token.
+	Credential *iot.DeviceCredential
+}
+
+// Verifier verifies device tokens. The zero value verifies tokens for any
+// audience against the current time.
+type Verifier struct {
+	// Audience is the required audience, the project ID. If empty, any
+	// audience is accepted.
+	Audience string
+
+	// Now returns the time tokens and credentials are checked at. It
+	// defaults to time.Now.
+	Now func() time.Time
+
+	// Leeway is the clock skew tolerated when checking the times of a token.
+	Leeway time.Duration
+}
+
+// Verify verifies token against a device's credentials, such as the
+// Credentials of an *iot.Device, using a Verifier for audience.
+func Verify(token, audience string, credentials []*iot.DeviceCredential) (*Match, error) {
+	v := Verifier{Audience: audience}
+	return v.Verify(token, credentials)
+}
+
+// Verify checks that token is signed by the key of one of credentials that
+// has not reached its ExpirationTime, and that its claims are valid. It
+// returns the first matching credential.
+func (v *Verifier) Verify(token string, credentials []*iot.DeviceCredential) (*Match, error) {
+	now := time.Now()
+	if v.Now != nil {
+		now = v.Now()
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformedToken
+	}
+	var h header
+	var c claims
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return nil, err
+	}
+	if err := decodeSegment(parts[1], &c); err != nil {
+		return nil, err
+	}
+	if h.Alg != "RS256" && h.Alg != "ES256" {
+		return nil, fmt.Errorf("%w: unsupported algorithm %q", ErrMalformedToken, h.Alg)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedToken, err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+
+	match := -1
+	expired := false
+	var errs []error
+	for i, cred := range credentials {
+		if cred == nil || cred.PublicKey == nil {
+			continue
+		}
+		key, err := ParsePublicKey(cred.PublicKey)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("credential %d: %w", i, err))
+			continue
+		}
+		if !verifySignature(h.Alg, key, digest[:], sig) {
+			continue
+		}
+		if cred.ExpirationTime != "" {
+			expires, err := time.Parse(time.RFC3339Nano, cred.ExpirationTime)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("credential %d: invalid expiration time: %w", i, err))
+				continue
+			}
+			// Credentials that never expire have the Unix epoch as their
+			// expiration time.
+			if expires.Unix() > 0 && !now.Before(expires) {
+				expired = true
+				continue
+			}
+		}
+		match = i
+		break
+	}
+	if match < 0 {
+		if expired {
+			return nil, ErrCredentialExpired
+		}
+		return nil, errors.Join(append([]error{ErrInvalidSignature}, errs...)...)
+	}
+
+	m := &Match{
+		Claims: Claims{
+			Audience:  c.Aud,
+			IssuedAt:  time.Unix(c.Iat, 0),
+			ExpiresAt: time.Unix(c.Exp, 0),
+		},
+		Index:      match,
+		Credential: credentials[match],
+	}
+	if v.Audience != "" && c.Aud != v.Audience {
+		return nil, fmt.Errorf("%w %q", ErrInvalidAudience, c.Aud)
+	}
+	switch {
+	case m.Claims.ExpiresAt.Sub(m.Claims.IssuedAt) > MaxLifetime:
+		return nil, fmt.Errorf("%w: lifetime is longer than %s", ErrTokenExpired, MaxLifetime)
+	case now.Add(v.Leeway).Before(m.Claims.IssuedAt):
+		return nil, fmt.Errorf("%w: issued in the future", ErrTokenExpired)
+	case !now.Add(-v.Leeway).Before(m.Claims.ExpiresAt):
+		return nil, ErrTokenExpired
+	}
+	return m, nil
+}
+
+// ParsePublicKey parses the key of a credential in the RSA_PEM,
+// RSA_X509_PEM, ES256_PEM or ES256_X509_PEM format.
+func ParsePublicKey(cred *iot.PublicKeyCredential) (crypto.PublicKey, error) {
+	block, _ := pem.Decode([]byte(cred.Key))
+	if block == nil {
+		return nil, errors.New("jwtauth: no PEM data found in public key")
+	}
+	var key interface{}
+	var err error
+	switch cred.Format {
+	case "RSA_PEM", "ES256_PEM":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA_X509_PEM", "ES256_X509_PEM":
+		var cert *x509.Certificate
+		if cert, err = x509.ParseCertificate(block.Bytes); err == nil {
+			key = cert.PublicKey
+		}
+	default:
+		return nil, fmt.Errorf("jwtauth: unsupported public key format %q", cred.Format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("jwtauth: invalid %s public key: %w", cred.Format, err)
+	}
+	alg, err := Algorithm(key)
+	if err != nil {
+		return nil, err
+	}
+	want := "RS256"
+	if strings.HasPrefix(cred.Format, "ES256") {
+		want = "ES256"
+	}
+	if alg != want {
+		return nil, fmt.Errorf("jwtauth: %s credential holds an %s key", cred.Format, alg)
+	}
+	return key, nil
+}
+
+func decodeSegment(s string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err == nil {
+		err = json.Unmarshal(b, v)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrMalformedToken, err)
+	}
+	return nil
+}
+
+func verifySignature(alg string, key crypto.PublicKey, digest, sig []byte) bool {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return alg == "RS256" && rsa.VerifyPKCS1v15(k, crypto.SHA256, digest, sig) == nil
+	case *ecdsa.PublicKey:
+		if alg != "ES256" || len(sig) != 64 {
+			return false
+		}
+		r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+		return ecdsa.Verify(k, digest, r, s)
+	}
+	return false
+}
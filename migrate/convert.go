@@ -0,0 +1,231 @@
+// Copyright 2023 ClearBlade Inc.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package migrate
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	iot "github.com/clearblade/go-iot"
+)
+
+// logLevels are the log levels ClearBlade IoT Core accepts.
+var logLevels = map[string]bool{"NONE": true, "ERROR": true, "INFO": true, "DEBUG": true}
+
+// Options configures Check and Import.
+type Options struct {
+	// Project and Location are where the registries are created. By
+	// default, the project and location of each exported registry are used.
+	Project  string
+	Location string
+
+	// Topic maps a Pub/Sub topic of the export to the topic notifications
+	// are published to in ClearBlade. Topics it returns an error for are
+	// left out and reported. The default keeps topics of the form
+	// projects/{project}/topics/{topic} as they are.
+	Topic func(topic string) (string, error)
+
+	// Checkpoint is the path of a file recording the progress of Import.
+	// If the file exists, the steps it records are skipped, so that a
+	// failed import can be run again to continue where it stopped.
+	Checkpoint string
+}
+
+// Issue is a setting of an export that ClearBlade IoT Core does not
+// support. The setting is left out of the import.
+type Issue struct {
+	// Resource is the name of the exported registry or device.
+	Resource string
+	// Field is the API field path of the setting.
+	Field   string
+	Message string
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("%s: %s: %s", i.Resource, i.Field, i.Message)
+}
+
+// Check maps an export onto ClearBlade IoT Core without importing it and
+// returns the issues found.
+func Check(e *Export, opts Options) []Issue {
+	_, issues := convert(e, opts)
+	return issues
+}
+
+// registryImport is a registry as it is created in ClearBlade.
+type registryImport struct {
+	name     iot.RegistryName
+	registry *iot.DeviceRegistry
+	devices  []*deviceImport
+	bindings []*Binding
+}
+
+// deviceImport is a device as it is created in ClearBlade, with the binary
+// data of its config history, oldest first.
+type deviceImport struct {
+	device  *iot.Device
+	configs []string
+}
+
+func convert(e *Export, opts Options) ([]*registryImport, []Issue) {
+	c := &converter{opts: opts}
+	if c.opts.Topic == nil {
+		c.opts.Topic = defaultTopic
+	}
+	var imports []*registryImport
+	for _, r := range e.Registries {
+		if imp := c.registry(r); imp != nil {
+			imports = append(imports, imp)
+		}
+	}
+	return imports, c.issues
+}
+
+type converter struct {
+	opts   Options
+	issues []Issue
+}
+
+func (c *converter) report(resource, field, format string, args ...interface{}) {
+	c.issues = append(c.issues, Issue{Resource: resource, Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// registry returns nil for a registry that cannot be imported.
+func (c *converter) registry(r *RegistryExport) *registryImport {
+	src := r.Registry
+	name, err := iot.ParseRegistryName(src.Name)
+	if err != nil {
+		c.report(src.Name, "name", "%v; registry left out", err)
+		return nil
+	}
+	if c.opts.Project != "" {
+		name.Project = c.opts.Project
+	}
+	if c.opts.Location != "" {
+		name.Location = c.opts.Location
+	}
+	registry := &iot.DeviceRegistry{
+		Id:         name.Registry,
+		MqttConfig: src.MqttConfig,
+		HttpConfig: src.HttpConfig,
+		LogLevel:   c.logLevel(src.Name, src.LogLevel),
+	}
+	for i, n := range src.EventNotificationConfigs {
+		topic, err := c.opts.Topic(n.PubsubTopicName)
+		if err != nil {
+			c.report(src.Name, fmt.Sprintf("eventNotificationConfigs[%d].pubsubTopicName", i), "%v", err)
+			continue
+		}
+		registry.EventNotificationConfigs = append(registry.EventNotificationConfigs, &iot.EventNotificationConfig{
+			PubsubTopicName:  topic,
+			SubfolderMatches: n.SubfolderMatches,
+		})
+	}
+	if n := src.StateNotificationConfig; n != nil && n.PubsubTopicName != "" {
+		if topic, err := c.opts.Topic(n.PubsubTopicName); err != nil {
+			c.report(src.Name, "stateNotificationConfig.pubsubTopicName", "%v", err)
+		} else {
+			registry.StateNotificationConfig = &iot.StateNotificationConfig{PubsubTopicName: topic}
+		}
+	}
+	for _, cred := range src.Credentials {
+		if cred.PublicKeyCertificate != nil {
+			registry.Credentials = append(registry.Credentials, &iot.RegistryCredential{
+				PublicKeyCertificate: &iot.PublicKeyCertificate{
+					Format:      cred.PublicKeyCertificate.Format,
+					Certificate: cred.PublicKeyCertificate.Certificate,
+				},
+			})
+		}
+	}
+
+	imp := &registryImport{name: name, registry: registry}
+	gateways := make(map[string]bool)
+	devices := make(map[string]bool)
+	var withStates int
+	for _, d := range r.Devices {
+		imp.devices = append(imp.devices, c.device(name, d))
+		devices[d.Device.Id] = true
+		if d.Device.GatewayConfig != nil && d.Device.GatewayConfig.GatewayType == "GATEWAY" {
+			gateways[d.Device.Id] = true
+		}
+		if len(d.States) > 0 {
+			withStates++
+		}
+	}
+	if withStates > 0 {
+		c.report(src.Name, "devices.states", "states of %d devices cannot be imported; devices report them again when they connect", withStates)
+	}
+	for _, b := range r.Bindings {
+		switch {
+		case !gateways[b.GatewayID]:
+			c.report(src.Name, "bindings", "%s is not a gateway of the export; binding of %s left out", b.GatewayID, b.DeviceID)
+		case !devices[b.DeviceID]:
+			c.report(src.Name, "bindings", "device %s is not in the export; binding to %s left out", b.DeviceID, b.GatewayID)
+		default:
+			imp.bindings = append(imp.bindings, b)
+		}
+	}
+	return imp
+}
+
+func (c *converter) device(registry iot.RegistryName, d *DeviceExport) *deviceImport {
+	src := d.Device
+	resource := src.Name
+	if resource == "" {
+		resource = registry.Device(src.Id).String()
+	}
+	device := &iot.Device{
+		Id:       src.Id,
+		Metadata: src.Metadata,
+		Blocked:  src.Blocked,
+		LogLevel: c.logLevel(resource, src.LogLevel),
+	}
+	for _, cred := range src.Credentials {
+		if cred.PublicKey != nil {
+			device.Credentials = append(device.Credentials, &iot.DeviceCredential{
+				PublicKey:      cred.PublicKey,
+				ExpirationTime: cred.ExpirationTime,
+			})
+		}
+	}
+	if src.GatewayConfig != nil {
+		device.GatewayConfig = &iot.GatewayConfig{
+			GatewayType:       src.GatewayConfig.GatewayType,
+			GatewayAuthMethod: src.GatewayConfig.GatewayAuthMethod,
+		}
+	}
+
+	configs := append([]*iot.DeviceConfig(nil), d.ConfigVersions...)
+	if len(configs) == 0 && src.Config != nil {
+		configs = append(configs, src.Config)
+	}
+	sort.SliceStable(configs, func(i, j int) bool { return configs[i].Version < configs[j].Version })
+	imp := &deviceImport{device: device}
+	for _, config := range configs {
+		imp.configs = append(imp.configs, config.BinaryData)
+	}
+	return imp
+}
+
+func (c *converter) logLevel(resource, level string) string {
+	switch {
+	case level == "" || level == "LOG_LEVEL_UNSPECIFIED":
+		return ""
+	case logLevels[level]:
+		return level
+	}
+	c.report(resource, "logLevel", "log level %q is not supported; the default is used", level)
+	return ""
+}
+
+func defaultTopic(topic string) (string, error) {
+	parts := strings.Split(topic, "/")
+	if len(parts) != 4 || parts[0] != "projects" || parts[1] == "" || parts[2] != "topics" || parts[3] == "" {
+		return "", fmt.Errorf("topic %q is not a Pub/Sub topic name", topic)
+	}
+	return topic, nil
+}
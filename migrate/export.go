@@ -0,0 +1,101 @@
+// Copyright 2023 ClearBlade Inc.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package migrate imports registries and devices exported from Google Cloud
+// IoT Core into ClearBlade IoT Core:
+//
+//	export, err := migrate.LoadExport("export.json")
+//	...
+//	for _, issue := range migrate.Check(export, opts) {
+//		fmt.Println(issue)
+//	}
+//	report, err := migrate.Import(ctx, service, export, migrate.Options{Checkpoint: "import.checkpoint"})
+//
+// An export is a JSON document holding the resources as the Cloud IoT API
+// returned them, for example collected with `gcloud iot ... --format=json`:
+//
+//	{
+//	  "registries": [{
+//	    "registry": {"name": "projects/p/locations/us-central1/registries/r", ...},
+//	    "devices": [{
+//	      "device": {"id": "d", ...},
+//	      "configVersions": [{"version": "2", "binaryData": "..."}, ...],
+//	      "states": [{"updateTime": "...", "binaryData": "..."}, ...]
+//	    }],
+//	    "bindings": [{"gatewayId": "g", "deviceId": "d"}]
+//	  }]
+//	}
+//
+// Settings ClearBlade does not support are left out and reported as
+// issues rather than failing the import.
+package migrate
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	iot "github.com/clearblade/go-iot"
+)
+
+// Export is a Google Cloud IoT Core export.
+type Export struct {
+	Registries []*RegistryExport `json:"registries"`
+}
+
+// RegistryExport is an exported registry with its devices and bindings.
+type RegistryExport struct {
+	Registry *iot.DeviceRegistry `json:"registry"`
+	Devices  []*DeviceExport     `json:"devices,omitempty"`
+	Bindings []*Binding          `json:"bindings,omitempty"`
+}
+
+// DeviceExport is an exported device with its config history and states.
+type DeviceExport struct {
+	Device *iot.Device `json:"device"`
+	// ConfigVersions is the config history of the device, in any order.
+	ConfigVersions []*iot.DeviceConfig `json:"configVersions,omitempty"`
+	// States are the recent states of the device. They are reported by
+	// devices, so they cannot be imported.
+	States []*iot.DeviceState `json:"states,omitempty"`
+}
+
+// Binding binds a device to a gateway of the same registry.
+type Binding struct {
+	GatewayID string `json:"gatewayId"`
+	DeviceID  string `json:"deviceId"`
+}
+
+// LoadExport reads an export from a file.
+func LoadExport(path string) (*Export, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadExport(f)
+}
+
+// ReadExport reads an export from r.
+func ReadExport(r io.Reader) (*Export, error) {
+	var e Export
+	if err := json.NewDecoder(r).Decode(&e); err != nil {
+		return nil, fmt.Errorf("migrate: invalid export: %w", err)
+	}
+	for i, r := range e.Registries {
+		if r.Registry == nil {
+			return nil, fmt.Errorf("migrate: invalid export: registry %d is missing", i)
+		}
+		if _, err := iot.ParseRegistryName(r.Registry.Name); err != nil {
+			return nil, fmt.Errorf("migrate: invalid export: %w", err)
+		}
+		for j, d := range r.Devices {
+			if d.Device == nil || d.Device.Id == "" {
+				return nil, fmt.Errorf("migrate: invalid export: device %d of registry %s is missing", j, r.Registry.Name)
+			}
+		}
+	}
+	return &e, nil
+}
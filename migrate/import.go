@@ -0,0 +1,173 @@
+// Copyright 2023 ClearBlade Inc.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package migrate
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	iot "github.com/clearblade/go-iot"
+)
+
+// Report is the result of Import.
+type Report struct {
+	// Issues are the settings that were left out.
+	Issues []Issue
+	// Registries, Devices, Configs and Bindings count what this run
+	// created. Configs counts the config versions after the first.
+	Registries, Devices, Configs, Bindings int
+	// Resumed counts the steps skipped because the checkpoint recorded them.
+	Resumed int
+}
+
+// Import creates the registries, devices, config histories and gateway
+// bindings of an export. The config history of each device is replayed so
+// that its last version is current; version numbers and times are assigned
+// anew.
+//
+// Import stops at the first failure and returns the report so far with the
+// error. With a checkpoint, running it again continues after the last step
+// that succeeded, though a config version sent just before a failure may be
+// sent twice.
+func Import(ctx context.Context, service *iot.Service, e *Export, opts Options) (*Report, error) {
+	imports, issues := convert(e, opts)
+	report := &Report{Issues: issues}
+	cp, err := openCheckpoint(opts.Checkpoint)
+	if err != nil {
+		return report, err
+	}
+	defer cp.close()
+
+	// step runs fn unless the checkpoint records it as done, and records it
+	// once it succeeds. When resuming, the failed run may have created the
+	// resource of the first step missing from the checkpoint just before it
+	// stopped, so that one may already exist. Any other existing resource
+	// was not created by the import, and fails it.
+	step := func(key string, fn func() error, count *int) error {
+		if cp.done[key] {
+			report.Resumed++
+			return nil
+		}
+		err := fn()
+		resumed := cp.resuming
+		cp.resuming = false
+		if errors.Is(err, iot.ErrAlreadyExists) && resumed {
+			err = nil
+		} else if err == nil {
+			*count++
+		}
+		if err != nil {
+			return fmt.Errorf("migrate: %s: %w", key, err)
+		}
+		return cp.record(key)
+	}
+
+	registries := service.Projects.Locations.Registries
+	devices := registries.Devices
+	for _, r := range imports {
+		err := step("create "+r.name.String(), func() error {
+			_, err := registries.CreateIn(r.name.Parent(), r.registry).Context(ctx).Do()
+			return err
+		}, &report.Registries)
+		if err != nil {
+			return report, err
+		}
+		for _, d := range r.devices {
+			name := r.name.Device(d.device.Id)
+			err := step("create "+name.String(), func() error {
+				device := *d.device
+				if len(d.configs) > 0 {
+					device.Config = &iot.DeviceConfig{BinaryData: d.configs[0]}
+				}
+				_, err := devices.CreateIn(r.name, &device).Context(ctx).Do()
+				return err
+			}, &report.Devices)
+			if err != nil {
+				return report, err
+			}
+			for i := 1; i < len(d.configs); i++ {
+				err := step(fmt.Sprintf("config %s %d", name, i), func() error {
+					_, err := devices.ModifyCloudToDeviceConfigByName(name, &iot.ModifyCloudToDeviceConfigRequest{
+						BinaryData: d.configs[i],
+					}).Context(ctx).Do()
+					return err
+				}, &report.Configs)
+				if err != nil {
+					return report, err
+				}
+			}
+		}
+		for _, b := range r.bindings {
+			err := step(fmt.Sprintf("bind %s %s %s", r.name, b.GatewayID, b.DeviceID), func() error {
+				_, err := registries.BindDeviceToGatewayIn(r.name, &iot.BindDeviceToGatewayRequest{
+					GatewayId: b.GatewayID,
+					DeviceId:  b.DeviceID,
+				}).Context(ctx).Do()
+				return err
+			}, &report.Bindings)
+			if err != nil {
+				return report, err
+			}
+		}
+	}
+	return report, nil
+}
+
+// checkpoint is the set of steps an import has completed, one per line of
+// an append-only file.
+type checkpoint struct {
+	f        *os.File
+	done     map[string]bool
+	resuming bool
+}
+
+func openCheckpoint(path string) (*checkpoint, error) {
+	cp := &checkpoint{done: make(map[string]bool)}
+	if path == "" {
+		return cp, nil
+	}
+	// A checkpoint file that exists is resumed even if it is empty: the run
+	// that created it may have stopped after its first step but before
+	// recording it.
+	_, err := os.Stat(path)
+	cp.resuming = err == nil
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			cp.done[line] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("migrate: reading checkpoint: %w", err)
+	}
+	cp.f = f
+	return cp, nil
+}
+
+func (cp *checkpoint) record(key string) error {
+	cp.done[key] = true
+	if cp.f == nil {
+		return nil
+	}
+	if _, err := cp.f.WriteString(key + "\n"); err != nil {
+		return fmt.Errorf("migrate: writing checkpoint: %w", err)
+	}
+	return cp.f.Sync()
+}
+
+func (cp *checkpoint) close() {
+	if cp.f != nil {
+		cp.f.Close()
+	}
+}
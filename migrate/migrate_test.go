@@ -0,0 +1,141 @@
+package migrate_test
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	iot "github.com/clearblade/go-iot"
+	"github.com/clearblade/go-iot/iottest"
+	"github.com/clearblade/go-iot/migrate"
+)
+
+const export = `{
+  "registries": [{
+    "registry": {
+      "name": "projects/google-project/locations/us-central1/registries/registry",
+      "id": "registry",
+      "logLevel": "VERBOSE",
+      "eventNotificationConfigs": [
+        {"pubsubTopicName": "projects/google-project/topics/events"},
+        {"pubsubTopicName": "events"}
+      ]
+    },
+    "devices": [
+      {"device": {"id": "gateway", "numId": "123", "gatewayConfig": {"gatewayType": "GATEWAY", "gatewayAuthMethod": "ASSOCIATION_ONLY"}}},
+      {
+        "device": {"id": "device", "metadata": {"site": "berlin"}, "logLevel": "LOG_LEVEL_UNSPECIFIED"},
+        "configVersions": [
+          {"version": "2", "binaryData": "dHdv"},
+          {"version": "1", "binaryData": "b25l"}
+        ],
+        "states": [{"updateTime": "2023-01-01T00:00:00Z", "binaryData": "b2s="}]
+      }
+    ],
+    "bindings": [
+      {"gatewayId": "gateway", "deviceId": "device"},
+      {"gatewayId": "device", "deviceId": "gateway"}
+    ]
+  }]
+}`
+
+func TestImport(t *testing.T) {
+	_, service := iottest.NewService(t, "test-project")
+	ctx := context.Background()
+	e, err := migrate.ReadExport(strings.NewReader(export))
+	if err != nil {
+		t.Fatalf("Failed to read export: %s", err.Error())
+	}
+	opts := migrate.Options{Project: "test-project", Checkpoint: filepath.Join(t.TempDir(), "checkpoint")}
+
+	issues := migrate.Check(e, opts)
+	var fields []string
+	for _, issue := range issues {
+		fields = append(fields, issue.Field)
+	}
+	if got := strings.Join(fields, ","); got != "logLevel,eventNotificationConfigs[1].pubsubTopicName,devices.states,bindings" {
+		t.Errorf("Expected issues for the log level, topic, states and binding but got: %v", issues)
+	}
+
+	// A previous run created the registry and the gateway, but stopped
+	// before recording the gateway in the checkpoint.
+	registry := iot.LocationName{Project: "test-project", Location: "us-central1"}.Registry("registry")
+	iottest.CreateRegistry(t, service, registry)
+	if _, err := service.Projects.Locations.Registries.Devices.CreateIn(registry, &iot.Device{
+		Id:            "gateway",
+		GatewayConfig: &iot.GatewayConfig{GatewayType: "GATEWAY", GatewayAuthMethod: "ASSOCIATION_ONLY"},
+	}).Do(); err != nil {
+		t.Fatalf("Failed to create device: %s", err.Error())
+	}
+	if err := os.WriteFile(opts.Checkpoint, []byte("create "+registry.String()+"\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write checkpoint: %s", err.Error())
+	}
+
+	report, err := migrate.Import(ctx, service, e, opts)
+	if err != nil {
+		t.Fatalf("Failed to import: %s", err.Error())
+	}
+	if report.Resumed != 1 || report.Registries != 0 || report.Devices != 1 || report.Configs != 1 || report.Bindings != 1 {
+		t.Errorf("Expected the import to resume after the registry but got: %+v", report)
+	}
+	device, err := service.Projects.Locations.Registries.Devices.GetByName(registry.Device("device")).Do()
+	if err != nil {
+		t.Fatalf("Failed to get device: %s", err.Error())
+	}
+	if data, _ := base64.StdEncoding.DecodeString(device.Config.BinaryData); string(data) != "two" || device.Metadata["site"] != "berlin" {
+		t.Errorf("Expected the last config and metadata to be imported but got: %q %v", data, device.Metadata)
+	}
+
+	// Everything is recorded, so running again does nothing.
+	report, err = migrate.Import(ctx, service, e, opts)
+	if err != nil {
+		t.Fatalf("Failed to import: %s", err.Error())
+	}
+	if report.Resumed != 5 || report.Devices != 0 {
+		t.Errorf("Expected all 5 steps to be skipped but got: %+v", report)
+	}
+}
+
+func TestImportEmptyCheckpoint(t *testing.T) {
+	_, service := iottest.NewService(t, "test-project")
+	ctx := context.Background()
+	e, err := migrate.ReadExport(strings.NewReader(export))
+	if err != nil {
+		t.Fatalf("Failed to read export: %s", err.Error())
+	}
+	opts := migrate.Options{Project: "test-project", Checkpoint: filepath.Join(t.TempDir(), "checkpoint")}
+
+	// A previous run created the registry, but stopped before recording
+	// anything in the checkpoint. The device existed before the import.
+	registry := iot.LocationName{Project: "test-project", Location: "us-central1"}.Registry("registry")
+	iottest.CreateRegistry(t, service, registry)
+	if _, err := service.Projects.Locations.Registries.Devices.CreateIn(registry, &iot.Device{Id: "device"}).Do(); err != nil {
+		t.Fatalf("Failed to create device: %s", err.Error())
+	}
+	if err := os.WriteFile(opts.Checkpoint, nil, 0o644); err != nil {
+		t.Fatalf("Failed to write checkpoint: %s", err.Error())
+	}
+
+	report, err := migrate.Import(ctx, service, e, opts)
+	if !errors.Is(err, iot.ErrAlreadyExists) {
+		t.Fatalf("Expected ErrAlreadyExists for the existing device but got: %v", err)
+	}
+	if report.Registries != 0 || report.Devices != 1 {
+		t.Errorf("Expected the existing registry to be kept and the gateway to be created but got: %+v", report)
+	}
+}
+
+func TestCheckInvalidName(t *testing.T) {
+	e := &migrate.Export{Registries: []*migrate.RegistryExport{
+		{Registry: &iot.DeviceRegistry{Name: "registry"}},
+		{Registry: &iot.DeviceRegistry{Name: "projects/google-project/locations/us-central1/registries/registry"}},
+	}}
+	issues := migrate.Check(e, migrate.Options{Project: "test-project"})
+	if len(issues) != 1 || issues[0].Resource != "registry" || issues[0].Field != "name" {
+		t.Errorf("Expected an issue for the invalid registry name but got: %v", issues)
+	}
+}
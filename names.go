@@ -0,0 +1,302 @@
+// Copyright 2023 ClearBlade Inc.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iot
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/clearblade/go-iot/cblib/path_template"
+)
+
+var (
+	locationPathTemplate = path_template.MustCompilePathTemplate("projects/{project}/locations/{location}")
+	registryPathTemplate = path_template.MustCompilePathTemplate("projects/{project}/locations/{location}/registries/{registry}")
+	devicePathTemplate   = path_template.MustCompilePathTemplate("projects/{project}/locations/{location}/registries/{registry}/devices/{device}")
+	groupPathTemplate    = path_template.MustCompilePathTemplate("projects/{project}/locations/{location}/registries/{registry}/groups/{group}")
+
+	projectIDPattern  = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9\-.:_]{0,99}$`)
+	locationIDPattern = regexp.MustCompile(`^[a-z][a-z0-9\-]{0,62}$`)
+	// resourceIDPattern is the format of registry, device and group IDs: 3 to
+	// 255 characters, starting with a letter.
+	resourceIDPattern = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9\-._+~%]{2,254}$`)
+	numericIDPattern  = regexp.MustCompile(`^[0-9]{1,20}$`)
+)
+
+// LocationName is the name of a location,
+// "projects/{project}/locations/{location}".
+type LocationName struct {
+	Project  string
+	Location string
+}
+
+// ParseLocationName parses and validates a location name.
+func ParseLocationName(name string) (LocationName, error) {
+	m, err := locationPathTemplate.Match(name)
+	if err != nil {
+		return LocationName{}, invalidNameError(name, err)
+	}
+	n := LocationName{Project: m["project"], Location: m["location"]}
+	return n, n.Validate()
+}
+
+// String returns the name in the form used by the API.
+func (n LocationName) String() string {
+	s, _ := locationPathTemplate.Render(map[string]string{"project": n.Project, "location": n.Location})
+	return s
+}
+
+// Validate reports whether the project and location IDs are well-formed.
+func (n LocationName) Validate() error {
+	if !projectIDPattern.MatchString(n.Project) {
+		return invalidNameError(n.String(), fmt.Errorf("invalid project ID %q", n.Project))
+	}
+	if !locationIDPattern.MatchString(n.Location) {
+		return invalidNameError(n.String(), fmt.Errorf("invalid location %q", n.Location))
+	}
+	return nil
+}
+
+// Registry returns the name of a registry in the location.
+func (n LocationName) Registry(id string) RegistryName {
+	return RegistryName{Project: n.Project, Location: n.Location, Registry: id}
+}
+
+// RegistryName is the name of a device registry,
+// "projects/{project}/locations/{location}/registries/{registry}".
+type RegistryName struct {
+	Project  string
+	Location string
+	Registry string
+}
+
+// ParseRegistryName parses and validates a registry name.
+func ParseRegistryName(name string) (RegistryName, error) {
+	m, err := registryPathTemplate.Match(name)
+	if err != nil {
+		return RegistryName{}, invalidNameError(name, err)
+	}
+	n := RegistryName{Project: m["project"], Location: m["location"], Registry: m["registry"]}
+	return n, n.Validate()
+}
+
+// String returns the name in the form used by the API.
+func (n RegistryName) String() string {
+	s, _ := registryPathTemplate.Render(map[string]string{"project": n.Project, "location": n.Location, "registry": n.Registry})
+	return s
+}
+
+// Validate reports whether all IDs in the name are well-formed.
+func (n RegistryName) Validate() error {
+	if err := n.Parent().Validate(); err != nil {
+		return err
+	}
+	if !resourceIDPattern.MatchString(n.Registry) {
+		return invalidNameError(n.String(), fmt.Errorf("invalid registry ID %q", n.Registry))
+	}
+	return nil
+}
+
+// Parent returns the name of the location of the registry.
+func (n RegistryName) Parent() LocationName {
+	return LocationName{Project: n.Project, Location: n.Location}
+}
+
+// Device returns the name of a device in the registry.
+func (n RegistryName) Device(id string) DeviceName {
+	return DeviceName{Project: n.Project, Location: n.Location, Registry: n.Registry, Device: id}
+}
+
+// Group returns the name of a device group in the registry.
+func (n RegistryName) Group(id string) GroupName {
+	return GroupName{Project: n.Project, Location: n.Location, Registry: n.Registry, Group: id}
+}
+
+// DeviceName is the name of a device,
+// "projects/{project}/locations/{location}/registries/{registry}/devices/{device}".
+// Device is either the device ID or its numeric ID.
+type DeviceName struct {
+	Project  string
+	Location string
+	Registry string
+	Device   string
+}
+
+// ParseDeviceName parses and validates a device name.
+func ParseDeviceName(name string) (DeviceName, error) {
+	m, err := devicePathTemplate.Match(name)
+	if err != nil {
+		return DeviceName{}, invalidNameError(name, err)
+	}
+	n := DeviceName{Project: m["project"], Location: m["location"], Registry: m["registry"], Device: m["device"]}
+	return n, n.Validate()
+}
+
+// String returns the name in the form used by the API.
+func (n DeviceName) String() string {
+	s, _ := devicePathTemplate.Render(map[string]string{"project": n.Project, "location": n.Location, "registry": n.Registry, "device": n.Device})
+	return s
+}
+
+// Validate reports whether all IDs in the name are well-formed.
+func (n DeviceName) Validate() error {
+	if err := n.Parent().Validate(); err != nil {
+		return err
+	}
+	if !resourceIDPattern.MatchString(n.Device) && !n.IsNumeric() {
+		return invalidNameError(n.String(), fmt.Errorf("invalid device ID %q", n.Device))
+	}
+	return nil
+}
+
+// IsNumeric reports whether the device is identified by its numeric ID.
+func (n DeviceName) IsNumeric() bool {
+	return numericIDPattern.MatchString(n.Device)
+}
+
+// Parent returns the name of the registry of the device.
+func (n DeviceName) Parent() RegistryName {
+	return RegistryName{Project: n.Project, Location: n.Location, Registry: n.Registry}
+}
+
+// GroupName is the name of a device group,
+// "projects/{project}/locations/{location}/registries/{registry}/groups/{group}".
+type GroupName struct {
+	Project  string
+	Location string
+	Registry string
+	Group    string
+}
+
+// ParseGroupName parses and validates a group name.
+func ParseGroupName(name string) (GroupName, error) {
+	m, err := groupPathTemplate.Match(name)
+	if err != nil {
+		return GroupName{}, invalidNameError(name, err)
+	}
+	n := GroupName{Project: m["project"], Location: m["location"], Registry: m["registry"], Group: m["group"]}
+	return n, n.Validate()
+}
+
+// String returns the name in the form used by the API.
+func (n GroupName) String() string {
+	s, _ := groupPathTemplate.Render(map[string]string{"project": n.Project, "location": n.Location, "registry": n.Registry, "group": n.Group})
+	return s
+}
+
+// Validate reports whether all IDs in the name are well-formed.
+func (n GroupName) Validate() error {
+	if err := n.Parent().Validate(); err != nil {
+		return err
+	}
+	if !resourceIDPattern.MatchString(n.Group) {
+		return invalidNameError(n.String(), fmt.Errorf("invalid group ID %q", n.Group))
+	}
+	return nil
+}
+
+// Parent returns the name of the registry of the group.
+func (n GroupName) Parent() RegistryName {
+	return RegistryName{Project: n.Project, Location: n.Location, Registry: n.Registry}
+}
+
+// validateResourceID checks the ID of a registry or device to be created.
+// Numeric IDs are assigned by the server and cannot be chosen.
+func validateResourceID(kind string, id string) error {
+	if !resourceIDPattern.MatchString(id) {
+		return fmt.Errorf("%w: invalid %s ID %q: must be 3 to 255 letters, digits or -._+~%% and start with a letter", ErrInvalidName, kind, id)
+	}
+	return nil
+}
+
+// The methods below are variants of the service methods that take typed
+// names, for use with names built from IDs rather than formatted by hand.
+
+// CreateIn is like Create with a typed parent.
+func (r *ProjectsLocationsRegistriesService) CreateIn(parent LocationName, deviceregistry *DeviceRegistry) *ProjectsLocationsRegistriesCreateCall {
+	return r.Create(parent.String(), deviceregistry)
+}
+
+// ListIn is like List with a typed parent.
+func (r *ProjectsLocationsRegistriesService) ListIn(parent LocationName) *ProjectsLocationsRegistriesListCall {
+	return r.List(parent.String())
+}
+
+// GetByName is like Get with a typed name.
+func (r *ProjectsLocationsRegistriesService) GetByName(name RegistryName) *ProjectsLocationsRegistriesGetCall {
+	return r.Get(name.String())
+}
+
+// PatchByName is like Patch with a typed name.
+func (r *ProjectsLocationsRegistriesService) PatchByName(name RegistryName, deviceregistry *DeviceRegistry) *ProjectsLocationsRegistriesPatchCall {
+	return r.Patch(name.String(), deviceregistry)
+}
+
+// DeleteByName is like Delete with a typed name.
+func (r *ProjectsLocationsRegistriesService) DeleteByName(name RegistryName) *ProjectsLocationsRegistriesDeleteCall {
+	return r.Delete(name.String())
+}
+
+// BindDeviceToGatewayIn is like BindDeviceToGateway with a typed parent.
+func (r *ProjectsLocationsRegistriesService) BindDeviceToGatewayIn(parent RegistryName, binddevicetogatewayrequest *BindDeviceToGatewayRequest) *ProjectsLocationsRegistriesBindDeviceToGatewayCall {
+	return r.BindDeviceToGateway(parent.String(), binddevicetogatewayrequest)
+}
+
+// UnbindDeviceFromGatewayIn is like UnbindDeviceFromGateway with a typed
+// parent.
+func (r *ProjectsLocationsRegistriesService) UnbindDeviceFromGatewayIn(parent RegistryName, unbinddevicefromgatewayrequest *UnbindDeviceFromGatewayRequest) *ProjectsLocationsRegistriesUnbindDeviceFromGatewayCall {
+	return r.UnbindDeviceFromGateway(parent.String(), unbinddevicefromgatewayrequest)
+}
+
+// CreateIn is like Create with a typed parent.
+func (r *ProjectsLocationsRegistriesDevicesService) CreateIn(parent RegistryName, device *Device) *ProjectsLocationsRegistriesDevicesCreateCall {
+	return r.Create(parent.String(), device)
+}
+
+// ListIn is like List with a typed parent.
+func (r *ProjectsLocationsRegistriesDevicesService) ListIn(parent RegistryName) *ProjectsLocationsRegistriesDevicesListCall {
+	return r.List(parent.String())
+}
+
+// GetByName is like Get with a typed name.
+func (r *ProjectsLocationsRegistriesDevicesService) GetByName(name DeviceName) *ProjectsLocationsRegistriesDevicesGetCall {
+	return r.Get(name.String())
+}
+
+// PatchByName is like Patch with a typed name.
+func (r *ProjectsLocationsRegistriesDevicesService) PatchByName(name DeviceName, device *Device) *ProjectsLocationsRegistriesDevicesPatchCall {
+	return r.Patch(name.String(), device)
+}
+
+// DeleteByName is like Delete with a typed name.
+func (r *ProjectsLocationsRegistriesDevicesService) DeleteByName(name DeviceName) *ProjectsLocationsRegistriesDevicesDeleteCall {
+	return r.Delete(name.String())
+}
+
+// ModifyCloudToDeviceConfigByName is like ModifyCloudToDeviceConfig with a
+// typed name.
+func (r *ProjectsLocationsRegistriesDevicesService) ModifyCloudToDeviceConfigByName(name DeviceName, modifycloudtodeviceconfigrequest *ModifyCloudToDeviceConfigRequest) *ProjectsLocationsRegistriesDevicesModifyCloudToDeviceConfigCall {
+	return r.ModifyCloudToDeviceConfig(name.String(), modifycloudtodeviceconfigrequest)
+}
+
+// SendCommandToDeviceByName is like SendCommandToDevice with a typed name.
+func (r *ProjectsLocationsRegistriesDevicesService) SendCommandToDeviceByName(name DeviceName, sendcommandtodevicerequest *SendCommandToDeviceRequest) *ProjectsLocationsRegistriesDevicesSendCommandToDeviceCall {
+	return r.SendCommandToDevice(name.String(), sendcommandtodevicerequest)
+}
+
+// ListByName is like List with a typed device name.
+func (r *ProjectsLocationsRegistriesDevicesConfigVersionsService) ListByName(name DeviceName) *ProjectsLocationsRegistriesDevicesConfigVersionsListCall {
+	return r.List(name.String())
+}
+
+// ListByName is like List with a typed device name.
+func (r *ProjectsLocationsRegistriesDevicesStatesService) ListByName(name DeviceName) *ProjectsLocationsRegistriesDevicesStatesListCall {
+	return r.List(name.String())
+}
+
+// ListIn is like List with a typed group name.
+func (r *ProjectsLocationsRegistriesGroupsDevicesService) ListIn(parent GroupName) *ProjectsLocationsRegistriesGroupsDevicesListCall {
+	return r.List(parent.String())
+}
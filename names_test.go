@@ -0,0 +1,39 @@
+package iot
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseNames(t *testing.T) {
+	device, err := ParseDeviceName("projects/test-project/locations/us-central1/registries/registry/devices/device-1")
+	if err != nil {
+		t.Fatalf("Failed to parse device name: %s", err.Error())
+	}
+	if device.Device != "device-1" || device.Parent().String() != "projects/test-project/locations/us-central1/registries/registry" {
+		t.Errorf("Expected device-1 in registry but got: %+v", device)
+	}
+	if got := device.Parent().Parent().Registry("other").Device("123456").String(); got != "projects/test-project/locations/us-central1/registries/other/devices/123456" {
+		t.Errorf("Expected a built numeric device name but got: %s", got)
+	}
+	if numeric, err := ParseDeviceName("projects/p1/locations/us-central1/registries/registry/devices/2820184307282310"); err != nil || !numeric.IsNumeric() {
+		t.Errorf("Expected a valid numeric device name but got: %+v, %v", numeric, err)
+	}
+	if group, err := ParseGroupName("projects/p1/locations/europe-west1/registries/registry/groups/group"); err != nil || group.Parent().Registry != "registry" {
+		t.Errorf("Expected a valid group name but got: %+v, %v", group, err)
+	}
+
+	for _, name := range []string{
+		"projects/p1/locations/us-central1/registries/registry",
+		"projects/p1/locations/us-central1/registries/registry/devices/",
+		"projects/p1/locations/us-central1/registries/r/devices/device",
+		"projects/p1/locations/us-central1/registries/registry/devices/1device",
+		"projects/p1/locations/us-central1/registries/registry/devices/dev ice",
+		"projects/p1/locations/US/registries/registry/devices/device",
+		"projects//locations/us-central1/registries/registry/devices/device",
+	} {
+		if _, err := ParseDeviceName(name); !errors.Is(err, ErrInvalidName) {
+			t.Errorf("Expected %q to be an invalid device name but got: %v", name, err)
+		}
+	}
+}
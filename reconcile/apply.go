@@ -0,0 +1,87 @@
+// Copyright 2023 ClearBlade Inc.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package reconcile
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	iot "github.com/clearblade/go-iot"
+)
+
+// Apply makes the changes of a plan in order. It stops at the first
+// failure; the changes before it have been made, and computing a new plan
+// picks up from there.
+func Apply(ctx context.Context, service *iot.Service, plan *Plan) error {
+	for _, c := range plan.Changes {
+		if err := apply(ctx, service, c); err != nil {
+			return fmt.Errorf("reconcile: %s: %w", strings.SplitN(c.String(), "\n", 2)[0], err)
+		}
+	}
+	return nil
+}
+
+func apply(ctx context.Context, service *iot.Service, c *Change) error {
+	registries := service.Projects.Locations.Registries
+	devices := registries.Devices
+	var err error
+	switch {
+	case c.Kind == "registry" && c.Action == Create:
+		_, err = registries.CreateIn(c.registryName.Parent(), c.registry).Context(ctx).Do()
+	case c.Kind == "registry" && c.Action == Update:
+		r := *c.registry
+		r.Id = ""
+		r.NullFields = emptyFields(c.Fields, map[string]bool{
+			"eventNotificationConfigs": len(r.EventNotificationConfigs) == 0,
+			"credentials":              len(r.Credentials) == 0,
+		})
+		_, err = registries.PatchByName(c.registryName, &r).UpdateMask(updateMask(c.Fields)).Context(ctx).Do()
+	case c.Kind == "registry" && c.Action == Delete:
+		_, err = registries.DeleteByName(c.registryName).Context(ctx).Do()
+	case c.Action == Create:
+		_, err = devices.CreateIn(c.registryName, c.device).Context(ctx).Do()
+	case c.Action == Update:
+		d := *c.device
+		d.NullFields = emptyFields(c.Fields, map[string]bool{
+			"metadata":    len(d.Metadata) == 0,
+			"credentials": len(d.Credentials) == 0,
+		})
+		_, err = devices.PatchByName(c.registryName.Device(c.deviceID), &d).UpdateMask(updateMask(c.Fields)).Context(ctx).Do()
+	case c.Action == Delete:
+		_, err = devices.DeleteByName(c.registryName.Device(c.deviceID)).Context(ctx).Do()
+	case c.Action == Bind:
+		_, err = registries.BindDeviceToGatewayIn(c.registryName, &iot.BindDeviceToGatewayRequest{
+			DeviceId:  c.deviceID,
+			GatewayId: c.Gateway,
+		}).Context(ctx).Do()
+	case c.Action == Unbind:
+		_, err = registries.UnbindDeviceFromGatewayIn(c.registryName, &iot.UnbindDeviceFromGatewayRequest{
+			DeviceId:  c.deviceID,
+			GatewayId: c.Gateway,
+		}).Context(ctx).Do()
+	}
+	return err
+}
+
+func updateMask(fields []FieldChange) string {
+	paths := make([]string, len(fields))
+	for i, f := range fields {
+		paths[i] = f.Path
+	}
+	return strings.Join(paths, ",")
+}
+
+// emptyFields returns the Go names of the updated fields that are cleared,
+// so that they are sent as null rather than left out of the request.
+func emptyFields(fields []FieldChange, empty map[string]bool) []string {
+	var names []string
+	for _, f := range fields {
+		if empty[f.Path] {
+			names = append(names, strings.ToUpper(f.Path[:1])+f.Path[1:])
+		}
+	}
+	return names
+}
@@ -0,0 +1,159 @@
+// Copyright 2023 ClearBlade Inc.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package reconcile reconciles the registries and devices of a location with a
+// declarative manifest. A plan lists the changes needed to make the live
+// state match the manifest, and can be reviewed before it is applied:
+//
+//	manifest, err := reconcile.LoadManifest("fleet.yaml")
+//	...
+//	plan, err := reconcile.ComputePlan(ctx, service, manifest, reconcile.Options{})
+//	...
+//	fmt.Print(plan)
+//	err = reconcile.Apply(ctx, service, plan)
+//
+// Manifests are YAML or JSON documents using the field names of the API:
+//
+//	project: my-project
+//	location: us-central1
+//	registries:
+//	- id: my-registry
+//	  mqttConfig: {mqttEnabledState: MQTT_ENABLED}
+//	  devices:
+//	  - id: my-gateway
+//	    gatewayConfig: {gatewayType: GATEWAY, gatewayAuthMethod: ASSOCIATION_ONLY}
+//	  - id: my-device
+//	    metadata: {site: berlin}
+//	    gateways: [my-gateway]
+//
+// Fields left out of a manifest are not managed: they are set to the server
+// defaults when a resource is created, and left unchanged afterwards.
+package reconcile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	iot "github.com/clearblade/go-iot"
+	"gopkg.in/yaml.v3"
+)
+
+// Manifest is the desired state of the registries of a location.
+type Manifest struct {
+	// Project and Location are the location the registries are in.
+	Project  string `json:"project"`
+	Location string `json:"location"`
+
+	Registries []*Registry `json:"registries,omitempty"`
+}
+
+// Registry is the desired state of a registry and its devices.
+type Registry struct {
+	ID                       string                         `json:"id"`
+	EventNotificationConfigs []*iot.EventNotificationConfig `json:"eventNotificationConfigs,omitempty"`
+	StateNotificationConfig  *iot.StateNotificationConfig   `json:"stateNotificationConfig,omitempty"`
+	MqttConfig               *iot.MqttConfig                `json:"mqttConfig,omitempty"`
+	HttpConfig               *iot.HttpConfig                `json:"httpConfig,omitempty"`
+	LogLevel                 string                         `json:"logLevel,omitempty"`
+	// Credentials are the CA certificates of the registry.
+	Credentials []*iot.RegistryCredential `json:"credentials,omitempty"`
+
+	Devices []*Device `json:"devices,omitempty"`
+}
+
+// Device is the desired state of a device.
+type Device struct {
+	ID            string                  `json:"id"`
+	Metadata      map[string]string       `json:"metadata,omitempty"`
+	Credentials   []*iot.DeviceCredential `json:"credentials,omitempty"`
+	Blocked       *bool                   `json:"blocked,omitempty"`
+	LogLevel      string                  `json:"logLevel,omitempty"`
+	GatewayConfig *iot.GatewayConfig      `json:"gatewayConfig,omitempty"`
+
+	// Gateways are the IDs of the gateways the device is bound to. They
+	// must be gateways of the same registry in the manifest. If nil, the
+	// bindings of the device are not managed; an empty list unbinds it
+	// from all gateways.
+	Gateways []string `json:"gateways"`
+}
+
+// LoadManifest reads a YAML or JSON manifest from a file.
+func LoadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseManifest(data)
+}
+
+// ParseManifest parses a YAML or JSON manifest and validates it.
+func ParseManifest(data []byte) (*Manifest, error) {
+	// YAML is converted to JSON first, so that the JSON field names and
+	// types of the API apply to both.
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("reconcile: invalid manifest: %w", err)
+	}
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("reconcile: invalid manifest: %w", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	var m Manifest
+	if err := dec.Decode(&m); err != nil {
+		return nil, fmt.Errorf("reconcile: invalid manifest: %w", err)
+	}
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// Validate checks the names and references of a manifest.
+func (m *Manifest) Validate() error {
+	parent := m.parent()
+	if err := parent.Validate(); err != nil {
+		return fmt.Errorf("reconcile: %w", err)
+	}
+	registries := make(map[string]bool)
+	for _, r := range m.Registries {
+		if err := parent.Registry(r.ID).Validate(); err != nil {
+			return fmt.Errorf("reconcile: %w", err)
+		}
+		if registries[r.ID] {
+			return fmt.Errorf("reconcile: registry %s is declared twice", r.ID)
+		}
+		registries[r.ID] = true
+
+		devices := make(map[string]*Device)
+		for _, d := range r.Devices {
+			if err := parent.Registry(r.ID).Device(d.ID).Validate(); err != nil {
+				return fmt.Errorf("reconcile: %w", err)
+			}
+			if devices[d.ID] != nil {
+				return fmt.Errorf("reconcile: device %s of registry %s is declared twice", d.ID, r.ID)
+			}
+			devices[d.ID] = d
+		}
+		for _, d := range r.Devices {
+			for _, gw := range d.Gateways {
+				if g := devices[gw]; g == nil || !isGateway(g.GatewayConfig) {
+					return fmt.Errorf("reconcile: device %s is bound to %s, which is not a gateway of registry %s", d.ID, gw, r.ID)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (m *Manifest) parent() iot.LocationName {
+	return iot.LocationName{Project: m.Project, Location: m.Location}
+}
+
+func isGateway(c *iot.GatewayConfig) bool {
+	return c != nil && c.GatewayType == "GATEWAY"
+}
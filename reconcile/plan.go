@@ -0,0 +1,457 @@
+// Copyright 2023 ClearBlade Inc.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package reconcile
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	iot "github.com/clearblade/go-iot"
+)
+
+// deviceFields are the device fields a plan compares.
+const deviceFields = "metadata,credentials,blocked,logLevel,gatewayConfig"
+
+// Action is the kind of a change.
+type Action string
+
+const (
+	Create Action = "create"
+	Update Action = "update"
+	Delete Action = "delete"
+	Bind   Action = "bind"
+	Unbind Action = "unbind"
+)
+
+// Change is a single call needed to reconcile the live state with a
+// manifest.
+type Change struct {
+	Action Action
+	// Kind is "registry" or "device". Bindings are device changes.
+	Kind string
+	// Name is the name of the registry or device.
+	Name string
+	// Gateway is the ID of the gateway of a Bind or Unbind change.
+	Gateway string
+	// Fields are the fields changed by an Update, in update mask order.
+	Fields []FieldChange
+
+	registryName iot.RegistryName
+	deviceID     string
+	registry     *iot.DeviceRegistry
+	device       *iot.Device
+}
+
+// FieldChange is a field changed by an update. Old and New summarize the
+// values.
+type FieldChange struct {
+	// Path is the update mask path of the field.
+	Path     string
+	Old, New string
+}
+
+func (c *Change) String() string {
+	switch c.Action {
+	case Bind:
+		return fmt.Sprintf("+ binding %s to gateway %s", c.Name, c.Gateway)
+	case Unbind:
+		return fmt.Sprintf("- binding %s to gateway %s", c.Name, c.Gateway)
+	case Create:
+		return fmt.Sprintf("+ %s %s", c.Kind, c.Name)
+	case Delete:
+		return fmt.Sprintf("- %s %s", c.Kind, c.Name)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "~ %s %s", c.Kind, c.Name)
+	for _, f := range c.Fields {
+		fmt.Fprintf(&b, "\n    ~ %s: %s => %s", f.Path, f.Old, f.New)
+	}
+	return b.String()
+}
+
+// Plan is the list of changes that reconcile the live state with a
+// manifest, in the order they are applied.
+type Plan struct {
+	Changes []*Change
+}
+
+// Empty reports whether the live state already matches the manifest.
+func (p *Plan) Empty() bool {
+	return len(p.Changes) == 0
+}
+
+// String formats the plan for review, one change per line followed by a
+// summary.
+func (p *Plan) String() string {
+	if p.Empty() {
+		return "No changes. The live state matches the manifest.\n"
+	}
+	var b strings.Builder
+	var add, change, destroy int
+	for _, c := range p.Changes {
+		b.WriteString(c.String())
+		b.WriteByte('\n')
+		switch c.Action {
+		case Create, Bind:
+			add++
+		case Update:
+			change++
+		case Delete, Unbind:
+			destroy++
+		}
+	}
+	fmt.Fprintf(&b, "\nPlan: %d to add, %d to change, %d to destroy.\n", add, change, destroy)
+	return b.String()
+}
+
+// Options configures ComputePlan.
+type Options struct {
+	// Prune deletes the registries of the location and the devices of the
+	// managed registries that are not in the manifest. Without it, they are
+	// left alone.
+	Prune bool
+}
+
+// planner collects the changes of a plan by phase, so that they can be
+// applied in dependency order.
+type planner struct {
+	ctx     context.Context
+	service *iot.Service
+
+	registries []*Change // creates and updates
+	devices    []*Change // creates and updates
+	unbinds    []*Change
+	binds      []*Change
+	deletes    []*Change // devices
+	regDeletes []*Change
+	unbound    map[string]bool // keyed by device name and gateway ID
+}
+
+// ComputePlan compares the manifest with the live state of its location
+// and returns the changes needed to reconcile them.
+func ComputePlan(ctx context.Context, service *iot.Service, m *Manifest, opts Options) (*Plan, error) {
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	p := &planner{ctx: ctx, service: service, unbound: make(map[string]bool)}
+	parent := m.parent()
+
+	live := make(map[string]*iot.DeviceRegistry)
+	for r, err := range service.Projects.Locations.Registries.ListIn(parent).All(ctx) {
+		if err != nil {
+			return nil, err
+		}
+		live[r.Id] = r
+	}
+	declared := make(map[string]bool)
+	for _, spec := range m.Registries {
+		declared[spec.ID] = true
+		if err := p.registry(parent.Registry(spec.ID), spec, live[spec.ID], opts); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Prune {
+		ids := make([]string, 0, len(live))
+		for id := range live {
+			if !declared[id] {
+				ids = append(ids, id)
+			}
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			name := parent.Registry(id)
+			for d, err := range service.Projects.Locations.Registries.Devices.ListIn(name).FieldMask("gatewayConfig").All(ctx) {
+				if err != nil {
+					return nil, err
+				}
+				if err := p.deleteDevice(name, d); err != nil {
+					return nil, err
+				}
+			}
+			p.regDeletes = append(p.regDeletes, &Change{Action: Delete, Kind: "registry", Name: name.String(), registryName: name})
+		}
+	}
+
+	var changes []*Change
+	for _, phase := range [][]*Change{p.registries, p.devices, p.unbinds, p.binds, p.deletes, p.regDeletes} {
+		changes = append(changes, phase...)
+	}
+	return &Plan{Changes: changes}, nil
+}
+
+func (p *planner) registry(name iot.RegistryName, spec *Registry, live *iot.DeviceRegistry, opts Options) error {
+	desired := &iot.DeviceRegistry{
+		Id:                       spec.ID,
+		EventNotificationConfigs: spec.EventNotificationConfigs,
+		StateNotificationConfig:  spec.StateNotificationConfig,
+		MqttConfig:               spec.MqttConfig,
+		HttpConfig:               spec.HttpConfig,
+		LogLevel:                 spec.LogLevel,
+		Credentials:              spec.Credentials,
+	}
+	if live == nil {
+		p.registries = append(p.registries, &Change{Action: Create, Kind: "registry", Name: name.String(), registryName: name, registry: desired})
+		for _, d := range spec.Devices {
+			p.createDevice(name, d)
+		}
+		return nil
+	}
+
+	var fields []FieldChange
+	diff := func(path string, managed bool, old, new interface{}) {
+		if managed {
+			if o, n := summarize(old), summarize(new); o != n {
+				fields = append(fields, FieldChange{Path: path, Old: o, New: n})
+			}
+		}
+	}
+	diff("eventNotificationConfigs", spec.EventNotificationConfigs != nil, live.EventNotificationConfigs, spec.EventNotificationConfigs)
+	if spec.StateNotificationConfig != nil {
+		diff("stateNotificationConfig.pubsubTopicName", true, stateTopic(live.StateNotificationConfig), spec.StateNotificationConfig.PubsubTopicName)
+	}
+	if spec.MqttConfig != nil {
+		diff("mqttConfig.mqttEnabledState", true, mqttState(live.MqttConfig), spec.MqttConfig.MqttEnabledState)
+	}
+	if spec.HttpConfig != nil {
+		diff("httpConfig.httpEnabledState", true, httpState(live.HttpConfig), spec.HttpConfig.HttpEnabledState)
+	}
+	diff("logLevel", spec.LogLevel != "", live.LogLevel, spec.LogLevel)
+	diff("credentials", spec.Credentials != nil, registryCredentials(live.Credentials), registryCredentials(spec.Credentials))
+	if len(fields) > 0 {
+		p.registries = append(p.registries, &Change{Action: Update, Kind: "registry", Name: name.String(), Fields: fields, registryName: name, registry: desired})
+	}
+
+	liveDevices := make(map[string]*iot.Device)
+	for d, err := range p.service.Projects.Locations.Registries.Devices.ListIn(name).FieldMask(deviceFields).All(p.ctx) {
+		if err != nil {
+			return err
+		}
+		liveDevices[d.Id] = d
+	}
+	declared := make(map[string]bool)
+	for _, d := range spec.Devices {
+		declared[d.ID] = true
+		if err := p.device(name, d, liveDevices[d.ID]); err != nil {
+			return err
+		}
+	}
+	if opts.Prune {
+		ids := make([]string, 0, len(liveDevices))
+		for id := range liveDevices {
+			if !declared[id] {
+				ids = append(ids, id)
+			}
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			if err := p.deleteDevice(name, liveDevices[id]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (p *planner) createDevice(registry iot.RegistryName, spec *Device) {
+	d := &iot.Device{
+		Id:            spec.ID,
+		Metadata:      spec.Metadata,
+		Credentials:   spec.Credentials,
+		LogLevel:      spec.LogLevel,
+		GatewayConfig: spec.GatewayConfig,
+	}
+	if spec.Blocked != nil {
+		d.Blocked = *spec.Blocked
+	}
+	name := registry.Device(spec.ID)
+	p.devices = append(p.devices, &Change{Action: Create, Kind: "device", Name: name.String(), registryName: registry, deviceID: spec.ID, device: d})
+	for _, gw := range spec.Gateways {
+		p.bind(name, gw)
+	}
+}
+
+func (p *planner) device(registry iot.RegistryName, spec *Device, live *iot.Device) error {
+	if live == nil {
+		p.createDevice(registry, spec)
+		return nil
+	}
+	name := registry.Device(spec.ID)
+	if spec.GatewayConfig != nil && isGateway(spec.GatewayConfig) != isGateway(live.GatewayConfig) {
+		return fmt.Errorf("reconcile: the gateway type of device %s cannot be changed; delete it first", name)
+	}
+
+	desired := &iot.Device{Metadata: spec.Metadata, Credentials: spec.Credentials, LogLevel: spec.LogLevel, GatewayConfig: spec.GatewayConfig}
+	var fields []FieldChange
+	diff := func(path string, managed bool, old, new interface{}) {
+		if managed {
+			if o, n := summarize(old), summarize(new); o != n {
+				fields = append(fields, FieldChange{Path: path, Old: o, New: n})
+			}
+		}
+	}
+	diff("metadata", spec.Metadata != nil, live.Metadata, spec.Metadata)
+	diff("credentials", spec.Credentials != nil, deviceCredentials(live.Credentials), deviceCredentials(spec.Credentials))
+	if spec.Blocked != nil {
+		desired.Blocked = *spec.Blocked
+		diff("blocked", true, live.Blocked, *spec.Blocked)
+	}
+	diff("logLevel", spec.LogLevel != "", live.LogLevel, spec.LogLevel)
+	if spec.GatewayConfig != nil {
+		diff("gatewayConfig.gatewayAuthMethod", true, gatewayAuthMethod(live.GatewayConfig), spec.GatewayConfig.GatewayAuthMethod)
+	}
+	if len(fields) > 0 {
+		p.devices = append(p.devices, &Change{Action: Update, Kind: "device", Name: name.String(), Fields: fields, registryName: registry, deviceID: spec.ID, device: desired})
+	}
+
+	if spec.Gateways == nil {
+		return nil
+	}
+	bound := make(map[string]bool)
+	gateways := p.service.Projects.Locations.Registries.Devices.ListIn(registry).GatewayListOptionsAssociationsDeviceId(spec.ID)
+	for gw, err := range gateways.All(p.ctx) {
+		if err != nil {
+			return err
+		}
+		bound[gw.Id] = true
+	}
+	wanted := make(map[string]bool)
+	for _, gw := range spec.Gateways {
+		wanted[gw] = true
+		if !bound[gw] {
+			p.bind(name, gw)
+		}
+	}
+	for _, gw := range sortedKeys(bound) {
+		if !wanted[gw] {
+			p.unbind(name, gw)
+		}
+	}
+	return nil
+}
+
+// deleteDevice plans the deletion of a device, after unbinding it from its
+// gateways, or its devices if it is a gateway.
+func (p *planner) deleteDevice(registry iot.RegistryName, d *iot.Device) error {
+	name := registry.Device(d.Id)
+	list := p.service.Projects.Locations.Registries.Devices.ListIn(registry)
+	if isGateway(d.GatewayConfig) {
+		list.GatewayListOptionsAssociationsGatewayId(d.Id)
+	} else {
+		list.GatewayListOptionsAssociationsDeviceId(d.Id)
+	}
+	for other, err := range list.All(p.ctx) {
+		if err != nil {
+			return err
+		}
+		if isGateway(d.GatewayConfig) {
+			p.unbind(registry.Device(other.Id), d.Id)
+		} else {
+			p.unbind(name, other.Id)
+		}
+	}
+	p.deletes = append(p.deletes, &Change{Action: Delete, Kind: "device", Name: name.String(), registryName: registry, deviceID: d.Id})
+	return nil
+}
+
+func (p *planner) bind(device iot.DeviceName, gateway string) {
+	p.binds = append(p.binds, &Change{Action: Bind, Kind: "device", Name: device.String(), Gateway: gateway, registryName: device.Parent(), deviceID: device.Device})
+}
+
+func (p *planner) unbind(device iot.DeviceName, gateway string) {
+	key := device.String() + " " + gateway
+	if !p.unbound[key] {
+		p.unbound[key] = true
+		p.unbinds = append(p.unbinds, &Change{Action: Unbind, Kind: "device", Name: device.String(), Gateway: gateway, registryName: device.Parent(), deviceID: device.Device})
+	}
+}
+
+// summarize returns a short representation of a field value for a plan.
+// Empty values of any type are represented the same way.
+func summarize(v interface{}) string {
+	b, _ := json.Marshal(v)
+	switch s := string(b); s {
+	case "null", `""`, "[]", "{}", "false":
+		return "(none)"
+	default:
+		return s
+	}
+}
+
+// registryCredentials returns the certificates of credentials, without the
+// details reported by the server.
+func registryCredentials(creds []*iot.RegistryCredential) []string {
+	var certs []string
+	for _, c := range creds {
+		if c != nil && c.PublicKeyCertificate != nil {
+			certs = append(certs, "X509 "+fingerprint(c.PublicKeyCertificate.Certificate))
+		}
+	}
+	return certs
+}
+
+// deviceCredentials returns the formats, fingerprints and expiration times
+// of credentials.
+func deviceCredentials(creds []*iot.DeviceCredential) []string {
+	var keys []string
+	for _, c := range creds {
+		if c == nil || c.PublicKey == nil {
+			continue
+		}
+		s := c.PublicKey.Format + " " + fingerprint(c.PublicKey.Key)
+		if t, err := time.Parse(time.RFC3339Nano, c.ExpirationTime); err == nil && t.Unix() > 0 {
+			s += " until " + t.UTC().Format(time.RFC3339)
+		}
+		keys = append(keys, s)
+	}
+	return keys
+}
+
+func fingerprint(pem string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(pem)))
+	return "sha256:" + hex.EncodeToString(sum[:6])
+}
+
+func stateTopic(c *iot.StateNotificationConfig) string {
+	if c == nil {
+		return ""
+	}
+	return c.PubsubTopicName
+}
+
+func mqttState(c *iot.MqttConfig) string {
+	if c == nil {
+		return ""
+	}
+	return c.MqttEnabledState
+}
+
+func httpState(c *iot.HttpConfig) string {
+	if c == nil {
+		return ""
+	}
+	return c.HttpEnabledState
+}
+
+func gatewayAuthMethod(c *iot.GatewayConfig) string {
+	if c == nil {
+		return ""
+	}
+	return c.GatewayAuthMethod
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
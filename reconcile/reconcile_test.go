@@ -0,0 +1,91 @@
+package reconcile_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	iot "github.com/clearblade/go-iot"
+	"github.com/clearblade/go-iot/iottest"
+	"github.com/clearblade/go-iot/reconcile"
+)
+
+const manifest = `
+project: test-project
+location: us-central1
+registries:
+- id: registry
+  logLevel: INFO
+  devices:
+  - id: gateway
+    gatewayConfig: {gatewayType: GATEWAY, gatewayAuthMethod: ASSOCIATION_ONLY}
+  - id: device
+    metadata: {site: berlin}
+    gateways: [gateway]
+`
+
+func TestPlanAndApply(t *testing.T) {
+	_, service := iottest.NewService(t, "test-project")
+	ctx := context.Background()
+	parent := iot.LocationName{Project: "test-project", Location: "us-central1"}
+	if _, err := service.Projects.Locations.Registries.CreateIn(parent, &iot.DeviceRegistry{Id: "unmanaged"}).Do(); err != nil {
+		t.Fatalf("Failed to create registry: %s", err.Error())
+	}
+
+	apply := func(doc string, opts reconcile.Options) *reconcile.Plan {
+		t.Helper()
+		m, err := reconcile.ParseManifest([]byte(doc))
+		if err != nil {
+			t.Fatalf("Failed to parse manifest: %s", err.Error())
+		}
+		plan, err := reconcile.ComputePlan(ctx, service, m, opts)
+		if err != nil {
+			t.Fatalf("Failed to compute plan: %s", err.Error())
+		}
+		if err := reconcile.Apply(ctx, service, plan); err != nil {
+			t.Fatalf("Failed to apply plan: %s", err.Error())
+		}
+		again, err := reconcile.ComputePlan(ctx, service, m, opts)
+		if err != nil {
+			t.Fatalf("Failed to compute plan: %s", err.Error())
+		}
+		if !again.Empty() {
+			t.Errorf("Expected no changes after applying but got:\n%s", again)
+		}
+		return plan
+	}
+
+	plan := apply(manifest, reconcile.Options{})
+	if !strings.HasSuffix(plan.String(), "Plan: 4 to add, 0 to change, 0 to destroy.\n") {
+		t.Errorf("Expected 4 additions but got:\n%s", plan)
+	}
+
+	// Changing the manifest updates only the changed fields, and bindings
+	// follow the gateways list.
+	changed := strings.Replace(manifest, "site: berlin", "site: paris", 1)
+	changed = strings.Replace(changed, "gateways: [gateway]", "gateways: []", 1)
+	plan = apply(changed, reconcile.Options{})
+	if len(plan.Changes) != 2 || plan.Changes[0].Fields[0].Path != "metadata" || plan.Changes[1].Action != reconcile.Unbind {
+		t.Errorf("Expected a metadata update and an unbinding but got:\n%s", plan)
+	}
+	device, err := service.Projects.Locations.Registries.Devices.GetByName(parent.Registry("registry").Device("device")).Do()
+	if err != nil {
+		t.Fatalf("Failed to get device: %s", err.Error())
+	}
+	if device.Metadata["site"] != "paris" {
+		t.Errorf("Expected metadata to be updated but got: %v", device.Metadata)
+	}
+
+	// Pruning deletes what is not in the manifest.
+	if _, err := service.Projects.Locations.Registries.Devices.CreateIn(parent.Registry("registry"), &iot.Device{Id: "extra"}).Do(); err != nil {
+		t.Fatalf("Failed to create device: %s", err.Error())
+	}
+	plan = apply(changed, reconcile.Options{Prune: true})
+	if len(plan.Changes) != 2 || plan.Changes[0].Name != parent.Registry("registry").Device("extra").String() || plan.Changes[1].Name != parent.Registry("unmanaged").String() {
+		t.Errorf("Expected the extra device and unmanaged registry to be deleted but got:\n%s", plan)
+	}
+
+	if _, err := reconcile.ParseManifest([]byte(strings.Replace(manifest, "gateways: [gateway]", "gateways: [device]", 1))); err == nil {
+		t.Errorf("Expected a binding to a device that is not a gateway to be rejected")
+	}
+}
@@ -0,0 +1,85 @@
+package iot_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	iot "github.com/clearblade/go-iot"
+	"github.com/clearblade/go-iot/iottest"
+)
+
+func TestGetRegistryCredentialsSharesConcurrentFetches(t *testing.T) {
+	fake, setup := iottest.NewService(t, "test-project")
+	ctx := context.Background()
+	parent := "projects/test-project/locations/us-central1"
+	registries := []string{"registry-a", "registry-b", "registry-c", "registry-d"}
+	for _, id := range registries {
+		if _, err := setup.Projects.Locations.Registries.Create(parent, &iot.DeviceRegistry{Id: id}).Do(); err != nil {
+			t.Fatalf("Failed to create registry: %s", err.Error())
+		}
+		if _, err := setup.Projects.Locations.Registries.Devices.Create(parent+"/registries/"+id, &iot.Device{Id: "device"}).Do(); err != nil {
+			t.Fatalf("Failed to create device: %s", err.Error())
+		}
+	}
+
+	service, err := iot.NewService(ctx, iot.WithServiceAccountCredentials(fake.Credentials()))
+	if err != nil {
+		t.Fatalf("Failed to initialize service: %s", err.Error())
+	}
+	before := fake.RegistryCredentialsRequests()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 500)
+	for i := 0; i < 500; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			name := fmt.Sprintf("%s/registries/%s/devices/device", parent, registries[i%len(registries)])
+			if _, err := service.Projects.Locations.Registries.Devices.Get(name).Context(ctx).Do(); err != nil {
+				errs <- err
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("Failed to get device: %s", err.Error())
+	}
+
+	if got := fake.RegistryCredentialsRequests() - before; got != len(registries) {
+		t.Errorf("Expected %d getRegistryCredentials calls but got: %d", len(registries), got)
+	}
+}
+
+func TestRejectedRegistryCredentialsAreRefetched(t *testing.T) {
+	fake, service := iottest.NewService(t, "test-project")
+	parent := "projects/test-project/locations/us-central1"
+	registry := parent + "/registries/registry-a"
+	if _, err := service.Projects.Locations.Registries.Create(parent, &iot.DeviceRegistry{Id: "registry-a"}).Do(); err != nil {
+		t.Fatalf("Failed to create registry: %s", err.Error())
+	}
+	if _, err := service.Projects.Locations.Registries.Devices.Create(registry, &iot.Device{Id: "device"}).Do(); err != nil {
+		t.Fatalf("Failed to create device: %s", err.Error())
+	}
+	before := fake.RegistryCredentialsRequests()
+
+	if err := fake.RotateRegistryToken(registry); err != nil {
+		t.Fatalf("Failed to rotate token: %s", err.Error())
+	}
+	if _, err := service.Projects.Locations.Registries.Devices.Get(registry + "/devices/device").Do(); err != nil {
+		t.Fatalf("Failed to get device after token rotation: %s", err.Error())
+	}
+	if got := fake.RegistryCredentialsRequests() - before; got != 1 {
+		t.Errorf("Expected credentials to be fetched once but got: %d", got)
+	}
+
+	service.InvalidateRegistryCredentials("us-central1", "registry-a")
+	if _, err := service.Projects.Locations.Registries.Devices.Get(registry + "/devices/device").Do(); err != nil {
+		t.Fatalf("Failed to get device after invalidation: %s", err.Error())
+	}
+	if got := fake.RegistryCredentialsRequests() - before; got != 2 {
+		t.Errorf("Expected credentials to be fetched again after invalidation but got: %d", got)
+	}
+}
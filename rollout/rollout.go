@@ -0,0 +1,414 @@
+// Copyright 2023 ClearBlade Inc.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package rollout sends a new config to a fleet of devices in stages: a
+// canary first, then batches. Each stage has to be acknowledged by enough of
+// its devices, without too many of them reporting errors, before the next
+// one starts. Otherwise every device updated so far is rolled back to its
+// previous config version:
+//
+//	target := rollout.Target{Registry: registry, Metadata: map[string]string{"site": "berlin"}}
+//	result, err := rollout.Run(ctx, service, target, config, rollout.Options{
+//		CanaryPercent: 5,
+//		BatchSize:     100,
+//		MinAckRate:    0.95,
+//	})
+//	if errors.Is(err, rollout.ErrRolledBack) {
+//		...
+//	}
+package rollout
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	iot "github.com/clearblade/go-iot"
+)
+
+// ErrRolledBack is wrapped by the error Run returns when a stage failed and
+// the rollout was rolled back.
+var ErrRolledBack = errors.New("rollout: rolled back")
+
+const (
+	defaultCanaryPercent = 10
+	defaultAckTimeout    = 5 * time.Minute
+	defaultPollInterval  = 5 * time.Second
+)
+
+// Target selects the devices of a rollout. Devices have to match all of the
+// set fields.
+type Target struct {
+	Registry iot.RegistryName
+	// Metadata selects the devices that have all of these metadata values.
+	Metadata map[string]string
+	// Gateway selects the devices bound to the gateway with this ID.
+	Gateway string
+}
+
+// Options configures a rollout.
+type Options struct {
+	// CanaryPercent is the percentage of the devices updated in the first
+	// stage, rounded up to at least one device. The default is 10.
+	CanaryPercent float64
+	// BatchSize is the number of devices updated in each of the stages
+	// after the canary. By default, all remaining devices are updated in a
+	// single stage.
+	BatchSize int
+
+	// MinAckRate is the fraction of the devices of a stage, from 0 to 1,
+	// that have to acknowledge the config. The default is 1.
+	MinAckRate float64
+	// MaxErrorRate is the fraction of the devices of a stage, from 0 to 1,
+	// that may report an error after being sent the config. The default is
+	// 0: any error fails the stage.
+	MaxErrorRate float64
+
+	// AckTimeout is how long to wait for the devices of a stage to
+	// acknowledge the config. The default is 5 minutes.
+	AckTimeout time.Duration
+	// PollInterval is how often the devices of a stage are checked while
+	// waiting. The default is 5 seconds.
+	PollInterval time.Duration
+
+	// Bulk configures the requests that send configs to the devices of a
+	// stage and poll them.
+	Bulk *iot.BulkOptions
+
+	// Progress, if set, is called with every stage once it is decided.
+	Progress func(*Stage)
+}
+
+// Stage is a group of devices that is sent the config together.
+type Stage struct {
+	// Name is "canary" or "batch N".
+	Name string
+	// Devices are the IDs of the devices of the stage.
+	Devices []string
+	// Failed maps the IDs of the devices that could not be sent the config
+	// to the error. They count as not acknowledged.
+	Failed map[string]error
+	// Acked are the devices that acknowledged the config.
+	Acked []string
+	// Errored are the devices that reported an error, in their
+	// LastErrorStatus, after they were sent the config.
+	Errored []string
+
+	// configs are the configs sent to the devices, by ID.
+	configs map[string]*iot.DeviceConfig
+}
+
+// AckRate returns the fraction of the devices that acknowledged the config.
+func (s *Stage) AckRate() float64 {
+	return float64(len(s.Acked)) / float64(len(s.Devices))
+}
+
+// ErrorRate returns the fraction of the devices that reported an error.
+func (s *Stage) ErrorRate() float64 {
+	return float64(len(s.Errored)) / float64(len(s.Devices))
+}
+
+// Result is the outcome of a rollout.
+type Result struct {
+	// Stages are the stages that were started.
+	Stages []*Stage
+	// RolledBack reports whether the rollout was rolled back.
+	RolledBack bool
+	// Rollback is the report of the requests that restored the previous
+	// configs.
+	Rollback *iot.BulkReport
+}
+
+// Run sends data as the new config of the devices of target, in stages.
+//
+// If a stage fails, the devices of all stages so far are sent the config
+// version that preceded the rollout's in their config history, and the
+// returned error wraps ErrRolledBack. Devices whose config was changed by
+// someone else in the meantime are left alone, and fail in the Rollback
+// report.
+//
+// If ctx is done while a stage is in progress, the rollout stops without
+// rolling back and the context's error is returned.
+func Run(ctx context.Context, service *iot.Service, target Target, data []byte, opts Options) (*Result, error) {
+	if err := target.Registry.Validate(); err != nil {
+		return nil, err
+	}
+	ids, err := selectDevices(ctx, service, target)
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, errors.New("rollout: no devices match the target")
+	}
+
+	r := &runner{service: service, registry: target.Registry, opts: opts}
+	r.setDefaults()
+	result := &Result{}
+	for i, devices := range r.stages(ids) {
+		stage := &Stage{
+			Name:    "canary",
+			Devices: devices,
+			Failed:  map[string]error{},
+			configs: map[string]*iot.DeviceConfig{},
+		}
+		if i > 0 {
+			stage.Name = fmt.Sprintf("batch %d", i)
+		}
+		result.Stages = append(result.Stages, stage)
+
+		r.send(ctx, stage, data)
+		if err := r.wait(ctx, stage); err != nil {
+			return result, err
+		}
+		if r.opts.Progress != nil {
+			r.opts.Progress(stage)
+		}
+		if reason := r.check(stage); reason != "" {
+			result.RolledBack = true
+			report, err := r.rollback(ctx, result.Stages)
+			result.Rollback = report
+			err = errors.Join(fmt.Errorf("%w: stage %s: %s", ErrRolledBack, stage.Name, reason), err, report.Err())
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+type runner struct {
+	service  *iot.Service
+	registry iot.RegistryName
+	opts     Options
+}
+
+func (r *runner) setDefaults() {
+	if r.opts.CanaryPercent <= 0 {
+		r.opts.CanaryPercent = defaultCanaryPercent
+	}
+	if r.opts.MinAckRate <= 0 {
+		r.opts.MinAckRate = 1
+	}
+	if r.opts.AckTimeout <= 0 {
+		r.opts.AckTimeout = defaultAckTimeout
+	}
+	if r.opts.PollInterval <= 0 {
+		r.opts.PollInterval = defaultPollInterval
+	}
+}
+
+// selectDevices returns the IDs of the devices of target, in list order.
+func selectDevices(ctx context.Context, service *iot.Service, target Target) ([]string, error) {
+	call := service.Projects.Locations.Registries.Devices.ListIn(target.Registry)
+	if target.Gateway != "" {
+		call.GatewayListOptionsAssociationsGatewayId(target.Gateway)
+	}
+	if len(target.Metadata) > 0 {
+		call.FieldMask("metadata")
+	}
+	var ids []string
+	for d, err := range call.All(ctx) {
+		if err != nil {
+			return nil, fmt.Errorf("rollout: listing devices: %w", err)
+		}
+		if matches(d.Metadata, target.Metadata) {
+			ids = append(ids, d.Id)
+		}
+	}
+	return ids, nil
+}
+
+func matches(metadata, selector map[string]string) bool {
+	for k, v := range selector {
+		if value, ok := metadata[k]; !ok || value != v {
+			return false
+		}
+	}
+	return true
+}
+
+// stages splits ids into the canary and the batches.
+func (r *runner) stages(ids []string) [][]string {
+	canary := int(math.Ceil(float64(len(ids)) * r.opts.CanaryPercent / 100))
+	canary = max(1, min(canary, len(ids)))
+	stages := [][]string{ids[:canary]}
+	rest := ids[canary:]
+	size := r.opts.BatchSize
+	if size <= 0 {
+		size = len(rest)
+	}
+	for len(rest) > 0 {
+		n := min(size, len(rest))
+		stages = append(stages, rest[:n])
+		rest = rest[n:]
+	}
+	return stages
+}
+
+// send sends data to the devices of stage.
+func (r *runner) send(ctx context.Context, stage *Stage, data []byte) {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	updates := make([]*iot.ConfigUpdate, len(stage.Devices))
+	for i, id := range stage.Devices {
+		updates[i] = &iot.ConfigUpdate{DeviceID: id, Request: &iot.ModifyCloudToDeviceConfigRequest{BinaryData: encoded}}
+	}
+	report := r.service.Projects.Locations.Registries.Devices.BulkModifyConfig(ctx, r.registry, slices.Values(updates), r.opts.Bulk)
+	for _, res := range report.Results {
+		if res.Status == iot.BulkFailed {
+			stage.Failed[res.Name.Device] = res.Err
+		} else {
+			stage.configs[res.Name.Device] = res.Config
+		}
+	}
+	for _, id := range stage.Devices[len(report.Results):] {
+		stage.Failed[id] = ctx.Err()
+	}
+}
+
+// wait polls the devices of stage until each of them acknowledged the
+// config or reported an error, too many reported errors, or the ack timeout
+// passes. A device is no longer polled once it acknowledged the config or
+// reported an error.
+func (r *runner) wait(ctx context.Context, stage *Stage) error {
+	devices := r.service.Projects.Locations.Registries.Devices
+	acked, errored := map[string]bool{}, map[string]bool{}
+	deadline := time.Now().Add(r.opts.AckTimeout)
+	for {
+		var pending []string
+		for _, id := range stage.Devices {
+			if stage.configs[id] != nil && !acked[id] && !errored[id] {
+				pending = append(pending, id)
+			}
+		}
+		report := devices.BulkGetDevices(ctx, r.registry, slices.Values(pending), r.opts.Bulk)
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		for _, res := range report.Results {
+			if res.Status != iot.BulkSucceeded {
+				// The device is checked again on the next poll.
+				continue
+			}
+			id := res.Name.Device
+			isAcked, isErrored, err := r.status(ctx, res.Device, stage.configs[id])
+			if err != nil {
+				continue
+			}
+			acked[id], errored[id] = isAcked, isErrored
+		}
+
+		stage.Acked, stage.Errored = nil, nil
+		undecided := 0
+		for _, id := range stage.Devices {
+			if acked[id] {
+				stage.Acked = append(stage.Acked, id)
+			}
+			if errored[id] {
+				stage.Errored = append(stage.Errored, id)
+			}
+			if stage.configs[id] != nil && !acked[id] && !errored[id] {
+				undecided++
+			}
+		}
+		if undecided == 0 || len(stage.Errored) > r.maxErrors(stage) || !time.Now().Before(deadline) {
+			return nil
+		}
+		timer := time.NewTimer(min(r.opts.PollInterval, time.Until(deadline)))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// status reports whether device d acknowledged config and whether it
+// reported an error since config was sent.
+func (r *runner) status(ctx context.Context, d *iot.Device, config *iot.DeviceConfig) (acked, errored bool, err error) {
+	errored = d.LastErrorStatus != nil && !timeBefore(d.LastErrorTime, config.CloudUpdateTime)
+
+	ackTime, err := r.service.Projects.Locations.Registries.Devices.ConfigAckTime(ctx, r.registry.Device(d.Id), d, config.Version)
+	return !ackTime.IsZero(), errored, err
+}
+
+// timeBefore reports whether timestamp a is before b. Timestamps that cannot
+// be parsed are before any other.
+func timeBefore(a, b string) bool {
+	ta, err := time.Parse(time.RFC3339Nano, a)
+	if err != nil {
+		return true
+	}
+	tb, err := time.Parse(time.RFC3339Nano, b)
+	if err != nil {
+		return false
+	}
+	return ta.Before(tb)
+}
+
+// minAcks returns the number of devices of stage that have to acknowledge
+// the config.
+func (r *runner) minAcks(stage *Stage) int {
+	return int(math.Ceil(r.opts.MinAckRate*float64(len(stage.Devices)) - 1e-9))
+}
+
+// maxErrors returns the number of devices of stage that may report errors.
+func (r *runner) maxErrors(stage *Stage) int {
+	return int(math.Floor(r.opts.MaxErrorRate*float64(len(stage.Devices)) + 1e-9))
+}
+
+// check returns why stage failed, or "" if it succeeded.
+func (r *runner) check(stage *Stage) string {
+	n := len(stage.Devices)
+	if len(stage.Errored) > r.maxErrors(stage) {
+		return fmt.Sprintf("%d of %d devices reported errors", len(stage.Errored), n)
+	}
+	if len(stage.Acked) < r.minAcks(stage) {
+		return fmt.Sprintf("%d of %d devices acknowledged the config", len(stage.Acked), n)
+	}
+	return ""
+}
+
+// rollback sends the devices of stages the config version preceding the
+// one they were sent. The update is conditional on the device still having
+// that config.
+func (r *runner) rollback(ctx context.Context, stages []*Stage) (*iot.BulkReport, error) {
+	devices := r.service.Projects.Locations.Registries.Devices
+	var updates []*iot.ConfigUpdate
+	var errs []error
+	for _, stage := range stages {
+		for _, id := range stage.Devices {
+			config := stage.configs[id]
+			if config == nil {
+				continue
+			}
+			versions, err := devices.ConfigVersions.ListByName(r.registry.Device(id)).Context(ctx).Do()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("rollout: rolling back %s: %w", id, err))
+				continue
+			}
+			var previous *iot.DeviceConfig
+			for _, c := range versions.DeviceConfigs {
+				if c.Version < config.Version && (previous == nil || c.Version > previous.Version) {
+					previous = c
+				}
+			}
+			if previous == nil {
+				errs = append(errs, fmt.Errorf("rollout: rolling back %s: no config version before %d", id, config.Version))
+				continue
+			}
+			updates = append(updates, &iot.ConfigUpdate{
+				DeviceID: id,
+				Request: &iot.ModifyCloudToDeviceConfigRequest{
+					BinaryData:      previous.BinaryData,
+					VersionToUpdate: config.Version,
+				},
+			})
+		}
+	}
+	report := devices.BulkModifyConfig(ctx, r.registry, slices.Values(updates), r.opts.Bulk)
+	return report, errors.Join(errs...)
+}
@@ -0,0 +1,114 @@
+package rollout_test
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	iot "github.com/clearblade/go-iot"
+	"github.com/clearblade/go-iot/iottest"
+	"github.com/clearblade/go-iot/rollout"
+)
+
+// simulate acknowledges the configs of the devices until ctx is done, as
+// connected devices would. broken devices report an error instead.
+func simulate(ctx context.Context, fake *iottest.Server, service *iot.Service, registry iot.RegistryName, broken map[string]bool) {
+	for ctx.Err() == nil {
+		for d, err := range service.Projects.Locations.Registries.Devices.ListIn(registry).FieldMask("config").All(ctx) {
+			if err != nil || d.Config == nil || d.Config.DeviceAckTime != "" {
+				continue
+			}
+			if broken[d.Id] {
+				fake.ReportError(registry.Device(d.Id).String(), &iot.Status{Code: 3, Message: "invalid config"})
+			} else {
+				fake.AckConfig(registry.Device(d.Id).String(), d.Config.Version)
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestRun(t *testing.T) {
+	fake, service := iottest.NewService(t, "test-project")
+	ctx := context.Background()
+	registry := iot.LocationName{Project: "test-project", Location: "us-central1"}.Registry("registry")
+	iottest.CreateRegistry(t, service, registry)
+	for i := 0; i < 10; i++ {
+		site := "berlin"
+		if i >= 8 {
+			site = "paris"
+		}
+		device := &iot.Device{Id: fmt.Sprintf("device-%d", i), Metadata: map[string]string{"site": site}}
+		if _, err := service.Projects.Locations.Registries.Devices.CreateIn(registry, device).Do(); err != nil {
+			t.Fatalf("Failed to create device: %s", err.Error())
+		}
+	}
+	config := func(id string) (string, int64) {
+		d, err := service.Projects.Locations.Registries.Devices.GetByName(registry.Device(id)).Do()
+		if err != nil {
+			t.Fatalf("Failed to get device: %s", err.Error())
+		}
+		data, _ := base64.StdEncoding.DecodeString(d.Config.BinaryData)
+		return string(data), d.Config.Version
+	}
+
+	simCtx, stop := context.WithCancel(ctx)
+	go simulate(simCtx, fake, service, registry, map[string]bool{"device-1": true})
+	t.Cleanup(stop)
+
+	target := rollout.Target{Registry: registry, Metadata: map[string]string{"site": "berlin"}}
+	opts := rollout.Options{
+		CanaryPercent: 25,
+		BatchSize:     3,
+		MinAckRate:    0.5,
+		MaxErrorRate:  0.5,
+		AckTimeout:    5 * time.Second,
+		PollInterval:  10 * time.Millisecond,
+	}
+	var decided []string
+	opts.Progress = func(s *rollout.Stage) { decided = append(decided, s.Name) }
+	result, err := rollout.Run(ctx, service, target, []byte("v2"), opts)
+	if err != nil {
+		t.Fatalf("Failed to roll out: %s", err.Error())
+	}
+	if len(result.Stages) != 3 || len(result.Stages[0].Devices) != 2 || result.RolledBack {
+		t.Fatalf("Expected a canary of 2 and 2 batches but got: %d stages", len(result.Stages))
+	}
+	if fmt.Sprint(decided) != "[canary batch 1 batch 2]" {
+		t.Errorf("Expected progress for every stage but got: %v", decided)
+	}
+	if got := result.Stages[0].Errored; len(got) != 1 || got[0] != "device-1" {
+		t.Errorf("Expected device-1 to report an error but got: %v", got)
+	}
+	for i := 0; i < 10; i++ {
+		data, _ := config(fmt.Sprintf("device-%d", i))
+		if want := map[bool]string{true: "v2", false: ""}[i < 8]; data != want {
+			t.Errorf("Expected config %q on device-%d but got: %q", want, i, data)
+		}
+	}
+
+	// With no errors tolerated, the canary fails and is rolled back.
+	opts.MaxErrorRate = 0
+	result, err = rollout.Run(ctx, service, target, []byte("v3"), opts)
+	if !errors.Is(err, rollout.ErrRolledBack) || !result.RolledBack {
+		t.Fatalf("Expected the rollout to be rolled back but got: %v", err)
+	}
+	if !strings.HasPrefix(err.Error(), "rollout: rolled back: stage canary: 1 of 2 devices reported errors") {
+		t.Errorf("Expected the error to name the failed stage but got: %s", err.Error())
+	}
+	if len(result.Stages) != 1 || result.Rollback.Count(iot.BulkSucceeded) != 2 {
+		t.Fatalf("Expected the canary of 2 devices to be rolled back but got: %d stages", len(result.Stages))
+	}
+	for _, id := range []string{"device-0", "device-1"} {
+		if data, version := config(id); data != "v2" || version != 4 {
+			t.Errorf("Expected %s to be back on v2 as version 4 but got: %q version %d", id, data, version)
+		}
+	}
+	if data, version := config("device-2"); data != "v2" || version != 2 {
+		t.Errorf("Expected device-2 to be untouched but got: %q version %d", data, version)
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright 2023 ClearBlade Inc.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package iot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/googleapis/gax-go/v2"
+)
+
+// ErrConfigSuperseded is returned by WaitForConfigAck when the device was
+// sent a newer config before it acknowledged the one waited for.
+var ErrConfigSuperseded = errors.New("iot: config superseded before it was acknowledged")
+
+// defaultConfigAckTimeout is how long WaitForConfigAck waits when ctx has
+// no deadline.
+const defaultConfigAckTimeout = 5 * time.Minute
+
+// configAckBackoff is the delay between the polls of WaitForConfigAck.
+var configAckBackoff = gax.Backoff{
+	Initial:    250 * time.Millisecond,
+	Max:        10 * time.Second,
+	Multiplier: 2,
+}
+
+// ConfigAckTimeoutError is returned by WaitForConfigAck when ctx is done
+// before the device acknowledged the config. It holds what the device
+// reported when it was last checked, for diagnosis.
+type ConfigAckTimeoutError struct {
+	Name    DeviceName
+	Version int64
+	// CurrentVersion is the config version of the device.
+	CurrentVersion     int64
+	LastConfigSendTime string
+	LastErrorTime      string
+	LastErrorStatus    *Status
+	// Err is the error of the context.
+	Err error
+}
+
+func (e *ConfigAckTimeoutError) Error() string {
+	msg := fmt.Sprintf("iot: device %s did not acknowledge config version %d: %s", e.Name, e.Version, e.Err)
+	if e.LastConfigSendTime != "" {
+		msg += fmt.Sprintf(", config last sent at %s", e.LastConfigSendTime)
+	}
+	if e.LastErrorStatus != nil {
+		msg += fmt.Sprintf(", last error at %s: %s", e.LastErrorTime, e.LastErrorStatus.Message)
+	}
+	return msg
+}
+
+func (e *ConfigAckTimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// WaitForConfigAck polls the device with backoff until it acknowledged the
+// config version, and returns the time it did. It waits until the deadline
+// of ctx, or for 5 minutes if ctx has none; once it is done, a
+// *ConfigAckTimeoutError is returned. Errors matching ErrUnavailable are
+// retried with the same backoff. If the device was sent a newer config
+// instead, the error matches ErrConfigSuperseded.
+func (r *ProjectsLocationsRegistriesDevicesService) WaitForConfigAck(ctx context.Context, name DeviceName, version int64) (time.Time, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultConfigAckTimeout)
+		defer cancel()
+	}
+	timeout := &ConfigAckTimeoutError{Name: name, Version: version}
+	backoff := configAckBackoff
+	for {
+		if acked, done, err := r.pollConfigAck(ctx, name, version, timeout); done {
+			return acked, err
+		}
+		timer := time.NewTimer(backoff.Pause())
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			timeout.Err = ctx.Err()
+			return time.Time{}, timeout
+		}
+	}
+}
+
+// pollConfigAck checks the device once, and reports whether waiting is
+// done. It records what the device reported in timeout.
+func (r *ProjectsLocationsRegistriesDevicesService) pollConfigAck(ctx context.Context, name DeviceName, version int64, timeout *ConfigAckTimeoutError) (time.Time, bool, error) {
+	d, err := r.GetByName(name).Context(ctx).Do()
+	switch {
+	case err != nil && ctx.Err() != nil:
+		timeout.Err = ctx.Err()
+		return time.Time{}, true, timeout
+	case errors.Is(err, ErrUnavailable):
+		return time.Time{}, false, nil
+	case err != nil:
+		return time.Time{}, true, err
+	}
+	timeout.LastConfigSendTime = d.LastConfigSendTime
+	timeout.LastErrorTime, timeout.LastErrorStatus = d.LastErrorTime, d.LastErrorStatus
+	if d.Config != nil {
+		timeout.CurrentVersion = d.Config.Version
+	}
+
+	if timeout.CurrentVersion < version {
+		return time.Time{}, true, fmt.Errorf("iot: device %s has no config version %d", name, version)
+	}
+	acked, err := r.ConfigAckTime(ctx, name, d, version)
+	switch {
+	case err != nil || !acked.IsZero():
+		return acked, true, err
+	case timeout.CurrentVersion > version:
+		return time.Time{}, true, fmt.Errorf("iot: device %s config version %d: %w", name, version, ErrConfigSuperseded)
+	}
+	return time.Time{}, false, nil
+}
+
+// ConfigAckTime returns when device d, named name, acknowledged the config
+// version, or the zero time if it has not. If d has a newer config, the ack
+// is looked up in its config history.
+func (r *ProjectsLocationsRegistriesDevicesService) ConfigAckTime(ctx context.Context, name DeviceName, d *Device, version int64) (time.Time, error) {
+	switch {
+	case d.Config == nil || d.Config.Version < version:
+		return time.Time{}, nil
+	case d.Config.Version == version:
+		return parseAckTime(d.Config.DeviceAckTime)
+	}
+	versions, err := r.ConfigVersions.ListByName(name).Context(ctx).Do()
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, c := range versions.DeviceConfigs {
+		if c.Version == version {
+			return parseAckTime(c.DeviceAckTime)
+		}
+	}
+	return time.Time{}, nil
+}
+
+func parseAckTime(ts string) (time.Time, error) {
+	if ts == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339Nano, ts)
+}
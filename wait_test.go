@@ -0,0 +1,92 @@
+package iot_test
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	iot "github.com/clearblade/go-iot"
+	"github.com/clearblade/go-iot/iottest"
+)
+
+func TestWaitForConfigAck(t *testing.T) {
+	fake, service := iottest.NewService(t, "test-project")
+	ctx := context.Background()
+	registry := iot.LocationName{Project: "test-project", Location: "us-central1"}.Registry("registry")
+	iottest.CreateRegistry(t, service, registry)
+	devices := service.Projects.Locations.Registries.Devices
+	name := registry.Device("device")
+	if _, err := devices.CreateIn(registry, &iot.Device{Id: "device"}).Do(); err != nil {
+		t.Fatalf("Failed to create device: %s", err.Error())
+	}
+	modify := func(data string) int64 {
+		config, err := devices.ModifyCloudToDeviceConfigByName(name, &iot.ModifyCloudToDeviceConfigRequest{
+			BinaryData: base64.StdEncoding.EncodeToString([]byte(data)),
+		}).Do()
+		if err != nil {
+			t.Fatalf("Failed to modify config: %s", err.Error())
+		}
+		return config.Version
+	}
+
+	version := modify("on")
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		fake.AckConfig(name.String(), version)
+	}()
+	waitCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	ackTime, err := devices.WaitForConfigAck(waitCtx, name, version)
+	if err != nil {
+		t.Fatalf("Failed to wait for config ack: %s", err.Error())
+	}
+	if ackTime.IsZero() {
+		t.Errorf("Expected an ack time")
+	}
+	acked := version
+
+	version = modify("off")
+	if err := fake.ReportError(name.String(), &iot.Status{Code: 3, Message: "invalid config"}); err != nil {
+		t.Fatalf("Failed to report error: %s", err.Error())
+	}
+	waitCtx, cancel = context.WithTimeout(ctx, 300*time.Millisecond)
+	defer cancel()
+	_, err = devices.WaitForConfigAck(waitCtx, name, version)
+	var timeout *iot.ConfigAckTimeoutError
+	if !errors.As(err, &timeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected a timeout error but got: %v", err)
+	}
+	if timeout.CurrentVersion != version || timeout.LastConfigSendTime == "" || timeout.LastErrorStatus.Message != "invalid config" {
+		t.Errorf("Expected the device's last send time and error but got: %+v", timeout)
+	}
+
+	modify("standby")
+	if _, err := devices.WaitForConfigAck(ctx, name, version); !errors.Is(err, iot.ErrConfigSuperseded) {
+		t.Errorf("Expected ErrConfigSuperseded but got: %v", err)
+	}
+	d, err := devices.GetByName(name).Do()
+	if err != nil {
+		t.Fatalf("Failed to get device: %s", err.Error())
+	}
+	if got, err := devices.ConfigAckTime(ctx, name, d, acked); err != nil || !got.Equal(ackTime) {
+		t.Errorf("Expected the ack time %s from the config history but got: %s %v", ackTime, got, err)
+	}
+
+	// Unavailable responses are polled through. Without automatic retries,
+	// each of them reaches WaitForConfigAck.
+	noRetry, err := iot.NewService(ctx, iot.WithServiceAccountCredentials(fake.Credentials()), iot.WithRetryConfig(nil))
+	if err != nil {
+		t.Fatalf("Failed to initialize service: %s", err.Error())
+	}
+	version = modify("reset")
+	fake.FailNext(503, 2)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		fake.AckConfig(name.String(), version)
+	}()
+	if _, err := noRetry.Projects.Locations.Registries.Devices.WaitForConfigAck(ctx, name, version); err != nil {
+		t.Errorf("Failed to wait for config ack through unavailable responses: %s", err.Error())
+	}
+}